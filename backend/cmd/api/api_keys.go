@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/db/models"
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/types"
+)
+
+type apiKeyCtxKey struct{}
+
+// The API key a request was authenticated with, nil for session users
+func apiKeyFromContext(ctx context.Context) *types.APIKey {
+	key, _ := ctx.Value(apiKeyCtxKey{}).(*types.APIKey)
+	return key
+}
+
+/*
+Authenticates requests with `Authorization: Bearer <API key>` as the key's user, authed then checks
+the key's scopes. Other bearer tokens (e.g. instance keys) are left to their handlers.
+*/
+func (app *App) apiKeyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || !types.IsAPIKey(token) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, key, err := types.AuthenticateAPIKey(r.Context(), token)
+		if errors.Is(err, types.ErrInvalidAPIKey) || errors.Is(err, types.ErrAPIKeyExpired) {
+			HTTPReturnError(w, ErrorOptions{
+				Err:  err.Error(),
+				Code: http.StatusUnauthorized,
+			})
+			return
+		} else if err != nil {
+			HTTPReturnError(w, ErrorOptions{
+				Err: err.Error(),
+			})
+			app.ErrLogger.Print(err)
+			return
+		}
+
+		ctx := context.WithValue(withUser(r.Context(), user), apiKeyCtxKey{}, key)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"` // Never expires if omitted
+}
+
+// The key is only in this response, the database keeps its hash
+func (app *App) handleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	req, err := DecodeJSONBody[CreateAPIKeyRequest](r, w)
+	if err != nil {
+		return
+	}
+
+	scopes := make(models.APIKeyScopes, 0, len(req.Scopes))
+	for _, s := range req.Scopes {
+		scopes = append(scopes, models.APIKeyScope(s))
+	}
+
+	key, err := types.CreateAPIKey(r.Context(), userFromContext(r.Context()).ID, req.Name, scopes, req.ExpiresAt)
+	if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  fmt.Errorf("Failed to create API key - %w", err).Error(),
+			Code: http.StatusBadRequest,
+		})
+		return
+	}
+
+	HTTPSendJSON(w, key, &JSONResponseOptions{
+		StatusCode: http.StatusCreated,
+	})
+}
+
+func (app *App) handleGetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := types.GetAPIKeys(r.Context(), userFromContext(r.Context()).ID)
+	if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: err.Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
+	HTTPSendJSON(w, keys, nil)
+}
+
+func (app *App) handleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	err := types.RevokeAPIKey(r.Context(), userFromContext(r.Context()).ID, r.PathValue("keyID"))
+	if errors.Is(err, types.ErrAPIKeyNotFound) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: http.StatusNotFound,
+		})
+		return
+	} else if app.sendOwnerCheckError(w, err) {
+		return
+	}
+
+	HTTPSendJSON[any](w, nil, nil)
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/db/models"
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/types"
+	"github.com/gorilla/sessions"
+	"github.com/markbates/goth/gothic"
+)
+
+/*
+Users sign in with types.SIGN_IN_PROVIDER and get a session cookie. Signing in to any other
+provider connects an account to the signed in user. gothic keeps its own cookie for the length
+of a sign in, SESSION_NAME is the one that keeps users signed in.
+*/
+const (
+	SESSION_NAME        = "xtrn_session"
+	SESSION_USER_ID_KEY = "user_id"
+	SESSION_MAX_AGE     = 60 * 60 * 24 * 30 // Seconds
+)
+
+const DEFAULT_FRONTEND_ADDRESS = "http://localhost:5173"
+
+// Where users are sent after signing in, and the origin allowed to make credentialed requests. Set with FRONTEND_ADDRESS
+func frontendAddress() string {
+	if addr := os.Getenv("FRONTEND_ADDRESS"); addr != "" {
+		return addr
+	}
+
+	return DEFAULT_FRONTEND_ADDRESS
+}
+
+// Cookie store for sessions and gothic, signed with SESSION_SECRET
+func newSessionStore() (*sessions.CookieStore, error) {
+	secret, err := GetEnv("SESSION_SECRET")
+	if err != nil {
+		return nil, err
+	}
+
+	store := sessions.NewCookieStore([]byte(secret))
+	store.Options = &sessions.Options{
+		Path:     "/",
+		MaxAge:   SESSION_MAX_AGE,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(types.APIAddress(), "https://"),
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	return store, nil
+}
+
+type userCtxKey struct{}
+
+// The signed in user of a request that went through authed
+func userFromContext(ctx context.Context) *types.User {
+	user, _ := ctx.Value(userCtxKey{}).(*types.User)
+	return user
+}
+
+func withUser(ctx context.Context, user *types.User) context.Context {
+	return context.WithValue(ctx, userCtxKey{}, user)
+}
+
+// The user of the request's session, nil if it isn't signed in
+func (app *App) sessionUser(r *http.Request) (*types.User, error) {
+	session, _ := app.Sessions.Get(r, SESSION_NAME)
+
+	userID, ok := session.Values[SESSION_USER_ID_KEY].(string)
+	if !ok || userID == "" {
+		return nil, nil
+	}
+
+	user, err := types.GetUser(r.Context(), userID)
+	if errors.Is(err, types.ErrUserNotFound) {
+		return nil, nil
+	}
+
+	return user, err
+}
+
+// The frontend sends the session cookie so the origin can't be *
+func setCORSHeaders(w http.ResponseWriter) {
+	w.Header().Set("Access-Control-Allow-Origin", frontendAddress())
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+}
+
+/*
+Wraps handlers that need a signed in user, who is put in the request's context (userFromContext).
+Requests authenticated with an API key (apiKeyMiddleware) also need the key to allow scope, "" lets
+any key through. Session users have every scope, routes that change what all users share also
+need adminOnly.
+*/
+func (app *App) authed(scope models.APIKeyScope, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setCORSHeaders(w)
+
+		//CORS preflights don't carry credentials so they're answered here, handlers always get a user
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		user := userFromContext(r.Context())
+		if user == nil {
+			var err error
+			if user, err = app.sessionUser(r); err != nil {
+				HTTPReturnError(w, ErrorOptions{
+					Err: err.Error(),
+				})
+				app.ErrLogger.Print(err)
+				return
+			}
+		}
+
+		if user == nil {
+			HTTPReturnError(w, ErrorOptions{
+				Err:  "Not signed in",
+				Code: http.StatusUnauthorized,
+			})
+			return
+		}
+
+		if key := apiKeyFromContext(r.Context()); key != nil && scope != "" && !key.Scopes.Allows(scope) {
+			HTTPReturnError(w, ErrorOptions{
+				Err:  fmt.Sprintf("API key needs the `%s` scope", scope),
+				Code: http.StatusForbidden,
+			})
+			return
+		}
+
+		next(w, r.WithContext(withUser(r.Context(), user)))
+	}
+}
+
+// Wraps handlers inside authed that only admins (types.User.IsAdmin) can use, whatever their key's scopes
+func (app *App) adminOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !userFromContext(r.Context()).IsAdmin {
+			HTTPReturnError(w, ErrorOptions{
+				Err:  "Only admins can do this",
+				Code: http.StatusForbidden,
+			})
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// Responds to a failed ownership check (types.Check*Owner). Returns false if err is nil
+func (app *App) sendOwnerCheckError(w http.ResponseWriter, err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: http.StatusNotFound,
+		})
+	} else if errors.Is(err, types.ErrForbidden) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: http.StatusForbidden,
+		})
+	} else {
+		HTTPReturnError(w, ErrorOptions{
+			Err: err.Error(),
+		})
+		app.ErrLogger.Print(err)
+	}
+
+	return true
+}
+
+// Session key of the scopes asked for, they're the scopes the connection is granted
+func scopesSessionKey(providerName string) string {
+	return providerName + "_scopes"
+}
+
+/*
+Signs in to a provider. SIGN_IN_PROVIDER signs the user in, other providers need a signed in user
+and ask for their own scopes plus the ones the user's instances need, added to the ones already
+granted. ?image_id=<id> also asks for the scopes of an image that doesn't have an instance yet.
+*/
+func (app *App) handleBeginAuth(w http.ResponseWriter, r *http.Request) {
+	providerName := r.PathValue("provider")
+
+	userID := ""
+	if providerName != types.SIGN_IN_PROVIDER {
+		user, err := app.sessionUser(r)
+		if err != nil {
+			HTTPReturnError(w, ErrorOptions{
+				Err: err.Error(),
+			})
+			app.ErrLogger.Print(err)
+			return
+		} else if user == nil {
+			HTTPReturnError(w, ErrorOptions{
+				Err:  fmt.Sprintf("Sign in with %s before connecting %s", types.SIGN_IN_PROVIDER, providerName),
+				Code: http.StatusUnauthorized,
+			})
+			return
+		}
+
+		userID = user.ID
+	}
+
+	scopes, err := types.OauthScopesToRequest(r.Context(), userID, providerName, r.URL.Query()["image_id"])
+	if errors.Is(err, sql.ErrNoRows) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: http.StatusNotFound,
+		})
+		return
+	} else if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: http.StatusBadRequest,
+		})
+		return
+	}
+
+	sess, err := types.BeginOauthAuth(r.Context(), providerName, scopes, gothic.SetState(r))
+	if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: err.Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
+	authURL, err := sess.GetAuthURL()
+	if err == nil {
+		err = gothic.StoreInSession(providerName, sess.Marshal(), r, w)
+	}
+	if err == nil {
+		err = gothic.StoreInSession(scopesSessionKey(providerName), strings.Join(scopes, " "), r, w)
+	}
+	if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: err.Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusTemporaryRedirect)
+}
+
+func (app *App) handleAuthCallback(w http.ResponseWriter, r *http.Request) {
+	providerName := r.PathValue("provider")
+	r = gothic.GetContextWithProvider(r, providerName)
+
+	//Read before completing, completing clears the session
+	requested, _ := gothic.GetFromSession(scopesSessionKey(providerName), r)
+	scopes := strings.Fields(requested)
+
+	gothUser, err := gothic.CompleteUserAuth(w, r)
+	if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: http.StatusBadRequest,
+		})
+		app.ErrLogger.Printf("OAuth Failed - %v", err)
+		return
+	}
+
+	if providerName == types.SIGN_IN_PROVIDER {
+		user, err := types.SignInUser(r.Context(), gothUser.Email, gothUser.Name)
+		if err != nil {
+			HTTPReturnError(w, ErrorOptions{
+				Err: err.Error(),
+			})
+			app.ErrLogger.Print(err)
+			return
+		}
+
+		session, _ := app.Sessions.Get(r, SESSION_NAME)
+		session.Values[SESSION_USER_ID_KEY] = user.ID
+		if err := session.Save(r, w); err != nil {
+			HTTPReturnError(w, ErrorOptions{
+				Err: fmt.Errorf("Failed to save session - %w", err).Error(),
+			})
+			app.ErrLogger.Print(err)
+			return
+		}
+
+		http.Redirect(w, r, frontendAddress(), http.StatusTemporaryRedirect)
+		return
+	}
+
+	user, err := app.sessionUser(r)
+	if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: err.Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	} else if user == nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  "Not signed in",
+			Code: http.StatusUnauthorized,
+		})
+		return
+	}
+
+	//Users can uncheck scopes so the provider's granted list is saved when it reports one
+	granted, err := types.GrantedOauthScopes(r.Context(), providerName, gothUser)
+	if err != nil {
+		app.ErrLogger.Printf("Saving requested scopes for %s - %v", providerName, err)
+	} else if granted != nil {
+		scopes = granted
+	}
+
+	if err := types.SaveOauthConnection(r.Context(), user.ID, providerName, gothUser.Email, scopes, gothUser.RefreshToken); err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: err.Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
+	fmt.Fprintf(w, "Connected %s. You can close this tab.\n", providerName)
+}
+
+func (app *App) handleLogout(w http.ResponseWriter, r *http.Request) {
+	session, _ := app.Sessions.Get(r, SESSION_NAME)
+	session.Options.MaxAge = -1
+	session.Values = map[any]any{}
+
+	if err := session.Save(r, w); err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: fmt.Errorf("Failed to clear session - %w", err).Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
+	HTTPSendJSON[any](w, nil, nil)
+}
+
+func (app *App) handleGetMe(w http.ResponseWriter, r *http.Request) {
+	HTTPSendJSON(w, userFromContext(r.Context()), nil)
+}
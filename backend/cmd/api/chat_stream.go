@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/types"
+	"github.com/tmc/langchaingo/llms"
+)
+
+type ChatStreamEventType string
+
+const (
+	ChatStreamEventTypeTool    ChatStreamEventType = "TOOL_EVENT"
+	ChatStreamEventTypeMessage ChatStreamEventType = "MESSAGE"
+	ChatStreamEventTypeError   ChatStreamEventType = "ERROR"
+)
+
+/*
+One line of a streamed chat response. Tool events come first as tools run, the last line is
+either the AI message parts (what the chat endpoint returns when not streaming) or an error.
+*/
+type ChatStreamEvent struct {
+	Type  ChatStreamEventType  `json:"type"`
+	Tool  string               `json:"tool,omitempty"`
+	Event *types.ToolCallEvent `json:"event,omitempty"`
+	Parts []llms.ContentPart   `json:"parts,omitempty"`
+	Error string               `json:"error,omitempty"`
+}
+
+// Streams chat events as JSON lines when the request's Accept header is types.TOOL_CALL_STREAM_CONTENT_TYPE
+type chatStream struct {
+	w       http.ResponseWriter
+	enabled bool
+	started bool
+	logger  *log.Logger
+	mu      sync.Mutex
+}
+
+func newChatStream(w http.ResponseWriter, r *http.Request, logger *log.Logger) *chatStream {
+	return &chatStream{
+		w:       w,
+		enabled: r.Header.Get("Accept") == types.TOOL_CALL_STREAM_CONTENT_TYPE,
+		logger:  logger,
+	}
+}
+
+func (c *chatStream) write(e ChatStreamEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.started {
+		c.w.Header().Set("Content-Type", types.TOOL_CALL_STREAM_CONTENT_TYPE)
+		c.w.WriteHeader(http.StatusOK)
+		c.started = true
+	}
+
+	json.NewEncoder(c.w).Encode(e)
+
+	if flusher, ok := c.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (c *chatStream) toolEvent(toolName string, e types.ToolCallEvent) {
+	c.logger.Printf("TOOL EVENT [%s] %s: progress=%v/%v message=%q level=%q data=%v\n", toolName, e.Type, e.Progress, e.Total, e.Message, e.Level, e.Data)
+
+	if c.enabled {
+		c.write(ChatStreamEvent{
+			Type:  ChatStreamEventTypeTool,
+			Tool:  toolName,
+			Event: &e,
+		})
+	}
+}
+
+func (c *chatStream) sendParts(parts []llms.ContentPart) {
+	if !c.started {
+		HTTPSendJSON(c.w, parts, &JSONResponseOptions{})
+		return
+	}
+
+	c.write(ChatStreamEvent{
+		Type:  ChatStreamEventTypeMessage,
+		Parts: parts,
+	})
+}
+
+// Once streaming has started the status code is already sent so errors become an ERROR event
+func (c *chatStream) returnError(opts ErrorOptions) {
+	if !c.started {
+		HTTPReturnError(c.w, opts)
+		return
+	}
+
+	c.write(ChatStreamEvent{
+		Type:  ChatStreamEventTypeError,
+		Error: opts.Err,
+	})
+}
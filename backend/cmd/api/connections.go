@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/types"
+)
+
+// Accounts connected through oauth providers and the instances using them
+func (app *App) handleGetConnections(w http.ResponseWriter, r *http.Request) {
+	connections, err := types.GetOauthConnections(r.Context(), userFromContext(r.Context()).ID)
+	if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: err.Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
+	HTTPSendJSON(w, connections, nil)
+}
+
+func (app *App) handleGetConnection(w http.ResponseWriter, r *http.Request) {
+	connection, err := types.GetOauthConnection(r.Context(), userFromContext(r.Context()).ID, r.PathValue("connectionID"))
+	if errors.Is(err, types.ErrOauthConnectionNotFound) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: http.StatusNotFound,
+		})
+		return
+	} else if errors.Is(err, types.ErrForbidden) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: http.StatusForbidden,
+		})
+		return
+	} else if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: err.Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
+	HTTPSendJSON(w, connection, nil)
+}
+
+// Revokes the token at the provider when it supports it, deletes it and stops the instances using it
+func (app *App) handleDeleteConnection(w http.ResponseWriter, r *http.Request) {
+	deleted, err := types.DeleteOauthConnection(r.Context(), userFromContext(r.Context()).ID, r.PathValue("connectionID"))
+	if errors.Is(err, types.ErrOauthConnectionNotFound) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: http.StatusNotFound,
+		})
+		return
+	} else if errors.Is(err, types.ErrForbidden) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: http.StatusForbidden,
+		})
+		return
+	} else if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: fmt.Errorf("Failed to delete connection - %w", err).Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
+	HTTPSendJSON(w, deleted, nil)
+}
+
+// Attempts a refresh. A revoked token is a failed test, not an error
+func (app *App) handleTestConnection(w http.ResponseWriter, r *http.Request) {
+	test, err := types.TestOauthConnection(r.Context(), userFromContext(r.Context()).ID, r.PathValue("connectionID"))
+	if errors.Is(err, types.ErrOauthConnectionNotFound) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: http.StatusNotFound,
+		})
+		return
+	} else if errors.Is(err, types.ErrForbidden) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: http.StatusForbidden,
+		})
+		return
+	} else if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: fmt.Errorf("Failed to test connection - %w", err).Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
+	HTTPSendJSON(w, test, nil)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/types"
+)
+
+func (app *App) handleGetImages(w http.ResponseWriter, r *http.Request) {
+	imgs, err := types.GetMCPServerImages()
+	if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: err.Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
+	HTTPSendJSON(w, imgs, nil)
+}
+
+func (app *App) handleGetImage(w http.ResponseWriter, r *http.Request) {
+	imageID := r.PathValue("imageID")
+
+	img, err := types.GetMCPServerImageByID(imageID)
+	if errors.Is(err, sql.ErrNoRows) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  fmt.Sprintf("Image `%s` not found", imageID),
+			Code: http.StatusNotFound,
+		})
+		return
+	} else if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: fmt.Errorf("Failed to get image - %w", err).Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
+	HTTPSendJSON(w, img, nil)
+}
+
+// JSON Schema of the env a user gives when creating an instance of the image
+func (app *App) handleGetImageEnvSchema(w http.ResponseWriter, r *http.Request) {
+	imageID := r.PathValue("imageID")
+
+	img, err := types.GetMCPServerImageByID(imageID)
+	if errors.Is(err, sql.ErrNoRows) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  fmt.Sprintf("Image `%s` not found", imageID),
+			Code: http.StatusNotFound,
+		})
+		return
+	} else if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: fmt.Errorf("Failed to get image - %w", err).Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
+	HTTPSendJSON(w, img.EnvSchema.JSONSchema(), nil)
+}
+
+// Body is an image manifest (JSON). Tools are discovered by starting the image before it is saved
+func (app *App) handleCreateImage(w http.ResponseWriter, r *http.Request) {
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: fmt.Errorf("Failed to read request body - %w", err).Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+	defer r.Body.Close()
+
+	manifest, err := types.ParseMCPServerImageManifest(bodyBytes, "json")
+	if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: http.StatusBadRequest,
+		})
+		return
+	}
+
+	img, err := types.NewMCPServerImageFromManifest(manifest)
+	if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: fmt.Errorf("Failed to create image - %w", err).Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
+	HTTPSendJSON(w, img, &JSONResponseOptions{
+		StatusCode: http.StatusCreated,
+	})
+}
+
+type ImageVersionResponse struct {
+	Image *types.MCPServerImage `json:"image"`
+	Diff  *types.MCPToolDiff    `json:"diff"`
+}
+
+// Body is a partial manifest. Omitted fields are inherited from the latest version of the image
+func (app *App) handleCreateImageVersion(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+
+	manifest, err := DecodeJSONBody[types.MCPServerImageManifest](r, w)
+	if err != nil {
+		return
+	}
+
+	img, diff, err := types.NewMCPServerImageVersion(slug, manifest)
+	if errors.Is(err, sql.ErrNoRows) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: http.StatusNotFound,
+		})
+		return
+	} else if errors.Is(err, types.ErrImageVersionExists) || errors.Is(err, types.ErrInvalidManifest) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: http.StatusBadRequest,
+		})
+		return
+	} else if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: fmt.Errorf("Failed to create image version - %w", err).Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
+	HTTPSendJSON(w, ImageVersionResponse{
+		Image: img,
+		Diff:  diff,
+	}, &JSONResponseOptions{
+		StatusCode: http.StatusCreated,
+	})
+}
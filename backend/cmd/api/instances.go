@@ -0,0 +1,258 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/db/models"
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/types"
+)
+
+type CreateInstanceRequest struct {
+	ImageID string            `json:"image_id"`
+	Env     map[string]string `json:"env"`
+}
+
+type ConsentRequiredResponse struct {
+	Error string `json:"error"`
+	*types.OauthConsentRequiredError
+}
+
+// 403 listing the scopes the user has to grant (signing in at auth_url) before the instance can be created
+func sendConsentRequired(w http.ResponseWriter, err error) bool {
+	consentErr := &types.OauthConsentRequiredError{}
+	if !errors.As(err, &consentErr) {
+		return false
+	}
+
+	HTTPSendJSON(w, ConsentRequiredResponse{
+		Error:                     consentErr.Error(),
+		OauthConsentRequiredError: consentErr,
+	}, &JSONResponseOptions{
+		StatusCode: http.StatusForbidden,
+	})
+
+	return true
+}
+
+func (app *App) handleCreateInstance(w http.ResponseWriter, r *http.Request) {
+	req, err := DecodeJSONBody[CreateInstanceRequest](r, w)
+	if err != nil {
+		return
+	}
+
+	inst, err := types.NewMCPServerInstace(userFromContext(r.Context()).ID, req.ImageID, req.Env)
+	if sendConsentRequired(w, err) {
+		return
+	} else if errors.Is(err, sql.ErrNoRows) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  fmt.Sprintf("Image `%s` not found", req.ImageID),
+			Code: http.StatusNotFound,
+		})
+		return
+	} else if errors.Is(err, models.InvalidUserEnvError) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: http.StatusBadRequest,
+		})
+		return
+	} else if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: fmt.Errorf("Failed to create instance - %w", err).Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
+	HTTPSendJSON(w, inst, &JSONResponseOptions{
+		StatusCode: http.StatusCreated,
+	})
+}
+
+type UpgradeInstanceRequest struct {
+	Version int `json:"version"` // Defaults to the latest version of the image
+}
+
+type UpgradeInstanceResponse struct {
+	Instance *types.MCPServerInstance `json:"instance"`
+	Diff     *types.MCPToolDiff       `json:"diff"`
+}
+
+// Responds 404 or 403 unless the instance is the signed in user's
+func (app *App) sendInstanceOwnerError(w http.ResponseWriter, r *http.Request, instanceID string) bool {
+	return app.sendOwnerCheckError(w, types.CheckMCPServerInstanceOwner(r.Context(), userFromContext(r.Context()).ID, instanceID))
+}
+
+func (app *App) handleUpgradeInstance(w http.ResponseWriter, r *http.Request) {
+	instanceID := r.PathValue("instanceID")
+
+	if app.sendInstanceOwnerError(w, r, instanceID) {
+		return
+	}
+
+	req := UpgradeInstanceRequest{}
+	if r.ContentLength != 0 {
+		body, err := DecodeJSONBody[UpgradeInstanceRequest](r, w)
+		if err != nil {
+			return
+		}
+		req = *body
+	}
+
+	inst, diff, err := types.UpgradeMCPServerInstance(instanceID, req.Version)
+	if sendConsentRequired(w, err) {
+		return
+	} else if errors.Is(err, types.ErrInstanceAlreadyOnVersion) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: http.StatusConflict,
+		})
+		return
+	} else if errors.Is(err, types.ErrInstanceDowngrade) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: http.StatusBadRequest,
+		})
+		return
+	} else if errors.Is(err, sql.ErrNoRows) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  fmt.Errorf("Instance or image version not found - %w", err).Error(),
+			Code: http.StatusNotFound,
+		})
+		return
+	} else if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: fmt.Errorf("Failed to upgrade instance - %w", err).Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
+	HTTPSendJSON(w, UpgradeInstanceResponse{
+		Instance: inst,
+		Diff:     diff,
+	}, nil)
+}
+
+type StartInstanceResponse struct {
+	InstanceID string `json:"instance_id"`
+	Address    string `json:"address"`
+}
+
+// Sets the instance's desired state to running and starts it if it isn't already
+func (app *App) handleStartInstance(w http.ResponseWriter, r *http.Request) {
+	instanceID := r.PathValue("instanceID")
+
+	if app.sendInstanceOwnerError(w, r, instanceID) {
+		return
+	}
+
+	address, err := types.StartMCPServerInstance(instanceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: http.StatusNotFound,
+		})
+		return
+	} else if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: fmt.Errorf("Failed to start instance - %w", err).Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
+	HTTPSendJSON(w, StartInstanceResponse{
+		InstanceID: instanceID,
+		Address:    address,
+	}, nil)
+}
+
+// Starts the instance if it was scaled to zero without changing its desired state. Used by other processes before calling an instance
+func (app *App) handleWakeInstance(w http.ResponseWriter, r *http.Request) {
+	instanceID := r.PathValue("instanceID")
+
+	instanceKey := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !VerifyInstanceKey(instanceID, instanceKey) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  types.ErrInvalidInstanceKey.Error(),
+			Code: http.StatusUnauthorized,
+		})
+		return
+	}
+
+	address, err := types.EnsureMCPServerInstanceRunning(instanceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: http.StatusNotFound,
+		})
+		return
+	} else if errors.Is(err, types.ErrInstanceStopped) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: http.StatusConflict,
+		})
+		return
+	} else if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: fmt.Errorf("Failed to start instance - %w", err).Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
+	HTTPSendJSON(w, StartInstanceResponse{
+		InstanceID: instanceID,
+		Address:    address,
+	}, nil)
+}
+
+// Sets the instance's desired state to stopped so its tools aren't offered, and stops it
+func (app *App) handleStopInstance(w http.ResponseWriter, r *http.Request) {
+	instanceID := r.PathValue("instanceID")
+
+	if app.sendInstanceOwnerError(w, r, instanceID) {
+		return
+	}
+
+	if err := types.StopMCPServerInstance(instanceID); errors.Is(err, sql.ErrNoRows) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: http.StatusNotFound,
+		})
+		return
+	} else if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: fmt.Errorf("Failed to stop instance - %w", err).Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
+	HTTPSendJSON[any](w, nil, nil)
+}
+
+// Time taken by each start of a stopped instance, newest first
+func (app *App) handleGetInstanceColdStarts(w http.ResponseWriter, r *http.Request) {
+	instanceID := r.PathValue("instanceID")
+
+	if app.sendInstanceOwnerError(w, r, instanceID) {
+		return
+	}
+
+	starts, err := types.GetMCPServerInstanceColdStarts(instanceID)
+	if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: err.Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
+	HTTPSendJSON(w, starts, nil)
+}
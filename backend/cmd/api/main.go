@@ -11,46 +11,152 @@ import (
 	"log"
 	"net"
 	"net/http"
-	"strings"
+	"os"
 
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/db/models"
 	db "github.com/AbhinavPalacharla/xtrn-personal/internal/db/sqlc"
+	mcp_instance_host "github.com/AbhinavPalacharla/xtrn-personal/internal/mcp-instance-host"
 	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/types"
+	"github.com/gorilla/sessions"
+	"github.com/markbates/goth/gothic"
 	gonanoid "github.com/matoous/go-nanoid/v2"
 	"github.com/tmc/langchaingo/llms"
 	"github.com/tmc/langchaingo/llms/openai"
 )
 
 type App struct {
-	Mux       *http.ServeMux
-	Listener  net.Listener
-	Logger    *log.Logger
-	ErrLogger *log.Logger
+	Mux          *http.ServeMux
+	Listener     net.Listener
+	Logger       *log.Logger
+	ErrLogger    *log.Logger
+	InstanceHost *mcp_instance_host.Host //nil unless INSTANCE_MODE is IN_PROCESS
+	Sessions     *sessions.CookieStore
+}
+
+/*
+Where MCP instances run. Set with INSTANCE_MODE
+
+SIDECAR    = a start-mcp-instance process per instance (default, isolates instances from the API)
+IN_PROCESS = inside the API process, tools are called without an HTTP hop
+*/
+const (
+	INSTANCE_MODE_SIDECAR    = "SIDECAR"
+	INSTANCE_MODE_IN_PROCESS = "IN_PROCESS"
+)
+
+func instanceMode() (string, error) {
+	switch mode := os.Getenv("INSTANCE_MODE"); mode {
+	case "", INSTANCE_MODE_SIDECAR:
+		return INSTANCE_MODE_SIDECAR, nil
+	case INSTANCE_MODE_IN_PROCESS:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("Invalid INSTANCE_MODE `%s` - must be %s or %s", mode, INSTANCE_MODE_SIDECAR, INSTANCE_MODE_IN_PROCESS)
+	}
 }
 
 func NewApp() (*App, error) {
 	a := App{}
 
+	loggers := NewAPILoggers()
+
+	a.Logger = loggers.Logger
+	a.ErrLogger = loggers.ErrLogger
+
+	sessionStore, err := newSessionStore()
+	if err != nil {
+		return nil, err
+	}
+	a.Sessions = sessionStore
+	gothic.Store = sessionStore
+
+	if err := types.UseOauthProviders(context.Background()); err != nil {
+		//Valid providers are still usable
+		a.ErrLogger.Print(err)
+	}
+
 	//Configure HTTP Router
 	a.Mux = http.NewServeMux()
-	a.Mux.HandleFunc("/chats", a.handleChat)
-	a.Mux.HandleFunc("/chats/{chatID}/messages", a.handleChat)
-	a.Mux.HandleFunc("/messages/{chatID}", a.handleGetChatMessages)
+	a.Mux.HandleFunc("GET /auth/{provider}", a.handleBeginAuth)
+	a.Mux.HandleFunc("GET /auth/{provider}/callback", a.handleAuthCallback)
+	a.Mux.HandleFunc("POST /logout", a.handleLogout)
+	a.Mux.HandleFunc("GET /me", a.authed("", a.handleGetMe))
+
+	a.Mux.HandleFunc("/chats", a.authed(models.APIKeyScopeChat, a.handleChat))
+	a.Mux.HandleFunc("/chats/{chatID}/messages", a.authed(models.APIKeyScopeChat, a.handleChat))
+	a.Mux.HandleFunc("/messages/{chatID}", a.authed(models.APIKeyScopeChat, a.handleGetChatMessages))
+
+	a.Mux.HandleFunc("GET /images", a.authed(models.APIKeyScopeInstancesRead, a.handleGetImages))
+	a.Mux.HandleFunc("GET /images/{imageID}", a.authed(models.APIKeyScopeInstancesRead, a.handleGetImage))
+	a.Mux.HandleFunc("GET /images/{imageID}/env-schema", a.authed(models.APIKeyScopeInstancesRead, a.handleGetImageEnvSchema))
+	a.Mux.HandleFunc("POST /images", a.authed(models.APIKeyScopeAdmin, a.adminOnly(a.handleCreateImage)))
+	a.Mux.HandleFunc("POST /images/{slug}/versions", a.authed(models.APIKeyScopeAdmin, a.adminOnly(a.handleCreateImageVersion)))
+
+	a.Mux.HandleFunc("POST /instances", a.authed(models.APIKeyScopeInstancesWrite, a.handleCreateInstance))
+	a.Mux.HandleFunc("POST /instances/{instanceID}/upgrade", a.authed(models.APIKeyScopeInstancesWrite, a.handleUpgradeInstance))
+	a.Mux.HandleFunc("POST /instances/{instanceID}/start", a.authed(models.APIKeyScopeInstancesWrite, a.handleStartInstance))
+	a.Mux.HandleFunc("POST /instances/{instanceID}/stop", a.authed(models.APIKeyScopeInstancesWrite, a.handleStopInstance))
+	a.Mux.HandleFunc("GET /instances/{instanceID}/cold-starts", a.authed(models.APIKeyScopeInstancesRead, a.handleGetInstanceColdStarts))
+	a.Mux.HandleFunc("GET /instances/{instanceID}/sampling", a.authed(models.APIKeyScopeInstancesRead, a.handleGetSamplingRequests))
+
+	a.Mux.HandleFunc("GET /connections", a.authed(models.APIKeyScopeAdmin, a.handleGetConnections))
+	a.Mux.HandleFunc("GET /connections/{connectionID}", a.authed(models.APIKeyScopeAdmin, a.handleGetConnection))
+	a.Mux.HandleFunc("DELETE /connections/{connectionID}", a.authed(models.APIKeyScopeAdmin, a.handleDeleteConnection))
+	a.Mux.HandleFunc("POST /connections/{connectionID}/test", a.authed(models.APIKeyScopeAdmin, a.handleTestConnection))
+
+	a.Mux.HandleFunc("GET /secrets", a.authed(models.APIKeyScopeAdmin, a.adminOnly(a.handleGetSecrets)))
+	a.Mux.HandleFunc("PUT /secrets/{name}", a.authed(models.APIKeyScopeAdmin, a.adminOnly(a.handleSetSecret)))
+	a.Mux.HandleFunc("DELETE /secrets/{name}", a.authed(models.APIKeyScopeAdmin, a.adminOnly(a.handleDeleteSecret)))
+
+	a.Mux.HandleFunc("GET /prompts", a.authed(models.APIKeyScopeChat, a.handleGetPrompts))
+
+	a.Mux.HandleFunc("POST /api-keys", a.authed(models.APIKeyScopeAdmin, a.handleCreateAPIKey))
+	a.Mux.HandleFunc("GET /api-keys", a.authed(models.APIKeyScopeAdmin, a.handleGetAPIKeys))
+	a.Mux.HandleFunc("DELETE /api-keys/{keyID}", a.authed(models.APIKeyScopeAdmin, a.handleRevokeAPIKey))
+
+	//Called by instance processes and xtrn-mcp, not users. Each checks the instance's key
+	a.Mux.HandleFunc("POST /instances/{instanceID}/wake", a.handleWakeInstance)
+	a.Mux.HandleFunc("POST /instances/{instanceID}/oauth/token", a.handleGetInstanceAccessToken)
+	a.Mux.HandleFunc("POST /sampling", a.handleSampling)
 
 	// a.Mux.HandleFunc("/chat", a.handleMessage) //Eventually needs to handle /chat/[chatID]
 
+	mode, err := instanceMode()
+	if err != nil {
+		return nil, err
+	}
+
+	if mode == INSTANCE_MODE_IN_PROCESS {
+		a.InstanceHost = mcp_instance_host.NewHost(types.APIAddress())
+		types.SetMCPInstanceHost(a.InstanceHost)
+		a.Mux.Handle(mcp_instance_host.ROUTE, a.InstanceHost) //Checks the instance's key
+	}
+
 	if listener, err := net.Listen("tcp", ":8080"); err != nil {
 		return nil, err
 	} else {
 		a.Listener = listener
 	}
-	loggers := NewAPILoggers()
-
-	a.Logger = loggers.Logger
-	a.ErrLogger = loggers.ErrLogger
 
 	return &a, nil
 }
 
+// Model used for chats and MCP sampling requests
+const LLM_MODEL = "gpt-4.1-mini"
+
+func newLLM() (*openai.LLM, error) {
+	openAIKey, err := GetEnv("OPENAI_KEY")
+	if err != nil {
+		return nil, err
+	}
+
+	return openai.New(
+		openai.WithToken(openAIKey),
+		openai.WithModel(LLM_MODEL),
+	)
+}
+
 func getMessageHistory(chatID string) ([]llms.MessageContent, error) {
 	msgHist := []llms.MessageContent{}
 
@@ -126,6 +232,10 @@ func getMessageHistory(chatID string) ([]llms.MessageContent, error) {
 func (app *App) handleGetChatMessages(w http.ResponseWriter, r *http.Request) {
 	chatID := r.PathValue("chatID")
 
+	if app.sendOwnerCheckError(w, types.CheckChatOwner(r.Context(), userFromContext(r.Context()).ID, chatID)) {
+		return
+	}
+
 	messages, err := Q.GetViewChatMessges(context.Background(), chatID)
 	if err != nil {
 		HTTPReturnError(w, ErrorOptions{
@@ -207,92 +317,43 @@ func (app *App) handleGetChatMessages(w http.ResponseWriter, r *http.Request) {
 }
 
 type Message struct {
-	Content string `json:"content"`
+	Content   string         `json:"content"`
+	Resources []ChatResource `json:"resources"`
 }
 
-type MCPInstanceTool struct {
-	Name        string
-	Description string
-	InputSchema map[string]any
-}
-
-type ChatMCPInstance struct {
-	ID      string
-	Address string
-	ImgID   string
-	Tools   []MCPInstanceTool
-}
-
-func getMCPTools() ([]llms.Tool, map[string]string, error) {
-	// Fetch MCP instance tools
-	instanceRows, err := Q.GetMCPServerInstances(context.Background())
-
+func getMCPTools(userID string) ([]llms.Tool, map[string]string, error) {
+	instanceTools, err := types.GetMCPInstanceTools(userID)
 	if err != nil {
-		return nil, nil, fmt.Errorf("Failed to get MPC instances - %w", err)
+		return nil, nil, err
 	}
 
-	instances := map[string]*ChatMCPInstance{}
-
-	for _, i := range instanceRows {
-
-		inst, ok := instances[i.InstanceID]
-		if !ok {
-			inst = &ChatMCPInstance{
-				ID:      i.InstanceID,
-				Address: i.Address,
-				ImgID:   i.ImageID.String,
-				Tools:   []MCPInstanceTool{},
-			}
-			ok = true
-			instances[i.InstanceID] = inst
-		}
-
-		if ok {
-			schema := map[string]any{}
-			json.Unmarshal([]byte(i.ToolSchema.String), &schema)
-
-			inst.Tools = append(inst.Tools, MCPInstanceTool{
-				Name:        i.ToolName.String,
-				Description: i.ToolDesc.String,
-				InputSchema: schema,
-			})
-		}
-	}
-
-	ViewObjectAsJSON("MCP INSTANCES", instances, nil)
+	ViewObjectAsJSON("MCP INSTANCE TOOLS", instanceTools, nil)
 
 	tools := []llms.Tool{}
 	toolToAddr := map[string]string{}
 
-	for _, inst := range instances {
-		for _, tool := range inst.Tools {
-			toolName := inst.ID + "___" + tool.Name
+	for _, tool := range instanceTools {
+		toolToAddr[tool.Name] = tool.Address
 
-			toolToAddr[toolName] = inst.Address
-
-			tools = append(tools, llms.Tool{
-				Type: "function",
-				Function: &llms.FunctionDefinition{
-					Name:        toolName,
-					Description: tool.Description,
-					Parameters:  tool.InputSchema,
-				},
-			})
-		}
+		tools = append(tools, llms.Tool{
+			Type: "function",
+			Function: &llms.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.InputSchema,
+			},
+		})
 	}
 
 	return tools, toolToAddr, nil
 }
 
 func (app *App) handleChat(w http.ResponseWriter, r *http.Request) {
-	//CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-
-	//XTRN frontend headers
+	//XTRN frontend headers, authed sets the other CORS headers
 	w.Header().Set("Access-Control-Expose-Headers", "x-xtrn-chat-id")
 
+	user := userFromContext(r.Context())
+
 	newChat := false
 	chatID := r.PathValue("chatID")
 	if chatID == "" {
@@ -300,6 +361,8 @@ func (app *App) handleChat(w http.ResponseWriter, r *http.Request) {
 		id, _ := gonanoid.New()
 		chatID = id
 		newChat = true
+	} else if app.sendOwnerCheckError(w, types.CheckChatOwner(r.Context(), user.ID, chatID)) {
+		return
 	}
 
 	w.Header().Set("x-xtrn-chat-id", chatID)
@@ -325,6 +388,39 @@ func (app *App) handleChat(w http.ResponseWriter, r *http.Request) {
 
 	ViewObjectAsJSON("MESSAGE RECIEVED", msg, nil)
 
+	// Attached resources are added to the history as human messages before the user's message
+	for _, res := range msg.Resources {
+		if app.sendOwnerCheckError(w, types.CheckMCPServerInstanceOwner(r.Context(), user.ID, res.InstanceID)) {
+			return
+		}
+	}
+
+	resourceContents, err := readChatResources(msg.Resources)
+	if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  fmt.Errorf("Failed to read attached resources - %w", err).Error(),
+			Code: http.StatusBadRequest,
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
+	// Slash commands are replaced by the messages of the prompt
+	promptMsgs, isPrompt, err := expandPromptCommand(user.ID, msg.Content)
+	if errors.Is(err, types.ErrPromptNotFound) || errors.Is(err, types.ErrInvalidPromptCommand) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: http.StatusBadRequest,
+		})
+		return
+	} else if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: err.Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
 	/***************** INITIALIZATION *****************/
 	tx, _ := DB.BeginTx(context.Background(), nil)
 	defer tx.Rollback()
@@ -333,28 +429,64 @@ func (app *App) handleChat(w http.ResponseWriter, r *http.Request) {
 
 	if newChat {
 		// Create chat in DB
-		qtx.InsertChat(context.Background(), chatID)
+		qtx.InsertChat(context.Background(), db.InsertChatParams{
+			ID:     chatID,
+			UserID: sql.NullString{String: user.ID, Valid: true},
+		})
 	}
 
-	msgID, _ := gonanoid.New()
+	for _, content := range resourceContents {
+		resourceMsgID, _ := gonanoid.New()
 
-	// Add message to chat
-	err = qtx.InsertMessage(context.Background(), db.InsertMessageParams{
-		ID:   msgID,
-		Role: string(llms.ChatMessageTypeHuman),
-		Content: sql.NullString{
-			String: msg.Content,
-			Valid:  msg.Content != "",
-		},
-		StopReason: sql.NullString{
-			String: "",
-			Valid:  false,
-		},
-		ChatID: chatID,
-	})
+		if err := qtx.InsertMessage(context.Background(), db.InsertMessageParams{
+			ID:   resourceMsgID,
+			Role: string(llms.ChatMessageTypeHuman),
+			Content: sql.NullString{
+				String: content,
+				Valid:  true,
+			},
+			ChatID: chatID,
+		}); err != nil {
+			//Nothing is saved unless the whole message is, the deferred rollback undoes the rest
+			HTTPReturnError(w, ErrorOptions{
+				Err: fmt.Errorf("Failed to insert resource message - %w", err).Error(),
+			})
+			app.ErrLogger.Print(err)
+			return
+		}
+	}
 
-	if err != nil {
-		fmt.Printf("ERROR INSERTING MESSAGE - %v\n", err)
+	if isPrompt {
+		for _, m := range promptMsgs {
+			if err := insertPromptMessage(context.Background(), qtx, chatID, m); err != nil {
+				HTTPReturnError(w, ErrorOptions{
+					Err: fmt.Errorf("Failed to insert prompt message - %w", err).Error(),
+				})
+				app.ErrLogger.Print(err)
+				return
+			}
+		}
+	} else {
+		msgID, _ := gonanoid.New()
+
+		// Add message to chat
+		err = qtx.InsertMessage(context.Background(), db.InsertMessageParams{
+			ID:   msgID,
+			Role: string(llms.ChatMessageTypeHuman),
+			Content: sql.NullString{
+				String: msg.Content,
+				Valid:  msg.Content != "",
+			},
+			StopReason: sql.NullString{
+				String: "",
+				Valid:  false,
+			},
+			ChatID: chatID,
+		})
+
+		if err != nil {
+			fmt.Printf("ERROR INSERTING MESSAGE - %v\n", err)
+		}
 	}
 
 	if err = tx.Commit(); err != nil {
@@ -365,7 +497,7 @@ func (app *App) handleChat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tools, toolToAddr, err := getMCPTools()
+	tools, toolToAddr, err := getMCPTools(user.ID)
 	if err != nil {
 		HTTPReturnError(w, ErrorOptions{
 			Err: err.Error(),
@@ -374,15 +506,7 @@ func (app *App) handleChat(w http.ResponseWriter, r *http.Request) {
 	}
 	_ = toolToAddr
 
-	openAIKey, err := GetEnv("OPENAI_KEY")
-	if err != nil {
-		panic(err)
-	}
-
-	llm, err := openai.New(
-		openai.WithToken(openAIKey),
-		openai.WithModel("gpt-4.1-mini"),
-	)
+	llm, err := newLLM()
 	if err != nil {
 		HTTPReturnError(w, ErrorOptions{
 			Err: fmt.Errorf("Failed to create LLM instance - %w", err).Error(),
@@ -424,6 +548,9 @@ func (app *App) handleChat(w http.ResponseWriter, r *http.Request) {
 
 	ViewObjectAsJSON("PRE-TOOLS MSG HIST LLM", msgHist, nil)
 
+	// Progress and log events from tool calls are streamed if the client accepts them
+	stream := newChatStream(w, r, app.Logger)
+
 	// Execute tool calls
 	for {
 		// Check if there are any tool calls to execute and execute them
@@ -431,9 +558,10 @@ func (app *App) handleChat(w http.ResponseWriter, r *http.Request) {
 			break // No tools needed so end conversation loop and wait for user to send next message
 		}
 
-		msgHist, err = execToolCalls(chatID, msgHist, resp, toolToAddr)
+		// If the client disconnects running tool calls are cancelled on their MCP servers
+		msgHist, err = app.execToolCalls(r.Context(), user.ID, chatID, msgHist, resp, stream.toolEvent)
 		if err != nil {
-			HTTPReturnError(w, ErrorOptions{
+			stream.returnError(ErrorOptions{
 				Err: fmt.Errorf("Failed to save execute tool call - %w", err).Error(),
 			})
 			app.ErrLogger.Print(err)
@@ -445,7 +573,7 @@ func (app *App) handleChat(w http.ResponseWriter, r *http.Request) {
 		// Call LLM again with tool call responses
 		resp, err = llm.GenerateContent(context.Background(), msgHist, llms.WithTools(tools))
 		if err != nil {
-			HTTPReturnError(w, ErrorOptions{
+			stream.returnError(ErrorOptions{
 				Err: fmt.Errorf("Failed to get response from LLM - %w", err).Error(),
 			})
 			app.ErrLogger.Print(err)
@@ -455,7 +583,7 @@ func (app *App) handleChat(w http.ResponseWriter, r *http.Request) {
 		// Add LLM response to msg history
 		msgHist, err = updateMessageHistory(context.Background(), chatID, msgHist, resp)
 		if err != nil {
-			HTTPReturnError(w, ErrorOptions{
+			stream.returnError(ErrorOptions{
 				Err: fmt.Errorf("Failed to update message history - %w", err).Error(),
 			})
 			app.ErrLogger.Print(err)
@@ -465,7 +593,7 @@ func (app *App) handleChat(w http.ResponseWriter, r *http.Request) {
 		ViewObjectAsJSON("MSG HIST LLM", msgHist, nil)
 	}
 
-	HTTPSendJSON(w, msgHist[len(msgHist)-1].Parts, &JSONResponseOptions{})
+	stream.sendParts(msgHist[len(msgHist)-1].Parts)
 }
 
 func updateMessageHistory(ctx context.Context, chatID string, messageHistory []llms.MessageContent, resp *llms.ContentResponse) ([]llms.MessageContent, error) {
@@ -573,12 +701,76 @@ type ToolCallResult struct {
 	IsError bool `json:"is_error"`
 }
 
-func execToolCalls(chatID string, msgHist []llms.MessageContent, resp *llms.ContentResponse, toolToAddr map[string]string) ([]llms.MessageContent, error) {
+// Saves a tool call result as a tool message
+func saveToolCallResult(chatID string, tc llms.ToolCall, content string, isError bool, status models.ToolCallResultStatus) error {
+	ctx := context.Background()
+
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Failed to begin transaction - %w", err)
+	}
+	defer tx.Rollback()
+	qtx := Q.WithTx(tx)
+
+	msgID, _ := gonanoid.New()
+
+	if err := qtx.InsertMessage(ctx, db.InsertMessageParams{
+		ID:         msgID,
+		Role:       string(llms.ChatMessageTypeTool),
+		Content:    sql.NullString{Valid: false},
+		StopReason: sql.NullString{Valid: false},
+		ChatID:     chatID,
+	}); err != nil {
+		return fmt.Errorf("Failed to create message in DB - %w", err)
+	}
+
+	if err := qtx.InsertToolCallResult(ctx, db.InsertToolCallResultParams{
+		MessageID:  msgID,
+		ToolCallID: tc.ID,
+		Name:       tc.FunctionCall.Name,
+		Content:    content,
+		IsError:    isError,
+		Status:     string(status),
+	}); err != nil {
+		return fmt.Errorf("Failed to insert tool call result - %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Failed to insert tool call response into DB - %w", err)
+	}
+
+	return nil
+}
+
+// Calls a tool on an instance process with a streamed /callTool request
+func callInstanceTool(ctx context.Context, instanceID string, addr string, payload ToolCallRequest, onEvent func(types.ToolCallEvent)) (int, []byte, error) {
+	payloadJSONb, _ := json.Marshal(payload)
+
+	// SEND TC REQUEST (streamed so progress and log events can be relayed while the tool runs)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, addr+"/callTool", bytes.NewBuffer(payloadJSONb))
+	if err != nil {
+		return 0, nil, fmt.Errorf("Failed to create /callTool request - %w", err)
+	}
+	if err := SetInstanceKeyHeader(req, instanceID); err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", types.TOOL_CALL_STREAM_CONTENT_TYPE)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("Failed to make request to /callTool - %w", err)
+	}
+	defer res.Body.Close()
+
+	return types.ReadToolCallResponse(res, onEvent)
+}
+
+// onEvent gets the progress and log events of each tool call and can be nil
+func (app *App) execToolCalls(ctx context.Context, userID string, chatID string, msgHist []llms.MessageContent, resp *llms.ContentResponse, onEvent func(toolName string, e types.ToolCallEvent)) ([]llms.MessageContent, error) {
 	fmt.Println("Executing", len(resp.Choices[0].ToolCalls), "tool calls")
 
 	for _, tc := range resp.Choices[0].ToolCalls {
-		addr := toolToAddr[tc.FunctionCall.Name]
-
 		// PREPARE TC REQUEST
 		var args map[string]any
 		json.Unmarshal([]byte(tc.FunctionCall.Arguments), &args)
@@ -586,24 +778,45 @@ func execToolCalls(chatID string, msgHist []llms.MessageContent, resp *llms.Cont
 		payload := ToolCallRequest{
 			ToolUseID: tc.ID,
 			Name: func() string {
-				funcName := strings.Split(tc.FunctionCall.Name, "___")
-				return funcName[1]
+				_, toolName, _ := types.SplitMCPToolName(tc.FunctionCall.Name)
+				return toolName
 			}(),
 			Arguments: args,
 		}
 
-		payloadJSONb, _ := json.Marshal(payload)
-
 		ViewObjectAsJSON("REQUEST PAYLOAD", payload, nil)
 
-		// SEND TC REQUEST
-		res, err := http.Post(addr+"/callTool", "application/json", bytes.NewBuffer(payloadJSONb))
+		onToolEvent := func(e types.ToolCallEvent) {
+			if onEvent != nil {
+				onEvent(tc.FunctionCall.Name, e)
+			}
+		}
+
+		//Stopped instances are started by the first tool call that needs them
+		instanceID, _, _ := types.SplitMCPToolName(tc.FunctionCall.Name)
+
+		//Only the user's tools are offered but the name comes from the LLM
+		if err := types.CheckMCPServerInstanceOwner(ctx, userID, instanceID); err != nil {
+			return nil, err
+		}
+
+		addr, err := types.EnsureMCPServerInstanceRunning(instanceID)
 		if err != nil {
-			return nil, fmt.Errorf("Failed to make request to /callTool - %w", err)
+			return nil, err
+		}
+
+		//Instances hosted by the API are called directly, everything else over HTTP
+		status, body, hosted := app.InstanceHost.CallTool(ctx, instanceID, payload.ToolUseID, payload.Name, payload.Arguments, onToolEvent)
+		if !hosted {
+			if status, body, err = callInstanceTool(ctx, instanceID, addr, payload, onToolEvent); err != nil {
+				return nil, err
+			}
 		}
-		defer res.Body.Close()
 
-		if res.StatusCode == http.StatusUnauthorized {
+		//Long tool calls count as use until they finish
+		types.TouchMCPServerInstance(instanceID)
+
+		if status == http.StatusUnauthorized {
 			// Tool Response
 			msgHist = append(msgHist, llms.MessageContent{
 				Role: llms.ChatMessageTypeTool,
@@ -616,8 +829,7 @@ func execToolCalls(chatID string, msgHist []llms.MessageContent, resp *llms.Cont
 				},
 			})
 
-			ts := strings.Split(tc.FunctionCall.Name, "___")
-			mcp := ts[0]
+			mcp, _, _ := types.SplitMCPToolName(tc.FunctionCall.Name)
 
 			// System message
 			msgHist = append(msgHist, llms.MessageContent{
@@ -649,6 +861,7 @@ func execToolCalls(chatID string, msgHist []llms.MessageContent, resp *llms.Cont
 				Name:       tc.FunctionCall.Name,
 				Content:    "Function could not be executed because user is unauthorized. User must re-authenticate to continue.",
 				IsError:    true,
+				Status:     string(models.ToolCallResultStatusUnauthorized),
 			})
 
 			// System message to DB
@@ -670,6 +883,36 @@ func execToolCalls(chatID string, msgHist []llms.MessageContent, resp *llms.Cont
 				return nil, err
 			}
 
+		} else if status == http.StatusRequestTimeout {
+			// Tool call timed out and was cancelled on the MCP server
+			var errRes struct {
+				Error string `json:"error"`
+			}
+			json.Unmarshal(body, &errRes)
+
+			content := errRes.Error
+			if content == "" {
+				content = "Tool call timed out and was cancelled"
+			}
+
+			msgHist = append(msgHist, llms.MessageContent{
+				Role: llms.ChatMessageTypeTool,
+				Parts: []llms.ContentPart{
+					llms.ToolCallResponse{
+						ToolCallID: tc.ID,
+						Name:       tc.FunctionCall.Name,
+						Content:    content,
+					},
+				},
+			})
+
+			// Same shape as the content of other results
+			contentJSONb, _ := json.Marshal([]map[string]string{{"type": "text", "text": content}})
+
+			if err := saveToolCallResult(chatID, tc, string(contentJSONb), true, models.ToolCallResultStatusTimeout); err != nil {
+				return nil, err
+			}
+
 		} else {
 
 			// IF RESPONSE HTTP TYPE is 401 then that means the user is needs to re-authenticate.
@@ -679,11 +922,6 @@ func execToolCalls(chatID string, msgHist []llms.MessageContent, resp *llms.Cont
 				Send request to user to re-authenticate (stream JSON object)
 			*/
 
-			body, err := io.ReadAll(res.Body)
-			if err != nil {
-				return nil, fmt.Errorf("Failed to read request body - %w", err)
-			}
-
 			var tcRes ToolCallResult
 			json.Unmarshal(body, &tcRes)
 
@@ -708,37 +946,15 @@ func execToolCalls(chatID string, msgHist []llms.MessageContent, resp *llms.Cont
 			})
 
 			// Add TC Res to DB
-			ctx := context.Background()
-
-			tx, err := DB.BeginTx(ctx, nil)
-			defer tx.Rollback()
-			qtx := Q.WithTx(tx)
-
-			msgID, _ := gonanoid.New()
-
-			if err := qtx.InsertMessage(ctx, db.InsertMessageParams{
-				ID:         msgID,
-				Role:       string(llms.ChatMessageTypeTool),
-				Content:    sql.NullString{Valid: false},
-				StopReason: sql.NullString{Valid: false},
-				ChatID:     chatID,
-			}); err != nil {
-				return nil, fmt.Errorf("Failed to create message in DB - %w", err)
+			resStatus := models.ToolCallResultStatusOK
+			if tcRes.IsError || status != http.StatusOK {
+				resStatus = models.ToolCallResultStatusError
 			}
 
-			qtx.InsertToolCallResult(ctx, db.InsertToolCallResultParams{
-				MessageID:  msgID,
-				ToolCallID: tc.ID,
-				Name:       tc.FunctionCall.Name,
-				Content: func() string {
-					contentJSONb, _ := json.Marshal(tcRes.Content)
-					return string(contentJSONb)
-				}(),
-				IsError: tcRes.IsError,
-			})
+			contentJSONb, _ := json.Marshal(tcRes.Content)
 
-			if err := tx.Commit(); err != nil {
-				return nil, fmt.Errorf("Failed to insert tool call response into DB - %w", err)
+			if err := saveToolCallResult(chatID, tc, string(contentJSONb), tcRes.IsError, resStatus); err != nil {
+				return nil, err
 			}
 		}
 	}
@@ -749,7 +965,7 @@ func execToolCalls(chatID string, msgHist []llms.MessageContent, resp *llms.Cont
 func (app *App) StartServer() error {
 	app.Logger.Printf("🚀 Starting server on %s\n", app.Listener.Addr().String())
 
-	return http.Serve(app.Listener, app.Mux)
+	return http.Serve(app.Listener, app.apiKeyMiddleware(app.Mux))
 }
 
 func (app *App) PANIC(reason string) {
@@ -765,6 +981,21 @@ func main() {
 		a.PANIC(fmt.Errorf("Failed to create new app - %w", err).Error())
 	}
 
+	//Hosted instances stopped with the last run
+	if err := types.MarkHostedMCPServerInstancesStopped(); err != nil {
+		a.ErrLogger.Print(err)
+	}
+
+	idleTimeout, err := types.InstanceIdleTimeout()
+	if err != nil {
+		a.PANIC(err.Error())
+	}
+	go types.WatchIdleMCPServerInstances(context.Background(), idleTimeout)
+
+	if err := types.StartWarmPools(); err != nil {
+		a.ErrLogger.Print(err)
+	}
+
 	a.StartServer()
 }
 
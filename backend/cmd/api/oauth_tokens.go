@@ -0,0 +1,52 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/types"
+)
+
+/*
+Token endpoint for instances ($broker.token_url). The instance sends its $broker.token_key as
+`Authorization: Bearer <key>` and gets a short-lived access token for its image's oauth provider.
+*/
+func (app *App) handleGetInstanceAccessToken(w http.ResponseWriter, r *http.Request) {
+	instanceID := r.PathValue("instanceID")
+	instanceKey := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	token, err := types.GetMCPServerInstanceAccessToken(r.Context(), instanceID, instanceKey)
+	if errors.Is(err, types.ErrInvalidInstanceKey) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: http.StatusUnauthorized,
+		})
+		return
+	} else if errors.Is(err, sql.ErrNoRows) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  fmt.Sprintf("Instance `%s` not found", instanceID),
+			Code: http.StatusNotFound,
+		})
+		return
+	} else if errors.Is(err, types.ErrOauthTokenRevoked) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: http.StatusConflict,
+		})
+		return
+	} else if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: fmt.Errorf("Failed to get access token - %w", err).Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
+	HTTPSendJSON(w, token, &JSONResponseOptions{
+		Headers: map[string]string{"Cache-Control": "no-store"},
+	})
+}
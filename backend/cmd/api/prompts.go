@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	db "github.com/AbhinavPalacharla/xtrn-personal/internal/db/sqlc"
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/types"
+	"github.com/mark3labs/mcp-go/mcp"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// Prompts of the user's running instances. Each can be used in a chat as /<command> arg=value
+func (app *App) handleGetPrompts(w http.ResponseWriter, r *http.Request) {
+	prompts, err := types.GetMCPInstancePrompts(userFromContext(r.Context()).ID)
+	if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: err.Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
+	HTTPSendJSON(w, prompts, nil)
+}
+
+type PromptMessage struct {
+	Role    llms.ChatMessageType
+	Content string
+}
+
+func promptMessageText(c mcp.Content) string {
+	switch content := c.(type) {
+	case mcp.TextContent:
+		return content.Text
+	case mcp.EmbeddedResource:
+		if rc, ok := content.Resource.(mcp.TextResourceContents); ok {
+			return formatResourceContents(rc.URI, []mcp.ResourceContents{rc})
+		}
+		return "[binary resource not included]"
+	case mcp.ImageContent:
+		return "[image not included]"
+	case mcp.AudioContent:
+		return "[audio not included]"
+	}
+
+	return ""
+}
+
+/*
+Expands a slash command into the messages returned by the prompt. ok is false if content is
+not a slash command so it should be sent as a regular message.
+*/
+func expandPromptCommand(userID string, content string) (msgs []PromptMessage, ok bool, err error) {
+	command, args, ok, err := types.ParsePromptCommand(content)
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+
+	prompt, err := types.FindMCPInstancePrompt(userID, command)
+	if err != nil {
+		return nil, true, err
+	}
+
+	promptMsgs, err := prompt.GetMessages(args)
+	if err != nil {
+		return nil, true, fmt.Errorf("Failed to expand /%s - %w", command, err)
+	}
+
+	msgs = []PromptMessage{}
+
+	for _, m := range promptMsgs {
+		role := llms.ChatMessageTypeHuman
+		if m.Role == mcp.RoleAssistant {
+			role = llms.ChatMessageTypeAI
+		}
+
+		msgs = append(msgs, PromptMessage{
+			Role:    role,
+			Content: promptMessageText(m.Content),
+		})
+	}
+
+	return msgs, true, nil
+}
+
+// Saves an expanded prompt message to the chat. Assistant messages are stored as AI text messages
+func insertPromptMessage(ctx context.Context, qtx *db.Queries, chatID string, m PromptMessage) error {
+	msgID, _ := gonanoid.New()
+
+	if m.Role == llms.ChatMessageTypeHuman {
+		return qtx.InsertMessage(ctx, db.InsertMessageParams{
+			ID:   msgID,
+			Role: string(llms.ChatMessageTypeHuman),
+			Content: sql.NullString{
+				String: m.Content,
+				Valid:  m.Content != "",
+			},
+			ChatID: chatID,
+		})
+	}
+
+	if err := qtx.InsertMessage(ctx, db.InsertMessageParams{
+		ID:     msgID,
+		Role:   string(llms.ChatMessageTypeAI),
+		ChatID: chatID,
+	}); err != nil {
+		return fmt.Errorf("Failed to insert message - %w", err)
+	}
+
+	partID, err := qtx.InsertAIMessagePart(ctx, db.InsertAIMessagePartParams{
+		Type:      "text",
+		PartIndex: 0,
+		MessageID: msgID,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to insert AI message part - %w", err)
+	}
+
+	return qtx.InsertTextPart(ctx, db.InsertTextPartParams{
+		Text: sql.NullString{
+			String: m.Content,
+			Valid:  m.Content != "",
+		},
+		MessagePartID: partID,
+	})
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/types"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Resource a user attached to a chat message
+type ChatResource struct {
+	InstanceID string `json:"instance_id"`
+	URI        string `json:"uri"`
+}
+
+// Text injected into the chat history for a resource. Binary contents are described but not included
+func formatResourceContents(uri string, contents []mcp.ResourceContents) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "The user attached the resource `%s`:\n", uri)
+
+	for _, c := range contents {
+		switch rc := c.(type) {
+		case mcp.TextResourceContents:
+			fmt.Fprintf(&sb, "\n<resource uri=%q mime_type=%q>\n%s\n</resource>\n", rc.URI, rc.MIMEType, rc.Text)
+		case mcp.BlobResourceContents:
+			fmt.Fprintf(&sb, "\n<resource uri=%q mime_type=%q>[binary content not included]</resource>\n", rc.URI, rc.MIMEType)
+		}
+	}
+
+	return sb.String()
+}
+
+// Reads every attached resource and returns the message content for each one
+func readChatResources(resources []ChatResource) ([]string, error) {
+	contents := []string{}
+
+	for _, r := range resources {
+		rc, err := types.ReadMCPInstanceResource(r.InstanceID, r.URI)
+		if err != nil {
+			return nil, err
+		}
+
+		contents = append(contents, formatResourceContents(r.URI, rc))
+	}
+
+	return contents, nil
+}
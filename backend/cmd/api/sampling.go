@@ -0,0 +1,226 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/db/models"
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/types"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// Converts the messages of a sampling request to LLM messages. Text and image content is supported
+func samplingMessages(params mcp.CreateMessageParams) ([]llms.MessageContent, error) {
+	msgs := []llms.MessageContent{}
+
+	if params.SystemPrompt != "" {
+		msgs = append(msgs, llms.TextParts(llms.ChatMessageTypeSystem, params.SystemPrompt))
+	}
+
+	for _, m := range params.Messages {
+		role := llms.ChatMessageTypeHuman
+		if m.Role == mcp.RoleAssistant {
+			role = llms.ChatMessageTypeAI
+		}
+
+		contentMap, ok := m.Content.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("Invalid sampling message content")
+		}
+
+		content, err := mcp.ParseContent(contentMap)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid sampling message content - %w", err)
+		}
+
+		switch c := content.(type) {
+		case mcp.TextContent:
+			msgs = append(msgs, llms.TextParts(role, c.Text))
+
+		case mcp.ImageContent:
+			data, err := base64.StdEncoding.DecodeString(c.Data)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid sampling image data - %w", err)
+			}
+
+			msgs = append(msgs, llms.MessageContent{
+				Role:  role,
+				Parts: []llms.ContentPart{llms.BinaryPart(c.MIMEType, data)},
+			})
+
+		default:
+			return nil, fmt.Errorf("Unsupported sampling message content `%s`", contentMap["type"])
+		}
+	}
+
+	return msgs, nil
+}
+
+// MCP stop reasons are camelCase, anything unknown is passed through
+func samplingStopReason(reason string) string {
+	switch reason {
+	case "stop":
+		return "endTurn"
+	case "length":
+		return "maxTokens"
+	}
+
+	return reason
+}
+
+func (app *App) recordSamplingRequest(rec *types.MCPSamplingRecord) {
+	if err := types.RecordMCPSamplingRequest(rec); err != nil {
+		app.ErrLogger.Print(err)
+	}
+}
+
+/*
+Runs a sampling/createMessage request from an instance's MCP server through the LLM. The request
+is authenticated with the instance's key (`Authorization: Bearer <instance key>`). The image's
+sampling policy decides if it is allowed and caps max tokens. Every request is recorded.
+*/
+func (app *App) handleSampling(w http.ResponseWriter, r *http.Request) {
+	req, err := DecodeJSONBody[types.MCPSamplingRequest](r, w)
+	if err != nil {
+		return
+	}
+
+	instanceKey := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !VerifyInstanceKey(req.InstanceID, instanceKey) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  types.ErrInvalidInstanceKey.Error(),
+			Code: http.StatusUnauthorized,
+		})
+		return
+	}
+
+	var params mcp.CreateMessageParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  fmt.Errorf("Invalid sampling params - %w", err).Error(),
+			Code: http.StatusBadRequest,
+		})
+		return
+	}
+
+	imageID, policy, err := types.GetMCPInstanceSamplingPolicy(req.InstanceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: http.StatusNotFound,
+		})
+		return
+	} else if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: err.Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
+	rec := types.MCPSamplingRecord{
+		InstanceID: req.InstanceID,
+		ImageID:    imageID,
+		MaxTokens:  policy.LimitTokens(params.MaxTokens),
+		Request:    req.Params,
+	}
+
+	fail := func(err error, code int) {
+		rec.Status = models.SamplingRequestStatusFailed
+		rec.Error = err.Error()
+		app.recordSamplingRequest(&rec)
+
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: code,
+		})
+		app.ErrLogger.Print(err)
+	}
+
+	if !policy.Allowed {
+		rec.Status = models.SamplingRequestStatusDenied
+		rec.Error = types.ErrSamplingDenied.Error()
+		app.recordSamplingRequest(&rec)
+
+		HTTPReturnError(w, ErrorOptions{
+			Err:  fmt.Errorf("%w - image %s", types.ErrSamplingDenied, imageID).Error(),
+			Code: http.StatusForbidden,
+		})
+		return
+	}
+
+	msgs, err := samplingMessages(params)
+	if err != nil {
+		fail(err, http.StatusBadRequest)
+		return
+	}
+
+	llm, err := newLLM()
+	if err != nil {
+		fail(fmt.Errorf("Failed to create LLM instance - %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	opts := []llms.CallOption{}
+	if rec.MaxTokens > 0 {
+		opts = append(opts, llms.WithMaxTokens(rec.MaxTokens))
+	}
+	if params.Temperature > 0 {
+		opts = append(opts, llms.WithTemperature(params.Temperature))
+	}
+	if len(params.StopSequences) > 0 {
+		opts = append(opts, llms.WithStopWords(params.StopSequences))
+	}
+
+	rec.Model = LLM_MODEL
+
+	resp, err := llm.GenerateContent(r.Context(), msgs, opts...)
+	if err != nil {
+		fail(fmt.Errorf("Failed to generate sampling message - %w", err), http.StatusBadGateway)
+		return
+	}
+
+	result := mcp.CreateMessageResult{
+		SamplingMessage: mcp.SamplingMessage{
+			Role:    mcp.RoleAssistant,
+			Content: mcp.NewTextContent(resp.Choices[0].Content),
+		},
+		Model:      LLM_MODEL,
+		StopReason: samplingStopReason(resp.Choices[0].StopReason),
+	}
+
+	rec.Status = models.SamplingRequestStatusCompleted
+	rec.Response, _ = json.Marshal(result)
+	app.recordSamplingRequest(&rec)
+
+	ViewObjectAsJSON("SAMPLING RESULT", result, app.Logger.Printf)
+
+	HTTPSendJSON(w, result, nil)
+}
+
+// Audit log of an instance's sampling requests, newest first
+func (app *App) handleGetSamplingRequests(w http.ResponseWriter, r *http.Request) {
+	instanceID := r.PathValue("instanceID")
+
+	if app.sendInstanceOwnerError(w, r, instanceID) {
+		return
+	}
+
+	recs, err := types.GetMCPSamplingRequests(instanceID)
+	if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: err.Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
+	HTTPSendJSON(w, recs, nil)
+}
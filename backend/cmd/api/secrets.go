@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/types"
+)
+
+type SetSecretRequest struct {
+	Value string `json:"value"`
+}
+
+// Names of the secrets env schemas can use as $secret.<name>, values are never returned
+func (app *App) handleGetSecrets(w http.ResponseWriter, r *http.Request) {
+	secrets, err := types.GetSecrets()
+	if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: err.Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
+	HTTPSendJSON(w, secrets, nil)
+}
+
+func (app *App) handleSetSecret(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	req, err := DecodeJSONBody[SetSecretRequest](r, w)
+	if err != nil {
+		return
+	}
+
+	if err := types.SetSecret(name, req.Value); errors.Is(err, types.ErrInvalidSecretName) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: http.StatusBadRequest,
+		})
+		return
+	} else if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: fmt.Errorf("Failed to save secret - %w", err).Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
+	HTTPSendJSON(w, types.Secret{Name: name}, nil)
+}
+
+// Instances already started with the secret keep its value
+func (app *App) handleDeleteSecret(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	if err := types.DeleteSecret(name); errors.Is(err, types.ErrSecretNotFound) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  err.Error(),
+			Code: http.StatusNotFound,
+		})
+		return
+	} else if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: fmt.Errorf("Failed to delete secret - %w", err).Error(),
+		})
+		app.ErrLogger.Print(err)
+		return
+	}
+
+	HTTPSendJSON[any](w, nil, nil)
+}
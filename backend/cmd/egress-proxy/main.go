@@ -0,0 +1,30 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/db/models"
+	egress_proxy "github.com/AbhinavPalacharla/xtrn-personal/internal/egress-proxy"
+)
+
+// Runs in a container on the egress network and the default bridge, see `make setup-egress-network`
+func main() {
+	listen := flag.String("listen", ":3128", "Address to listen on")
+	network := flag.String("network", models.DEFAULT_EGRESS_NETWORK, "Docker network of the EGRESS_ALLOWLIST containers")
+	dockerSocket := flag.String("docker-socket", "/var/run/docker.sock", "Docker API socket")
+	flag.Parse()
+
+	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
+	errLogger := log.New(os.Stderr, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
+
+	proxy := egress_proxy.NewProxy(*network, egress_proxy.NewDockerClient(*dockerSocket), logger, errLogger)
+
+	logger.Printf("🚀 Egress proxy for %s on %s\n", *network, *listen)
+
+	if err := http.ListenAndServe(*listen, proxy); err != nil {
+		errLogger.Fatal(err)
+	}
+}
@@ -1,21 +1,22 @@
 package main
 
 import (
-	"fmt"
-	"log"
-
 	new_mcp_instance "github.com/AbhinavPalacharla/xtrn-personal/internal/new-mcp-instance"
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
 )
 
+// Errors can echo the launcher's args and env so they go through the redacting StdErrLogger
 func main() {
 	app, err := new_mcp_instance.NewApp()
 	if err != nil {
-		log.Fatalf("Failed to initialize MCP instance: %v\n", err)
+		StdErrLogger.Fatalf("Failed to initialize MCP instance: %v\n", err)
 	}
 
 	//Start server app.startServer()
 	s := new_mcp_instance.NewHTTPServer(app)
 	if err := s.StartServer(); err != nil {
-		panic(fmt.Sprintf("Failed to start HTTP Server - %v", err))
+		StdErrLogger.Fatalf("Failed to start HTTP Server - %v\n", err)
 	}
+
+	app.Control.Close()
 }
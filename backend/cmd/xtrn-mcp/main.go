@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/types"
+	xtrn_mcp_server "github.com/AbhinavPalacharla/xtrn-personal/internal/xtrn-mcp-server"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Serves the tools of every running instance of a user as a single MCP server over stdio or streamable HTTP
+func main() {
+	email := flag.String("user", "", "Email of the user whose instances are served")
+	transport := flag.String("transport", "stdio", "stdio or http")
+	address := flag.String("address", ":8090", "Address to listen on for the http transport")
+	reloadInterval := flag.Duration("reload-interval", 30*time.Second, "How often to reload instance tools")
+	flag.Parse()
+
+	//stdout is the MCP stream for stdio so only log to stderr
+	logger := log.New(os.Stderr, "XTRN MCP: ", log.Ldate|log.Ltime|log.Lshortfile)
+
+	if *email == "" {
+		logger.Fatalf("--user is required\n")
+	}
+
+	user, err := types.GetUserByEmail(context.Background(), *email)
+	if err != nil {
+		logger.Fatalf("%v\n", err)
+	}
+
+	s, err := xtrn_mcp_server.NewServer(logger, user.ID)
+	if err != nil {
+		logger.Fatalf("Failed to create MCP server - %v\n", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go s.WatchInstances(ctx, *reloadInterval)
+
+	switch *transport {
+	case "stdio":
+		if err := server.ServeStdio(s.MCPServer, server.WithErrorLogger(logger)); err != nil {
+			logger.Fatalf("Stdio server failed - %v\n", err)
+		}
+	case "http":
+		logger.Printf("🚀 Serving MCP on %s/mcp\n", *address)
+
+		if err := server.NewStreamableHTTPServer(s.MCPServer).Start(*address); err != nil {
+			logger.Fatalf("HTTP server failed - %v\n", err)
+		}
+	default:
+		logger.Fatalf("Unknown transport `%s` must be stdio or http\n", *transport)
+	}
+}
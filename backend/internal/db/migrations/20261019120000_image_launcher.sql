@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE mcp_server_images
+ADD COLUMN launcher JSON NOT NULL DEFAULT '{"type":"DOCKER"}';
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE mcp_server_images
+DROP COLUMN launcher;
+
+-- +goose StatementEnd
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE mcp_server_resources (
+  id TEXT NOT NULL,
+  uri TEXT NOT NULL,
+  name TEXT NOT NULL,
+  description TEXT,
+  mime_type TEXT,
+  image_id TEXT NOT NULL,
+  FOREIGN KEY (image_id) REFERENCES mcp_server_images (id)
+);
+
+CREATE TABLE mcp_server_resource_templates (
+  id TEXT NOT NULL,
+  uri_template TEXT NOT NULL,
+  name TEXT NOT NULL,
+  description TEXT,
+  mime_type TEXT,
+  image_id TEXT NOT NULL,
+  FOREIGN KEY (image_id) REFERENCES mcp_server_images (id)
+);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE mcp_server_resource_templates;
+
+DROP TABLE mcp_server_resources;
+
+-- +goose StatementEnd
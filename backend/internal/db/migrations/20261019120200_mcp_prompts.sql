@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE mcp_server_prompts (
+  id TEXT NOT NULL,
+  name TEXT NOT NULL,
+  description TEXT,
+  arguments JSON NOT NULL,
+  image_id TEXT NOT NULL,
+  FOREIGN KEY (image_id) REFERENCES mcp_server_images (id)
+);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE mcp_server_prompts;
+
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE mcp_server_images
+ADD COLUMN tool_timeouts JSON NOT NULL DEFAULT '{}';
+
+-- Seconds, 0 = instance default
+ALTER TABLE mcp_server_tools
+ADD COLUMN timeout_seconds INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE tool_call_result
+ADD COLUMN status TEXT NOT NULL DEFAULT 'OK' CHECK (
+  status in ('OK', 'ERROR', 'TIMEOUT', 'UNAUTHORIZED')
+);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE tool_call_result
+DROP COLUMN status;
+
+ALTER TABLE mcp_server_tools
+DROP COLUMN timeout_seconds;
+
+ALTER TABLE mcp_server_images
+DROP COLUMN tool_timeouts;
+
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE mcp_server_images
+ADD COLUMN sampling_policy JSON NOT NULL DEFAULT '{"allowed":false}';
+
+CREATE TABLE mcp_sampling_requests (
+  id TEXT PRIMARY KEY,
+  instance_id TEXT NOT NULL,
+  image_id TEXT NOT NULL,
+  status TEXT NOT NULL CHECK (status in ('COMPLETED', 'DENIED', 'FAILED')),
+  model TEXT,
+  max_tokens INTEGER NOT NULL,
+  request TEXT NOT NULL,
+  response TEXT,
+  error TEXT,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS mcp_sampling_requests;
+
+ALTER TABLE mcp_server_images
+DROP COLUMN sampling_policy;
+
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE mcp_server_instance_tools (
+  instance_id TEXT PRIMARY KEY,
+  tools TEXT NOT NULL,
+  updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS mcp_server_instance_tools;
+
+-- +goose StatementEnd
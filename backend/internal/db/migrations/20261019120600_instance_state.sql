@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE mcp_server_instances
+ADD COLUMN desired_state TEXT NOT NULL DEFAULT 'RUNNING' CHECK (desired_state IN ('RUNNING', 'STOPPED'));
+
+ALTER TABLE mcp_server_instances
+ADD COLUMN runtime_state TEXT NOT NULL DEFAULT 'RUNNING' CHECK (runtime_state IN ('RUNNING', 'STOPPED'));
+
+ALTER TABLE mcp_server_instances
+ADD COLUMN last_used_at DATETIME;
+
+CREATE TABLE mcp_server_instance_cold_starts (
+  id TEXT PRIMARY KEY,
+  instance_id TEXT NOT NULL,
+  duration_ms INTEGER NOT NULL,
+  error TEXT,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS mcp_server_instance_cold_starts;
+
+ALTER TABLE mcp_server_instances
+DROP COLUMN last_used_at;
+
+ALTER TABLE mcp_server_instances
+DROP COLUMN runtime_state;
+
+ALTER TABLE mcp_server_instances
+DROP COLUMN desired_state;
+
+-- +goose StatementEnd
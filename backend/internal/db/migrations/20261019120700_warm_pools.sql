@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE mcp_server_images
+ADD COLUMN warm_pool JSON NOT NULL DEFAULT '{"size":0}';
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE mcp_server_images
+DROP COLUMN warm_pool;
+
+-- +goose StatementEnd
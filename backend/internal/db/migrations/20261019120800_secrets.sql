@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Values are encrypted with SECRETS_KEY, env schemas use them as $secret.<name>
+CREATE TABLE secrets (
+  name TEXT PRIMARY KEY,
+  value BLOB NOT NULL,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS secrets;
+
+-- +goose StatementEnd
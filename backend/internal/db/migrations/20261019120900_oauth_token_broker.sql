@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Where the API exchanges refresh tokens for access tokens
+ALTER TABLE oauth_providers
+ADD COLUMN token_url TEXT;
+
+UPDATE oauth_providers
+SET
+  token_url = 'https://oauth2.googleapis.com/token'
+WHERE
+  name IN ('google-calendar', 'google-signin');
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE oauth_providers
+DROP COLUMN token_url;
+
+-- +goose StatementEnd
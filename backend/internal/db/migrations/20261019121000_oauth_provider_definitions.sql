@@ -0,0 +1,43 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Providers are data (see manifests/oauth-providers), kind is the goth provider they're built with
+ALTER TABLE oauth_providers
+ADD COLUMN kind TEXT NOT NULL DEFAULT 'GOOGLE';
+
+ALTER TABLE oauth_providers
+ADD COLUMN auth_url TEXT;
+
+ALTER TABLE oauth_providers
+ADD COLUMN user_info_url TEXT;
+
+ALTER TABLE oauth_providers
+ADD COLUMN pkce BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE oauth_providers
+ADD COLUMN auth_params JSON NOT NULL DEFAULT '{}';
+
+UPDATE oauth_providers
+SET
+  auth_params = '{"access_type":"offline","prompt":"consent"}'
+WHERE
+  name IN ('google-calendar', 'google-signin');
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE oauth_providers
+DROP COLUMN auth_params;
+
+ALTER TABLE oauth_providers
+DROP COLUMN pkce;
+
+ALTER TABLE oauth_providers
+DROP COLUMN user_info_url;
+
+ALTER TABLE oauth_providers
+DROP COLUMN auth_url;
+
+ALTER TABLE oauth_providers
+DROP COLUMN kind;
+
+-- +goose StatementEnd
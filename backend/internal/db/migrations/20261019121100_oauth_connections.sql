@@ -0,0 +1,48 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Who a token was granted by and for what, shown in GET /connections
+ALTER TABLE oauth_tokens
+ADD COLUMN account TEXT;
+
+ALTER TABLE oauth_tokens
+ADD COLUMN scopes TEXT;
+
+ALTER TABLE oauth_tokens
+ADD COLUMN created_at DATETIME;
+
+ALTER TABLE oauth_tokens
+ADD COLUMN last_refreshed_at DATETIME;
+
+UPDATE oauth_tokens
+SET
+  created_at = CURRENT_TIMESTAMP;
+
+-- RFC 7009 endpoint tokens are revoked at when a connection is deleted
+ALTER TABLE oauth_providers
+ADD COLUMN revoke_url TEXT;
+
+UPDATE oauth_providers
+SET
+  revoke_url = 'https://oauth2.googleapis.com/revoke'
+WHERE
+  kind = 'GOOGLE';
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE oauth_providers
+DROP COLUMN revoke_url;
+
+ALTER TABLE oauth_tokens
+DROP COLUMN last_refreshed_at;
+
+ALTER TABLE oauth_tokens
+DROP COLUMN created_at;
+
+ALTER TABLE oauth_tokens
+DROP COLUMN scopes;
+
+ALTER TABLE oauth_tokens
+DROP COLUMN account;
+
+-- +goose StatementEnd
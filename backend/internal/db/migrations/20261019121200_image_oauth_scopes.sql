@@ -0,0 +1,46 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Scopes an image needs from its oauth provider, requested incrementally when an instance is created
+ALTER TABLE mcp_server_images
+ADD COLUMN oauth_scopes JSON NOT NULL DEFAULT '[]';
+
+UPDATE mcp_server_images
+SET
+  oauth_scopes = '["https://www.googleapis.com/auth/calendar"]'
+WHERE
+  oauth_provider = 'google-calendar';
+
+-- Connections made before scopes were recorded were granted everything their provider asked for
+UPDATE oauth_tokens
+SET
+  scopes = (
+    SELECT
+      scopes
+    FROM
+      oauth_providers
+    WHERE
+      oauth_providers.name = oauth_tokens.oauth_provider
+  )
+WHERE
+  scopes IS NULL;
+
+-- Providers only ask for sign in scopes now, images ask for the rest
+UPDATE oauth_providers
+SET
+  scopes = '["email","profile"]'
+WHERE
+  name = 'google-calendar';
+
+UPDATE oauth_providers
+SET
+  scopes = '["openid","email","profile"]'
+WHERE
+  name = 'google-signin';
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE mcp_server_images
+DROP COLUMN oauth_scopes;
+
+-- +goose StatementEnd
@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Users sign in with the google-signin provider, email identifies them
+CREATE TABLE users (
+  id TEXT PRIMARY KEY,
+  email TEXT UNIQUE NOT NULL,
+  name TEXT,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  last_login_at DATETIME
+);
+
+-- Rows from before users existed have no owner, the first user to sign in gets them
+ALTER TABLE chats
+ADD COLUMN user_id TEXT;
+
+ALTER TABLE mcp_server_instances
+ADD COLUMN user_id TEXT;
+
+ALTER TABLE oauth_tokens
+ADD COLUMN user_id TEXT;
+
+-- Each user has one connection per provider
+CREATE UNIQUE INDEX oauth_tokens_user_provider ON oauth_tokens (user_id, oauth_provider);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX oauth_tokens_user_provider;
+
+ALTER TABLE oauth_tokens
+DROP COLUMN user_id;
+
+ALTER TABLE mcp_server_instances
+DROP COLUMN user_id;
+
+ALTER TABLE chats
+DROP COLUMN user_id;
+
+DROP TABLE users;
+
+-- +goose StatementEnd
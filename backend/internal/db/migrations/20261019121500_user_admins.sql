@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Only admins can change what every user shares (images, secrets)
+ALTER TABLE users
+ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- The first user already owns everything from before there were users
+UPDATE users
+SET
+  is_admin = TRUE
+WHERE
+  id = (
+    SELECT
+      id
+    FROM
+      users
+    ORDER BY
+      created_at,
+      rowid
+    LIMIT
+      1
+  );
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+DROP COLUMN is_admin;
+
+-- +goose StatementEnd
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// Scopes of an API key
+type APIKeyScopes []APIKeyScope
+
+// Whether the scopes allow scope. ADMIN allows every scope
+func (s APIKeyScopes) Allows(scope APIKeyScope) bool {
+	return slices.Contains(s, APIKeyScopeAdmin) || slices.Contains(s, scope)
+}
+
+func (s APIKeyScopes) Value() (driver.Value, error) {
+	if s == nil {
+		return json.Marshal([]APIKeyScope{})
+	}
+	return json.Marshal([]APIKeyScope(s))
+}
+
+func (s *APIKeyScopes) Scan(value any) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	default:
+		return fmt.Errorf("expected []byte for APIKeyScopes, got %T", value)
+	}
+}
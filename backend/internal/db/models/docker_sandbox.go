@@ -0,0 +1,159 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var InvalidDockerSandboxError = errors.New("Invalid docker sandbox")
+
+// Default network and proxy for EGRESS_ALLOWLIST containers. Create them with `make setup-egress-network`
+const (
+	DEFAULT_EGRESS_NETWORK = "xtrn-egress"
+	DEFAULT_EGRESS_PROXY   = "http://xtrn-egress-proxy:3128"
+)
+
+// Label holding an EGRESS_ALLOWLIST container's allowed hosts (comma separated), read by the egress proxy
+const EGRESS_ALLOWED_HOSTS_LABEL = "xtrn.egress.allowed-hosts"
+
+/*
+Network a DOCKER launcher's container joins.
+
+BRIDGE           = docker's default network (default)
+NONE             = no network access
+EGRESS_ALLOWLIST = dedicated internal docker network (Name, default DEFAULT_EGRESS_NETWORK). The only way
+out is the egress proxy (Proxy, default DEFAULT_EGRESS_PROXY) which only connects to AllowedHosts.
+Hosts are `example.com`, `*.example.com` (subdomains only) with an optional `:port` (any port if omitted)
+*/
+type DockerNetwork struct {
+	Mode         DockerNetworkMode `json:"mode,omitempty" yaml:"mode,omitempty"`
+	Name         string            `json:"name,omitempty" yaml:"name,omitempty"`
+	AllowedHosts []string          `json:"allowed_hosts,omitempty" yaml:"allowed_hosts,omitempty"`
+	Proxy        string            `json:"proxy,omitempty" yaml:"proxy,omitempty"`
+}
+
+func (n DockerNetwork) NetworkName() string {
+	if n.Name != "" {
+		return n.Name
+	}
+
+	return DEFAULT_EGRESS_NETWORK
+}
+
+func (n DockerNetwork) ProxyURL() string {
+	if n.Proxy != "" {
+		return n.Proxy
+	}
+
+	return DEFAULT_EGRESS_PROXY
+}
+
+var egressHostRegex = regexp.MustCompile(`^(\*\.)?[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?)*(:[0-9]{1,5})?$`)
+
+// Whether host (and port) matches one of the allowed hosts
+func EgressHostAllowed(allowed []string, host string, port string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	for _, a := range allowed {
+		pattern, allowedPort, err := net.SplitHostPort(a)
+		if err != nil {
+			pattern, allowedPort = a, ""
+		}
+		pattern = strings.ToLower(pattern)
+
+		if allowedPort != "" && allowedPort != port {
+			continue
+		}
+
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+			if strings.HasSuffix(host, suffix) && host != suffix[1:] {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+Resource limits and hardening for a DOCKER launcher's container, translated into docker run flags.
+
+Memory    = --memory e.g. 512m, 1g
+CPUs      = --cpus e.g. 0.5
+PidsLimit = --pids-limit
+ReadOnly  = --read-only with a tmpfs at /tmp for servers that need scratch space
+CapDrop   = --cap-drop e.g. ALL, NET_RAW
+*/
+type DockerSandbox struct {
+	Memory    string        `json:"memory,omitempty" yaml:"memory,omitempty"`
+	CPUs      float64       `json:"cpus,omitempty" yaml:"cpus,omitempty"`
+	PidsLimit int           `json:"pids_limit,omitempty" yaml:"pids_limit,omitempty"`
+	ReadOnly  bool          `json:"read_only,omitempty" yaml:"read_only,omitempty"`
+	CapDrop   []string      `json:"cap_drop,omitempty" yaml:"cap_drop,omitempty"`
+	Network   DockerNetwork `json:"network,omitempty" yaml:"network,omitempty"`
+}
+
+var dockerMemoryRegex = regexp.MustCompile(`^[0-9]+[bkmgBKMG]?$`)
+var dockerCapabilityRegex = regexp.MustCompile(`^[A-Z][A-Z_]*$`)
+var dockerNetworkNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+func (s DockerSandbox) Validate() error {
+	if s.Memory != "" && !dockerMemoryRegex.MatchString(s.Memory) {
+		return fmt.Errorf("%w: memory `%s` must be a number with an optional b, k, m or g unit", InvalidDockerSandboxError, s.Memory)
+	}
+
+	if s.CPUs < 0 {
+		return fmt.Errorf("%w: cpus must not be negative", InvalidDockerSandboxError)
+	}
+
+	if s.PidsLimit < 0 {
+		return fmt.Errorf("%w: pids_limit must not be negative", InvalidDockerSandboxError)
+	}
+
+	for _, c := range s.CapDrop {
+		if !dockerCapabilityRegex.MatchString(c) {
+			return fmt.Errorf("%w: invalid capability `%s` in cap_drop", InvalidDockerSandboxError, c)
+		}
+	}
+
+	if s.Network.Mode != "" && !s.Network.Mode.IsValid() {
+		return fmt.Errorf("%w - %w: %s", InvalidDockerSandboxError, InvalidDockerNetworkModeError, s.Network.Mode)
+	}
+
+	if s.Network.Mode != DockerNetworkModeEgressAllowlist {
+		if s.Network.Name != "" || len(s.Network.AllowedHosts) > 0 || s.Network.Proxy != "" {
+			return fmt.Errorf("%w: network name, allowed_hosts and proxy are only used by %s networks", InvalidDockerSandboxError, DockerNetworkModeEgressAllowlist)
+		}
+
+		return nil
+	}
+
+	if s.Network.Name != "" && !dockerNetworkNameRegex.MatchString(s.Network.Name) {
+		return fmt.Errorf("%w: invalid network name `%s`", InvalidDockerSandboxError, s.Network.Name)
+	}
+
+	if len(s.Network.AllowedHosts) == 0 {
+		return fmt.Errorf("%w: %s networks need allowed_hosts", InvalidDockerSandboxError, DockerNetworkModeEgressAllowlist)
+	}
+
+	for _, h := range s.Network.AllowedHosts {
+		if !egressHostRegex.MatchString(h) {
+			return fmt.Errorf("%w: invalid allowed host `%s`", InvalidDockerSandboxError, h)
+		}
+	}
+
+	if s.Network.Proxy != "" {
+		u, err := url.Parse(s.Network.Proxy)
+		if err != nil || u.Scheme != "http" || u.Host == "" {
+			return fmt.Errorf("%w: proxy `%s` must be an http URL", InvalidDockerSandboxError, s.Network.Proxy)
+		}
+	}
+
+	return nil
+}
@@ -122,3 +122,369 @@ func (t *MessageType) UnmarshalJSON(data []byte) error {
 	*t = tmp
 	return nil
 }
+
+// ---------- MCPLauncherType ----------
+
+var InvalidMCPLauncherTypeError = errors.New("Invalid launcher type")
+
+type MCPLauncherType string
+
+const (
+	MCPLauncherTypeDocker     MCPLauncherType = "DOCKER"
+	MCPLauncherTypeCommand    MCPLauncherType = "COMMAND"
+	MCPLauncherTypeEmbedded   MCPLauncherType = "EMBEDDED"
+	MCPLauncherTypeRemoteSSE  MCPLauncherType = "REMOTE_SSE"
+	MCPLauncherTypeRemoteHTTP MCPLauncherType = "REMOTE_HTTP"
+)
+
+func (t MCPLauncherType) IsValid() bool {
+	switch t {
+	case MCPLauncherTypeDocker, MCPLauncherTypeCommand, MCPLauncherTypeEmbedded, MCPLauncherTypeRemoteSSE, MCPLauncherTypeRemoteHTTP:
+		return true
+	}
+	return false
+}
+
+func (t MCPLauncherType) MarshalJSON() ([]byte, error) {
+	if !t.IsValid() {
+		return nil, InvalidMCPLauncherTypeError
+	}
+	return json.Marshal(string(t))
+}
+
+func (t *MCPLauncherType) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	tmp := MCPLauncherType(str)
+	if !tmp.IsValid() {
+		return fmt.Errorf("%w: %s", InvalidMCPLauncherTypeError, str)
+	}
+	*t = tmp
+	return nil
+}
+
+// ---------- ToolCallResultStatus ----------
+
+var InvalidToolCallResultStatusError = errors.New("Invalid tool call result status")
+
+type ToolCallResultStatus string
+
+const (
+	ToolCallResultStatusOK           ToolCallResultStatus = "OK"
+	ToolCallResultStatusError        ToolCallResultStatus = "ERROR"
+	ToolCallResultStatusTimeout      ToolCallResultStatus = "TIMEOUT"
+	ToolCallResultStatusUnauthorized ToolCallResultStatus = "UNAUTHORIZED"
+)
+
+func (s ToolCallResultStatus) IsValid() bool {
+	switch s {
+	case ToolCallResultStatusOK, ToolCallResultStatusError, ToolCallResultStatusTimeout, ToolCallResultStatusUnauthorized:
+		return true
+	}
+	return false
+}
+
+func (s ToolCallResultStatus) MarshalJSON() ([]byte, error) {
+	if !s.IsValid() {
+		return nil, InvalidToolCallResultStatusError
+	}
+	return json.Marshal(string(s))
+}
+
+func (s *ToolCallResultStatus) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	tmp := ToolCallResultStatus(str)
+	if !tmp.IsValid() {
+		return fmt.Errorf("%w: %s", InvalidToolCallResultStatusError, str)
+	}
+	*s = tmp
+	return nil
+}
+
+// ---------- SamplingRequestStatus ----------
+
+var InvalidSamplingRequestStatusError = errors.New("Invalid sampling request status")
+
+type SamplingRequestStatus string
+
+const (
+	SamplingRequestStatusCompleted SamplingRequestStatus = "COMPLETED"
+	SamplingRequestStatusDenied    SamplingRequestStatus = "DENIED"
+	SamplingRequestStatusFailed    SamplingRequestStatus = "FAILED"
+)
+
+func (s SamplingRequestStatus) IsValid() bool {
+	switch s {
+	case SamplingRequestStatusCompleted, SamplingRequestStatusDenied, SamplingRequestStatusFailed:
+		return true
+	}
+	return false
+}
+
+func (s SamplingRequestStatus) MarshalJSON() ([]byte, error) {
+	if !s.IsValid() {
+		return nil, InvalidSamplingRequestStatusError
+	}
+	return json.Marshal(string(s))
+}
+
+func (s *SamplingRequestStatus) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	tmp := SamplingRequestStatus(str)
+	if !tmp.IsValid() {
+		return fmt.Errorf("%w: %s", InvalidSamplingRequestStatusError, str)
+	}
+	*s = tmp
+	return nil
+}
+
+// ---------- DockerNetworkMode ----------
+
+var InvalidDockerNetworkModeError = errors.New("Invalid docker network mode")
+
+type DockerNetworkMode string
+
+const (
+	DockerNetworkModeBridge          DockerNetworkMode = "BRIDGE"
+	DockerNetworkModeNone            DockerNetworkMode = "NONE"
+	DockerNetworkModeEgressAllowlist DockerNetworkMode = "EGRESS_ALLOWLIST"
+)
+
+func (m DockerNetworkMode) IsValid() bool {
+	switch m {
+	case DockerNetworkModeBridge, DockerNetworkModeNone, DockerNetworkModeEgressAllowlist:
+		return true
+	}
+	return false
+}
+
+func (m DockerNetworkMode) MarshalJSON() ([]byte, error) {
+	if !m.IsValid() {
+		return nil, InvalidDockerNetworkModeError
+	}
+	return json.Marshal(string(m))
+}
+
+func (m *DockerNetworkMode) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	tmp := DockerNetworkMode(str)
+	if !tmp.IsValid() {
+		return fmt.Errorf("%w: %s", InvalidDockerNetworkModeError, str)
+	}
+	*m = tmp
+	return nil
+}
+
+// ---------- MCPInstanceState ----------
+
+var InvalidMCPInstanceStateError = errors.New("Invalid MCP instance state")
+
+type MCPInstanceState string
+
+const (
+	MCPInstanceStateRunning MCPInstanceState = "RUNNING"
+	MCPInstanceStateStopped MCPInstanceState = "STOPPED"
+)
+
+func (s MCPInstanceState) IsValid() bool {
+	switch s {
+	case MCPInstanceStateRunning, MCPInstanceStateStopped:
+		return true
+	}
+	return false
+}
+
+func (s MCPInstanceState) MarshalJSON() ([]byte, error) {
+	if !s.IsValid() {
+		return nil, InvalidMCPInstanceStateError
+	}
+	return json.Marshal(string(s))
+}
+
+func (s *MCPInstanceState) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	tmp := MCPInstanceState(str)
+	if !tmp.IsValid() {
+		return fmt.Errorf("%w: %s", InvalidMCPInstanceStateError, str)
+	}
+	*s = tmp
+	return nil
+}
+
+// ---------- WarmPoolMode ----------
+
+var InvalidWarmPoolModeError = errors.New("Invalid warm pool mode")
+
+type WarmPoolMode string
+
+const (
+	WarmPoolModeExclusive WarmPoolMode = "EXCLUSIVE"
+	WarmPoolModeShared    WarmPoolMode = "SHARED"
+)
+
+func (m WarmPoolMode) IsValid() bool {
+	switch m {
+	case WarmPoolModeExclusive, WarmPoolModeShared:
+		return true
+	}
+	return false
+}
+
+func (m WarmPoolMode) MarshalJSON() ([]byte, error) {
+	if !m.IsValid() {
+		return nil, InvalidWarmPoolModeError
+	}
+	return json.Marshal(string(m))
+}
+
+func (m *WarmPoolMode) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	tmp := WarmPoolMode(str)
+	if !tmp.IsValid() {
+		return fmt.Errorf("%w: %s", InvalidWarmPoolModeError, str)
+	}
+	*m = tmp
+	return nil
+}
+
+// ---------- EnvVarType ----------
+
+var InvalidEnvVarTypeError = errors.New("Invalid env var type")
+
+type EnvVarType string
+
+const (
+	EnvVarTypeString  EnvVarType = "STRING"
+	EnvVarTypeNumber  EnvVarType = "NUMBER"
+	EnvVarTypeInteger EnvVarType = "INTEGER"
+	EnvVarTypeBoolean EnvVarType = "BOOLEAN"
+)
+
+func (t EnvVarType) IsValid() bool {
+	switch t {
+	case EnvVarTypeString, EnvVarTypeNumber, EnvVarTypeInteger, EnvVarTypeBoolean:
+		return true
+	}
+	return false
+}
+
+func (t EnvVarType) MarshalJSON() ([]byte, error) {
+	if !t.IsValid() {
+		return nil, InvalidEnvVarTypeError
+	}
+	return json.Marshal(string(t))
+}
+
+func (t *EnvVarType) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	tmp := EnvVarType(str)
+	if !tmp.IsValid() {
+		return fmt.Errorf("%w: %s", InvalidEnvVarTypeError, str)
+	}
+	*t = tmp
+	return nil
+}
+
+// ---------- OauthProviderKind ----------
+
+var InvalidOauthProviderKindError = errors.New("Invalid oauth provider kind")
+
+// Goth provider an oauth provider is built with. OAUTH2 is any provider with standard endpoints
+type OauthProviderKind string
+
+const (
+	OauthProviderKindGoogle OauthProviderKind = "GOOGLE"
+	OauthProviderKindGithub OauthProviderKind = "GITHUB"
+	OauthProviderKindSlack  OauthProviderKind = "SLACK"
+	OauthProviderKindOauth2 OauthProviderKind = "OAUTH2"
+)
+
+func (k OauthProviderKind) IsValid() bool {
+	switch k {
+	case OauthProviderKindGoogle, OauthProviderKindGithub, OauthProviderKindSlack, OauthProviderKindOauth2:
+		return true
+	}
+	return false
+}
+
+func (k OauthProviderKind) MarshalJSON() ([]byte, error) {
+	if !k.IsValid() {
+		return nil, InvalidOauthProviderKindError
+	}
+	return json.Marshal(string(k))
+}
+
+func (k *OauthProviderKind) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	tmp := OauthProviderKind(str)
+	if !tmp.IsValid() {
+		return fmt.Errorf("%w: %s", InvalidOauthProviderKindError, str)
+	}
+	*k = tmp
+	return nil
+}
+
+// ---------- APIKeyScope ----------
+
+var InvalidAPIKeyScopeError = errors.New("Invalid API key scope")
+
+// What an API key can be used for. ADMIN can do everything, like a signed in user
+type APIKeyScope string
+
+const (
+	APIKeyScopeChat           APIKeyScope = "chat"
+	APIKeyScopeInstancesRead  APIKeyScope = "instances:read"
+	APIKeyScopeInstancesWrite APIKeyScope = "instances:write"
+	APIKeyScopeAdmin          APIKeyScope = "admin"
+)
+
+func (s APIKeyScope) IsValid() bool {
+	switch s {
+	case APIKeyScopeChat, APIKeyScopeInstancesRead, APIKeyScopeInstancesWrite, APIKeyScopeAdmin:
+		return true
+	}
+	return false
+}
+
+func (s APIKeyScope) MarshalJSON() ([]byte, error) {
+	if !s.IsValid() {
+		return nil, InvalidAPIKeyScopeError
+	}
+	return json.Marshal(string(s))
+}
+
+func (s *APIKeyScope) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	tmp := APIKeyScope(str)
+	if !tmp.IsValid() {
+		return fmt.Errorf("%w: %s", InvalidAPIKeyScopeError, str)
+	}
+	*s = tmp
+	return nil
+}
@@ -3,10 +3,355 @@ package models
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var InvalidEnvSchemaError = errors.New("Invalid env schema")
+var InvalidUserEnvError = errors.New("Invalid env")
+
+/*
+Values xtrn fills in for an env var, users can't set them. Sources are `$<namespace>.<name>`
+(e.g. $secret.github_token), the namespaces are resolved by the instance env resolvers.
+*/
+const (
+	EnvSourceProviderClientID     = "$provider.oauth_client_id"
+	EnvSourceProviderClientSecret = "$provider.oauth_client_secret"
+	EnvSourceUserRefreshToken     = "$user.oauth_refresh_token"
+	EnvSourceUserAccessToken      = "$user.oauth_access_token"
+	EnvSourceInstanceID           = "$instance.id"
+	EnvSourceBrokerTokenURL       = "$broker.token_url"
+	EnvSourceBrokerTokenKey       = "$broker.token_key"
 )
 
-type EnvSchema map[string]string
+var envSourceRegex = regexp.MustCompile(`^\$([a-z_]+)\.([A-Za-z0-9_.-]+)$`)
+
+// Splits a source into its namespace and name
+func ParseEnvSource(source string) (string, string, error) {
+	m := envSourceRegex.FindStringSubmatch(source)
+	if m == nil {
+		return "", "", fmt.Errorf("source `%s` must look like $<namespace>.<name>", source)
+	}
+
+	return m[1], m[2], nil
+}
+
+/*
+One env var of an image. Values are always passed to the MCP server as strings, Type is what
+they have to parse as. Vars with a Source are filled in by xtrn, the rest come from the user.
+*/
+type EnvVar struct {
+	Type        EnvVarType `json:"type,omitempty" yaml:"type,omitempty"` // Defaults to STRING
+	Description string     `json:"description,omitempty" yaml:"description,omitempty"`
+	Required    bool       `json:"required,omitempty" yaml:"required,omitempty"`
+	Secret      bool       `json:"secret,omitempty" yaml:"secret,omitempty"` // Redacted from logs and rendered as a password field
+	Default     string     `json:"default,omitempty" yaml:"default,omitempty"`
+	Enum        []string   `json:"enum,omitempty" yaml:"enum,omitempty"`
+	Source      string     `json:"source,omitempty" yaml:"source,omitempty"`
+}
+
+/*
+Schemas used to be a map of key -> template, where "" meant the user sets the value.
+Those still parse: a template becomes the Source and "" a required string.
+*/
+func legacyEnvVar(v string) EnvVar {
+	if v == "" {
+		return EnvVar{Type: EnvVarTypeString, Required: true}
+	}
+
+	return EnvVar{Type: EnvVarTypeString, Source: v}
+}
+
+func (v *EnvVar) UnmarshalJSON(data []byte) error {
+	var legacy string
+	if err := json.Unmarshal(data, &legacy); err == nil {
+		*v = legacyEnvVar(legacy)
+		return nil
+	}
+
+	type envVar EnvVar
+	tmp := envVar{}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+
+	*v = EnvVar(tmp)
+	if v.Type == "" {
+		v.Type = EnvVarTypeString
+	}
+
+	return nil
+}
+
+func (v *EnvVar) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*v = legacyEnvVar(node.Value)
+		return nil
+	}
+
+	type envVar EnvVar
+	tmp := envVar{}
+	if err := node.Decode(&tmp); err != nil {
+		return err
+	}
+
+	*v = EnvVar(tmp)
+	if v.Type == "" {
+		v.Type = EnvVarTypeString
+	}
+
+	return nil
+}
+
+// Users set the value (it has no Source)
+func (v EnvVar) IsUserSet() bool {
+	return v.Source == ""
+}
+
+func (v EnvVar) varType() EnvVarType {
+	if v.Type == "" {
+		return EnvVarTypeString
+	}
+
+	return v.Type
+}
+
+// Checks value parses as the var's type and is one of its allowed values
+func (v EnvVar) check(value string) error {
+	switch v.varType() {
+	case EnvVarTypeNumber:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("`%s` is not a number", value)
+		}
+	case EnvVarTypeInteger:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("`%s` is not an integer", value)
+		}
+	case EnvVarTypeBoolean:
+		if value != "true" && value != "false" {
+			return fmt.Errorf("`%s` is not true or false", value)
+		}
+	}
+
+	if len(v.Enum) > 0 && !slices.Contains(v.Enum, value) {
+		return fmt.Errorf("`%s` is not one of %s", value, strings.Join(v.Enum, ", "))
+	}
+
+	return nil
+}
+
+// Value the image is started with when its tools are discovered
+func (v EnvVar) Placeholder() string {
+	if v.Default != "" {
+		return v.Default
+	}
+
+	if len(v.Enum) > 0 {
+		return v.Enum[0]
+	}
+
+	switch v.varType() {
+	case EnvVarTypeNumber, EnvVarTypeInteger:
+		return "0"
+	case EnvVarTypeBoolean:
+		return "false"
+	}
+
+	return "abc"
+}
+
+type EnvSchema map[string]EnvVar
+
+func (s EnvSchema) keys() []string {
+	keys := []string{}
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Reports every problem with the schema at once
+func (s EnvSchema) Validate() error {
+	errs := []error{}
+
+	for _, k := range s.keys() {
+		v := s[k]
+
+		if k == "" {
+			errs = append(errs, errors.New("keys must not be empty"))
+		}
+
+		if !v.varType().IsValid() {
+			errs = append(errs, fmt.Errorf("`%s`: %w: %s", k, InvalidEnvVarTypeError, v.Type))
+			continue
+		}
+
+		if v.Source != "" {
+			if _, _, err := ParseEnvSource(v.Source); err != nil {
+				errs = append(errs, fmt.Errorf("`%s`: %w", k, err))
+			}
+
+			if v.Required || v.Default != "" || len(v.Enum) > 0 {
+				errs = append(errs, fmt.Errorf("`%s`: vars with a source can't be required or have a default or enum", k))
+			}
+
+			continue
+		}
+
+		for _, e := range v.Enum {
+			if err := v.check(e); err != nil {
+				errs = append(errs, fmt.Errorf("`%s`: enum value %w", k, err))
+			}
+		}
+
+		if v.Default != "" {
+			if err := v.check(v.Default); err != nil {
+				errs = append(errs, fmt.Errorf("`%s`: default %w", k, err))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w - %w", InvalidEnvSchemaError, errors.Join(errs...))
+	}
+
+	return nil
+}
+
+/*
+Checks the env a user gave for an instance against the schema and fills in defaults. Every
+problem is reported at once. Vars with a Source aren't in the result, they're resolved separately.
+*/
+func (s EnvSchema) ValidateUserEnv(userEnv map[string]string) (map[string]string, error) {
+	errs := []error{}
+	env := map[string]string{}
+
+	for _, k := range s.keys() {
+		v := s[k]
+
+		if !v.IsUserSet() {
+			if _, ok := userEnv[k]; ok {
+				errs = append(errs, fmt.Errorf("`%s` is set by xtrn (%s) and can't be given", k, v.Source))
+			}
+			continue
+		}
+
+		value, ok := userEnv[k]
+		if !ok || value == "" {
+			if v.Default != "" {
+				env[k] = v.Default
+			} else if v.Required {
+				errs = append(errs, fmt.Errorf("`%s` is required", k))
+			}
+			continue
+		}
+
+		if err := v.check(value); err != nil {
+			errs = append(errs, fmt.Errorf("`%s`: %w", k, err))
+			continue
+		}
+
+		env[k] = value
+	}
+
+	unknown := []string{}
+	for k := range userEnv {
+		if _, ok := s[k]; !ok {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+
+	for _, k := range unknown {
+		errs = append(errs, fmt.Errorf("`%s` is not in the image's env schema", k))
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%w - %w", InvalidUserEnvError, errors.Join(errs...))
+	}
+
+	return env, nil
+}
+
+func jsonSchemaValue(t EnvVarType, value string) any {
+	switch t {
+	case EnvVarTypeNumber:
+		f, _ := strconv.ParseFloat(value, 64)
+		return f
+	case EnvVarTypeInteger:
+		i, _ := strconv.ParseInt(value, 10, 64)
+		return i
+	case EnvVarTypeBoolean:
+		return value == "true"
+	}
+
+	return value
+}
+
+/*
+JSON Schema (draft 2020-12) of the env a user gives when creating an instance, for rendering the
+instance creation form. Vars with a Source are left out, secret vars are writeOnly password fields.
+*/
+func (s EnvSchema) JSONSchema() map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+
+	for _, k := range s.keys() {
+		v := s[k]
+		if !v.IsUserSet() {
+			continue
+		}
+
+		t := v.varType()
+		prop := map[string]any{
+			"type": strings.ToLower(string(t)),
+		}
+
+		if v.Description != "" {
+			prop["description"] = v.Description
+		}
+
+		if v.Default != "" {
+			prop["default"] = jsonSchemaValue(t, v.Default)
+		}
+
+		if len(v.Enum) > 0 {
+			enum := []any{}
+			for _, e := range v.Enum {
+				enum = append(enum, jsonSchemaValue(t, e))
+			}
+			prop["enum"] = enum
+		}
+
+		if v.Secret {
+			prop["writeOnly"] = true
+			prop["format"] = "password"
+		}
+
+		properties[k] = prop
+
+		if v.Required && v.Default == "" {
+			required = append(required, k)
+		}
+	}
+
+	return map[string]any{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
 
 func (s EnvSchema) Value() (driver.Value, error) {
 	return json.Marshal(s)
@@ -19,3 +364,18 @@ func (s *EnvSchema) Scan(value any) error {
 	}
 	return json.Unmarshal(b, s)
 }
+
+// Env an instance was created with, key -> value
+type InstanceEnv map[string]string
+
+func (e InstanceEnv) Value() (driver.Value, error) {
+	return json.Marshal(e)
+}
+
+func (e *InstanceEnv) Scan(value any) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("expected []byte for InstanceEnv, got %T", value)
+	}
+	return json.Unmarshal(b, e)
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+/*
+How the MCP server for an image is started.
+
+DOCKER   = docker run -i --rm <image> (image comes from mcp_server_images.docker_image), limited by Sandbox
+COMMAND  = local command e.g. npx / uvx / a binary, run with Args in WorkingDir
+EMBEDDED = MCP server compiled into xtrn, looked up by Server name
+REMOTE_SSE / REMOTE_HTTP = hosted MCP server at URL. If BearerTokenEnv is set the instance
+env value with that key is sent as `Authorization: Bearer <value>`. When the key's source is
+$user.oauth_access_token a fresh token is fetched from the token broker for every request
+*/
+type MCPLauncher struct {
+	Type       MCPLauncherType `json:"type" yaml:"type"`
+	Command    string          `json:"command,omitempty" yaml:"command,omitempty"`
+	Args       []string        `json:"args,omitempty" yaml:"args,omitempty"`
+	WorkingDir string          `json:"working_dir,omitempty" yaml:"working_dir,omitempty"`
+	Server     string          `json:"server,omitempty" yaml:"server,omitempty"`
+	Sandbox    *DockerSandbox  `json:"sandbox,omitempty" yaml:"sandbox,omitempty"`
+
+	URL            string `json:"url,omitempty" yaml:"url,omitempty"`
+	BearerTokenEnv string `json:"bearer_token_env,omitempty" yaml:"bearer_token_env,omitempty"`
+}
+
+func (l MCPLauncher) IsRemote() bool {
+	return l.Type == MCPLauncherTypeRemoteSSE || l.Type == MCPLauncherTypeRemoteHTTP
+}
+
+var DockerLauncher = MCPLauncher{Type: MCPLauncherTypeDocker}
+
+func (l MCPLauncher) Validate() error {
+	if l.Sandbox != nil && l.Type != MCPLauncherTypeDocker {
+		return fmt.Errorf("`sandbox` is only supported by %s launchers", MCPLauncherTypeDocker)
+	}
+
+	switch l.Type {
+	case MCPLauncherTypeDocker:
+		if l.Sandbox != nil {
+			return l.Sandbox.Validate()
+		}
+		return nil
+	case MCPLauncherTypeCommand:
+		if l.Command == "" {
+			return errors.New("`command` is required for COMMAND launchers")
+		}
+		return nil
+	case MCPLauncherTypeEmbedded:
+		if l.Server == "" {
+			return errors.New("`server` is required for EMBEDDED launchers")
+		}
+		return nil
+	case MCPLauncherTypeRemoteSSE, MCPLauncherTypeRemoteHTTP:
+		u, err := url.Parse(l.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("`url` must be an http(s) URL for %s launchers", l.Type)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", InvalidMCPLauncherTypeError, l.Type)
+}
+
+func (l MCPLauncher) Value() (driver.Value, error) {
+	return json.Marshal(l)
+}
+
+func (l *MCPLauncher) Scan(value any) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, l)
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	default:
+		return fmt.Errorf("expected []byte for MCPLauncher, got %T", value)
+	}
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Extra query params sent to an oauth provider's auth URL, e.g. access_type=offline
+type OauthAuthParams map[string]string
+
+func (p OauthAuthParams) Value() (driver.Value, error) {
+	if p == nil {
+		return json.Marshal(map[string]string{})
+	}
+	return json.Marshal(map[string]string(p))
+}
+
+func (p *OauthAuthParams) Scan(value any) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	default:
+		return fmt.Errorf("expected []byte for OauthAuthParams, got %T", value)
+	}
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// Scopes an image needs from its oauth provider
+type OauthScopes []string
+
+// Scopes of s that aren't in granted
+func (s OauthScopes) Missing(granted []string) []string {
+	missing := []string{}
+	for _, scope := range s {
+		if !slices.Contains(granted, scope) {
+			missing = append(missing, scope)
+		}
+	}
+
+	return missing
+}
+
+func (s OauthScopes) Value() (driver.Value, error) {
+	if s == nil {
+		return json.Marshal([]string{})
+	}
+	return json.Marshal([]string(s))
+}
+
+func (s *OauthScopes) Scan(value any) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	default:
+		return fmt.Errorf("expected []byte for OauthScopes, got %T", value)
+	}
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+type MCPPromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required"`
+}
+
+type MCPPromptArguments []MCPPromptArgument
+
+func (a MCPPromptArguments) Value() (driver.Value, error) {
+	if a == nil {
+		return json.Marshal([]MCPPromptArgument{})
+	}
+	return json.Marshal([]MCPPromptArgument(a))
+}
+
+func (a *MCPPromptArguments) Scan(value any) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, a)
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	default:
+		return fmt.Errorf("expected []byte for MCPPromptArguments, got %T", value)
+	}
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var InvalidSamplingPolicyError = errors.New("Invalid sampling policy")
+
+/*
+Whether an image's MCP servers may ask our LLM for completions (sampling/createMessage).
+Denied by default. MaxTokens caps the tokens a request can ask for, 0 = no cap
+*/
+type SamplingPolicy struct {
+	Allowed   bool `json:"allowed" yaml:"allowed"`
+	MaxTokens int  `json:"max_tokens,omitempty" yaml:"max_tokens,omitempty"`
+}
+
+func (p SamplingPolicy) Validate() error {
+	if p.MaxTokens < 0 {
+		return fmt.Errorf("%w: max_tokens must not be negative", InvalidSamplingPolicyError)
+	}
+
+	return nil
+}
+
+// Tokens to sample for a request asking for requested tokens
+func (p SamplingPolicy) LimitTokens(requested int) int {
+	if p.MaxTokens > 0 && (requested <= 0 || requested > p.MaxTokens) {
+		return p.MaxTokens
+	}
+
+	return requested
+}
+
+func (p SamplingPolicy) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+func (p *SamplingPolicy) Scan(value any) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	default:
+		return fmt.Errorf("expected []byte for SamplingPolicy, got %T", value)
+	}
+}
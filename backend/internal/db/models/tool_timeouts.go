@@ -0,0 +1,67 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var InvalidToolTimeoutError = errors.New("Invalid tool timeout")
+
+/*
+How long tool calls on an image may run before they are cancelled, in seconds.
+
+Tools   = per tool timeouts, take precedence over the tool's own `timeoutSeconds` annotation
+Default = used for tools without a timeout. 0 means the instance default
+*/
+type ToolTimeouts struct {
+	Default int            `json:"default,omitempty" yaml:"default,omitempty"`
+	Tools   map[string]int `json:"tools,omitempty" yaml:"tools,omitempty"`
+}
+
+func (t ToolTimeouts) IsEmpty() bool {
+	return t.Default == 0 && len(t.Tools) == 0
+}
+
+func (t ToolTimeouts) Validate() error {
+	if t.Default < 0 {
+		return fmt.Errorf("%w: default must not be negative", InvalidToolTimeoutError)
+	}
+
+	for name, s := range t.Tools {
+		if s < 1 {
+			return fmt.Errorf("%w: `%s` must be at least 1 second", InvalidToolTimeoutError, name)
+		}
+	}
+
+	return nil
+}
+
+// Timeout in seconds for a tool. annotated is the tool's annotation value (0 if it has none)
+func (t ToolTimeouts) For(tool string, annotated int) int {
+	if s, ok := t.Tools[tool]; ok {
+		return s
+	}
+
+	if annotated > 0 {
+		return annotated
+	}
+
+	return t.Default
+}
+
+func (t ToolTimeouts) Value() (driver.Value, error) {
+	return json.Marshal(t)
+}
+
+func (t *ToolTimeouts) Scan(value any) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, t)
+	case string:
+		return json.Unmarshal([]byte(v), t)
+	default:
+		return fmt.Errorf("expected []byte for ToolTimeouts, got %T", value)
+	}
+}
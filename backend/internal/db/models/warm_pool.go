@@ -0,0 +1,71 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var InvalidWarmPoolError = errors.New("Invalid warm pool")
+
+/*
+Instances of a public image kept started ahead of time. Disabled when Size is 0.
+
+EXCLUSIVE = Size started instances wait to be handed out as new instances, and are replaced as they are
+SHARED    = Size running instances are shared by every instance of the image, MaxConcurrentCalls caps
+
+	the tool calls each one runs at a time (0 = no cap)
+*/
+type WarmPool struct {
+	Size               int          `json:"size" yaml:"size"`
+	Mode               WarmPoolMode `json:"mode,omitempty" yaml:"mode,omitempty"` // Defaults to EXCLUSIVE
+	MaxConcurrentCalls int          `json:"max_concurrent_calls,omitempty" yaml:"max_concurrent_calls,omitempty"`
+}
+
+func (p WarmPool) Enabled() bool {
+	return p.Size > 0
+}
+
+func (p WarmPool) PoolMode() WarmPoolMode {
+	if p.Mode == "" {
+		return WarmPoolModeExclusive
+	}
+
+	return p.Mode
+}
+
+func (p WarmPool) Validate() error {
+	if p.Size < 0 {
+		return fmt.Errorf("%w: size must not be negative", InvalidWarmPoolError)
+	}
+
+	if p.Mode != "" && !p.Mode.IsValid() {
+		return fmt.Errorf("%w: %s", InvalidWarmPoolModeError, p.Mode)
+	}
+
+	if p.MaxConcurrentCalls < 0 {
+		return fmt.Errorf("%w: max_concurrent_calls must not be negative", InvalidWarmPoolError)
+	}
+
+	if p.MaxConcurrentCalls > 0 && p.PoolMode() != WarmPoolModeShared {
+		return fmt.Errorf("%w: max_concurrent_calls only applies to SHARED pools", InvalidWarmPoolError)
+	}
+
+	return nil
+}
+
+func (p WarmPool) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+func (p *WarmPool) Scan(value any) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	default:
+		return fmt.Errorf("expected []byte for WarmPool, got %T", value)
+	}
+}
@@ -1,24 +1,180 @@
+/***********************************/
+/*
+User queries
+*/
+-- name: InsertUser :exec
+INSERT INTO
+  users (id, email, name, last_login_at)
+VALUES
+  (?, ?, ?, CURRENT_TIMESTAMP);
+
+-- name: GetUser :one
+SELECT
+  *
+FROM
+  users
+WHERE
+  id = ?;
+
+-- name: GetUserByEmail :one
+SELECT
+  *
+FROM
+  users
+WHERE
+  email = ?;
+
+-- name: UpdateUserLogin :exec
+UPDATE users
+SET
+  name = ?,
+  last_login_at = CURRENT_TIMESTAMP
+WHERE
+  id = ?;
+
+-- name: SetUserAdmin :exec
+UPDATE users
+SET
+  is_admin = ?
+WHERE
+  id = ?;
+
+-- name: CountUsers :one
+SELECT
+  COUNT(*)
+FROM
+  users;
+
+-- name: ClaimUnownedChats :exec
+UPDATE chats
+SET
+  user_id = ?
+WHERE
+  user_id IS NULL;
+
+-- name: ClaimUnownedMCPServerInstances :exec
+UPDATE mcp_server_instances
+SET
+  user_id = ?
+WHERE
+  user_id IS NULL;
+
+-- name: ClaimUnownedOauthTokens :exec
+UPDATE oauth_tokens
+SET
+  user_id = ?
+WHERE
+  user_id IS NULL;
+
+-- name: InsertAPIKey :exec
+INSERT INTO
+  api_keys (id, user_id, name, prefix, key_hash, scopes, expires_at)
+VALUES
+  (?, ?, ?, ?, ?, ?, ?);
+
+-- name: GetAPIKeys :many
+SELECT
+  *
+FROM
+  api_keys
+WHERE
+  user_id = ?
+ORDER BY
+  created_at DESC;
+
+-- name: GetAPIKey :one
+SELECT
+  *
+FROM
+  api_keys
+WHERE
+  id = ?;
+
+-- name: GetAPIKeyByHash :one
+SELECT
+  *
+FROM
+  api_keys
+WHERE
+  key_hash = ?;
+
+-- name: TouchAPIKey :exec
+UPDATE api_keys
+SET
+  last_used_at = CURRENT_TIMESTAMP
+WHERE
+  id = ?;
+
+-- name: DeleteAPIKey :execrows
+DELETE FROM api_keys
+WHERE
+  id = ?;
+
 /***********************************/
 /*
 OAUTH token queries
 */
--- name: InsertOauthProvider :exec
+-- name: UpsertOauthProvider :exec
 INSERT INTO
   oauth_providers (
     name,
+    kind,
     client_id,
     client_secret,
     callback_url,
-    scopes
+    scopes,
+    auth_url,
+    token_url,
+    user_info_url,
+    pkce,
+    auth_params,
+    revoke_url
   )
 VALUES
-  (?, ?, ?, ?, ?);
+  (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (name) DO
+UPDATE
+SET
+  kind = excluded.kind,
+  client_id = excluded.client_id,
+  client_secret = excluded.client_secret,
+  callback_url = excluded.callback_url,
+  scopes = excluded.scopes,
+  auth_url = excluded.auth_url,
+  token_url = excluded.token_url,
+  user_info_url = excluded.user_info_url,
+  pkce = excluded.pkce,
+  auth_params = excluded.auth_params,
+  revoke_url = excluded.revoke_url;
+
+-- name: GetOauthProvider :one
+SELECT
+  *
+FROM
+  oauth_providers
+WHERE
+  name = ?;
+
+-- name: GetOauthProviders :many
+SELECT
+  *
+FROM
+  oauth_providers
+ORDER BY
+  name;
 
 -- name: InsertOauthToken :exec
 INSERT INTO
-  oauth_tokens (id, refresh_token, oauth_provider)
+  oauth_tokens (
+    id,
+    refresh_token,
+    oauth_provider,
+    account,
+    scopes,
+    user_id,
+    created_at
+  )
 VALUES
-  (?, ?, ?);
+  (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP);
 
 -- name: GetOauthTokenByProvider :one
 SELECT
@@ -26,14 +182,59 @@ SELECT
 FROM
   oauth_tokens
 WHERE
-  oauth_tokens.oauth_provider = ?;
+  oauth_tokens.user_id = ?
+  AND oauth_tokens.oauth_provider = ?;
+
+-- name: GetOauthToken :one
+SELECT
+  *
+FROM
+  oauth_tokens
+WHERE
+  id = ?;
+
+-- name: GetOauthTokens :many
+SELECT
+  *
+FROM
+  oauth_tokens
+WHERE
+  user_id = ?
+ORDER BY
+  created_at;
 
 -- name: UpdateOauthTokenByProivder :exec
 UPDATE oauth_tokens
 SET
   refresh_token = ?
 WHERE
-  oauth_provider = ?;
+  user_id = ?
+  AND oauth_provider = ?;
+
+-- name: ReconnectOauthTokenByProvider :exec
+UPDATE oauth_tokens
+SET
+  refresh_token = ?,
+  account = ?,
+  scopes = ?,
+  created_at = CURRENT_TIMESTAMP,
+  last_refreshed_at = NULL
+WHERE
+  user_id = ?
+  AND oauth_provider = ?;
+
+-- name: MarkOauthTokenRefreshed :exec
+UPDATE oauth_tokens
+SET
+  last_refreshed_at = CURRENT_TIMESTAMP
+WHERE
+  user_id = ?
+  AND oauth_provider = ?;
+
+-- name: DeleteOauthToken :execrows
+DELETE FROM oauth_tokens
+WHERE
+  id = ?;
 
 /***********************************/
 /*
@@ -49,10 +250,15 @@ INSERT INTO
     docker_image,
     type,
     oauth_provider,
-    env_schema
+    env_schema,
+    launcher,
+    tool_timeouts,
+    sampling_policy,
+    warm_pool,
+    oauth_scopes
   )
 VALUES
-  (?, ?, ?, ?, ?, ?, ?, ?);
+  (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
 
 -- name: GetMCPServerImage :one
 SELECT
@@ -66,27 +272,219 @@ FROM
 WHERE
   images.id = ?;
 
+-- name: GetMCPServerImages :many
+SELECT
+  *
+FROM
+  mcp_server_images
+ORDER BY
+  slug,
+  version;
+
+-- name: GetLatestMCPServerImageBySlug :one
+SELECT
+  *
+FROM
+  mcp_server_images
+WHERE
+  slug = ?
+ORDER BY
+  version DESC
+LIMIT
+  1;
+
+-- name: GetMCPServerImageBySlugVersion :one
+SELECT
+  images.*,
+  providers.name as provider_name,
+  providers.client_id,
+  providers.client_secret
+FROM
+  mcp_server_images AS images
+  LEFT JOIN oauth_providers AS providers ON images.oauth_provider = providers.name
+WHERE
+  images.slug = ?
+  AND images.version = ?;
+
+-- name: GetMCPServerToolsByImage :many
+SELECT
+  *
+FROM
+  mcp_server_tools
+WHERE
+  image_id = ?;
+
+-- name: InsertMCPServerResource :exec
+INSERT INTO
+  mcp_server_resources (id, uri, name, description, mime_type, image_id)
+VALUES
+  (?, ?, ?, ?, ?, ?);
+
+-- name: InsertMCPServerResourceTemplate :exec
+INSERT INTO
+  mcp_server_resource_templates (
+    id,
+    uri_template,
+    name,
+    description,
+    mime_type,
+    image_id
+  )
+VALUES
+  (?, ?, ?, ?, ?, ?);
+
+-- name: GetMCPServerResourcesByImage :many
+SELECT
+  *
+FROM
+  mcp_server_resources
+WHERE
+  image_id = ?;
+
+-- name: GetMCPServerResourceTemplatesByImage :many
+SELECT
+  *
+FROM
+  mcp_server_resource_templates
+WHERE
+  image_id = ?;
+
+-- name: InsertMCPServerPrompt :exec
+INSERT INTO
+  mcp_server_prompts (id, name, description, arguments, image_id)
+VALUES
+  (?, ?, ?, ?, ?);
+
+-- name: GetMCPServerPromptsByImage :many
+SELECT
+  *
+FROM
+  mcp_server_prompts
+WHERE
+  image_id = ?;
+
 /***********************************/
 /*
 MCP Server Instance Queries
 */
 -- name: InsertMCPServerInstance :exec
 INSERT INTO
-  mcp_server_instances (id, slug, version, address, env)
+  mcp_server_instances (id, slug, version, address, env, user_id)
 VALUES
-  (?, ?, ?, ?, ?);
+  (?, ?, ?, ?, ?, ?);
 
 -- name: InsertMCPServerInstanceTool :exec
 INSERT INTO
-  mcp_server_tools (id, name, description, schema, image_id)
+  mcp_server_tools (id, name, description, schema, image_id, timeout_seconds)
 VALUES
-  (?, ?, ?, ?, ?);
+  (?, ?, ?, ?, ?, ?);
+
+-- name: GetMCPServerInstance :one
+SELECT
+  *
+FROM
+  mcp_server_instances
+WHERE
+  id = ?;
 
 -- name: DeleteMCPServerInstance :exec
 DELETE FROM mcp_server_instances
 WHERE
   id = ?;
 
+-- name: GetAllMCPServerInstances :many
+SELECT
+  *
+FROM
+  mcp_server_instances;
+
+-- name: UpdateMCPServerInstanceRuntimeState :exec
+UPDATE mcp_server_instances
+SET
+  runtime_state = ?,
+  address = ?
+WHERE
+  id = ?;
+
+-- name: StopMCPServerInstancesByAddress :exec
+UPDATE mcp_server_instances
+SET
+  runtime_state = 'STOPPED',
+  address = ''
+WHERE
+  address = ?;
+
+-- name: UpdateMCPServerInstanceDesiredState :exec
+UPDATE mcp_server_instances
+SET
+  desired_state = ?
+WHERE
+  id = ?;
+
+-- name: UpdateMCPServerInstanceVersion :exec
+UPDATE mcp_server_instances
+SET
+  version = ?,
+  env = ?
+WHERE
+  id = ?;
+
+-- name: TouchMCPServerInstance :exec
+UPDATE mcp_server_instances
+SET
+  last_used_at = CURRENT_TIMESTAMP
+WHERE
+  id = ?;
+
+-- name: GetMCPServerInstancesByOauthProvider :many
+SELECT
+  inst.*
+FROM
+  mcp_server_instances inst
+  JOIN mcp_server_images img ON inst.slug = img.slug
+  AND inst.version = img.version
+WHERE
+  img.oauth_provider = ?
+  AND inst.user_id = ?
+ORDER BY
+  inst.id;
+
+-- name: GetOauthScopesInUseByProvider :many
+SELECT DISTINCT
+  img.oauth_scopes
+FROM
+  mcp_server_instances inst
+  JOIN mcp_server_images img ON inst.slug = img.slug
+  AND inst.version = img.version
+WHERE
+  img.oauth_provider = ?
+  AND inst.user_id = ?;
+
+-- name: GetIdleMCPServerInstances :many
+SELECT
+  *
+FROM
+  mcp_server_instances
+WHERE
+  runtime_state = 'RUNNING'
+  AND COALESCE(last_used_at, created_at) < datetime('now', sqlc.arg(idle_for));
+
+-- name: InsertMCPServerInstanceColdStart :exec
+INSERT INTO
+  mcp_server_instance_cold_starts (id, instance_id, duration_ms, error)
+VALUES
+  (?, ?, ?, ?);
+
+-- name: GetMCPServerInstanceColdStarts :many
+SELECT
+  *
+FROM
+  mcp_server_instance_cold_starts
+WHERE
+  instance_id = ?
+ORDER BY
+  created_at DESC;
+
 -- name: GetMCPServerInstances :many
 SELECT
   inst.id as instance_id,
@@ -99,20 +497,139 @@ FROM
   mcp_server_instances inst
   LEFT JOIN mcp_server_images AS img ON inst.slug = img.slug
   AND inst.version = img.version
-  LEFT JOIN mcp_server_tools as tool ON img.id = tool.image_id;
+  LEFT JOIN mcp_server_tools as tool ON img.id = tool.image_id
+WHERE
+  inst.desired_state = 'RUNNING'
+  AND inst.user_id = ?;
+
+-- name: UpsertMCPServerInstanceTools :exec
+INSERT INTO
+  mcp_server_instance_tools (instance_id, tools)
+VALUES
+  (?, ?) ON CONFLICT (instance_id) DO
+UPDATE
+SET
+  tools = excluded.tools,
+  updated_at = CURRENT_TIMESTAMP;
+
+-- name: GetAllMCPServerInstanceTools :many
+SELECT
+  *
+FROM
+  mcp_server_instance_tools;
+
+-- name: GetMCPServerInstanceTools :one
+SELECT
+  *
+FROM
+  mcp_server_instance_tools
+WHERE
+  instance_id = ?;
+
+-- name: DeleteMCPServerInstanceTools :exec
+DELETE FROM mcp_server_instance_tools
+WHERE
+  instance_id = ?;
+
+-- name: GetMCPServerInstancePrompts :many
+SELECT
+  inst.id as instance_id,
+  inst.address,
+  prompt.name as prompt_name,
+  prompt.description as prompt_desc,
+  prompt.arguments as prompt_arguments
+FROM
+  mcp_server_instances inst
+  JOIN mcp_server_images AS img ON inst.slug = img.slug
+  AND inst.version = img.version
+  JOIN mcp_server_prompts as prompt ON img.id = prompt.image_id
+WHERE
+  inst.desired_state = 'RUNNING'
+  AND inst.user_id = ?;
+
+-- name: InsertMCPSamplingRequest :exec
+INSERT INTO
+  mcp_sampling_requests (
+    id,
+    instance_id,
+    image_id,
+    status,
+    model,
+    max_tokens,
+    request,
+    response,
+    error
+  )
+VALUES
+  (?, ?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: GetMCPSamplingRequestsByInstance :many
+SELECT
+  *
+FROM
+  mcp_sampling_requests
+WHERE
+  instance_id = ?
+ORDER BY
+  created_at DESC;
 
 -- name: DeleteAllMCPinstances :exec
 DELETE FROM mcp_server_instances;
 
+/***********************************/
+/*
+Secret Queries
+*/
+-- name: UpsertSecret :exec
+INSERT INTO
+  secrets (name, value)
+VALUES
+  (?, ?) ON CONFLICT (name) DO
+UPDATE
+SET
+  value = excluded.value,
+  updated_at = CURRENT_TIMESTAMP;
+
+-- name: GetSecret :one
+SELECT
+  *
+FROM
+  secrets
+WHERE
+  name = ?;
+
+-- name: GetSecrets :many
+SELECT
+  name,
+  created_at,
+  updated_at
+FROM
+  secrets
+ORDER BY
+  name;
+
+-- name: DeleteSecret :execrows
+DELETE FROM secrets
+WHERE
+  name = ?;
+
 /***********************************/
 /*
 Chat Queries
 */
 -- name: InsertChat :exec
 INSERT INTO
-  chats (id)
+  chats (id, user_id)
 VALUES
-  (?);
+  (?, ?);
+
+-- name: GetChat :one
+SELECT
+  *
+FROM
+  chats
+WHERE
+  id = ?;
 
 -- name: InsertMessage :exec
 INSERT INTO
@@ -140,9 +657,9 @@ VALUES
 
 -- name: InsertToolCallResult :exec
 INSERT INTO
-  tool_call_result (message_id, tool_call_id, name, content, is_error)
+  tool_call_result (message_id, tool_call_id, name, content, is_error, status)
 VALUES
-  (?, ?, ?, ?, ?);
+  (?, ?, ?, ?, ?, ?);
 
 /***********************************/
 -- name: GetViewChatMessges :many
@@ -1,8 +1,41 @@
+/****************************************************/
+/*
+Users sign in with the google-signin provider, email identifies them
+*/
+CREATE TABLE users (
+  id TEXT PRIMARY KEY,
+  email TEXT UNIQUE NOT NULL,
+  name TEXT,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  last_login_at DATETIME,
+  is_admin BOOLEAN NOT NULL DEFAULT FALSE -- Can change images and secrets, which every user shares
+);
+
+/*
+Personal API keys for scripts and CI. Only the sha256 of the key is stored, prefix is the start of
+the key so users can tell keys apart. scopes is a JSON array of models.APIKeyScope
+*/
+CREATE TABLE api_keys (
+  id TEXT PRIMARY KEY,
+  user_id TEXT NOT NULL,
+  name TEXT NOT NULL,
+  prefix TEXT NOT NULL,
+  key_hash TEXT UNIQUE NOT NULL,
+  scopes JSON NOT NULL,
+  expires_at DATETIME,
+  last_used_at DATETIME,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
 /****************************************************/
 /*
 Models for storing chats
 */
-CREATE TABLE chats (id TEXT PRIMARY KEY);
+CREATE TABLE chats (
+  id TEXT PRIMARY KEY,
+  user_id TEXT -- NULL for chats from before users existed
+);
 
 /*
 HUMAN message = check messages.content
@@ -50,6 +83,9 @@ CREATE TABLE tool_call_result (
   name TEXT NOT NULL,
   content TEXT NOT NULL,
   is_error BOOLEAN DEFAULT FALSE NOT NULL,
+  status TEXT NOT NULL DEFAULT 'OK' CHECK (
+    status in ('OK', 'ERROR', 'TIMEOUT', 'UNAUTHORIZED')
+  ),
   FOREIGN KEY (message_id) REFERENCES messages (id) ON DELETE CASCADE
 );
 
@@ -139,7 +175,14 @@ CREATE TABLE oauth_providers (
   client_id TEXT NOT NULL,
   client_secret TEXT NOT NULL,
   callback_url TEXT NOT NULL,
-  scopes TEXT
+  scopes TEXT,
+  token_url TEXT, -- Where the API exchanges refresh tokens for access tokens
+  kind TEXT NOT NULL DEFAULT 'GOOGLE', -- Goth provider the provider is built with
+  auth_url TEXT,
+  user_info_url TEXT,
+  pkce BOOLEAN NOT NULL DEFAULT FALSE,
+  auth_params JSON NOT NULL DEFAULT '{}', -- Extra params sent to auth_url
+  revoke_url TEXT -- RFC 7009 endpoint tokens are revoked at
 );
 
 /*
@@ -149,9 +192,17 @@ CREATE TABLE oauth_tokens (
   id TEXT PRIMARY KEY,
   refresh_token TEXT UNIQUE NOT NULL,
   oauth_provider TEXT NOT NULL,
+  account TEXT, -- e.g. the email the user signed in with
+  scopes TEXT, -- JSON array of the scopes granted
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  last_refreshed_at DATETIME,
+  user_id TEXT,
   FOREIGN KEY (oauth_provider) REFERENCES oauth_providers (name)
 );
 
+-- Each user has one connection per provider
+CREATE UNIQUE INDEX oauth_tokens_user_provider ON oauth_tokens (user_id, oauth_provider);
+
 /***************************************************/
 /*
 mcp_servers is a list of possible MCP servers that can exist.
@@ -168,6 +219,11 @@ CREATE TABLE mcp_server_images (
   ),
   oauth_provider TEXT,
   env_schema JSON NOT NULL,
+  launcher JSON NOT NULL DEFAULT '{"type":"DOCKER"}',
+  tool_timeouts JSON NOT NULL DEFAULT '{}',
+  sampling_policy JSON NOT NULL DEFAULT '{"allowed":false}',
+  warm_pool JSON NOT NULL DEFAULT '{"size":0}',
+  oauth_scopes JSON NOT NULL DEFAULT '[]', -- Scopes the image needs from oauth_provider
   PRIMARY KEY (slug, version),
   FOREIGN KEY (oauth_provider) REFERENCES oauth_providers (name)
 );
@@ -178,6 +234,42 @@ CREATE TABLE mcp_server_tools (
   description TEXT,
   schema TEXT NOT NULL,
   image_id TEXT NOT NULL,
+  timeout_seconds INTEGER NOT NULL DEFAULT 0, -- 0 = instance default
+  FOREIGN KEY (image_id) REFERENCES mcp_server_images (id)
+);
+
+/*
+Resources and resource templates an image exposes. Discovered when the image is created
+*/
+CREATE TABLE mcp_server_resources (
+  id TEXT NOT NULL,
+  uri TEXT NOT NULL,
+  name TEXT NOT NULL,
+  description TEXT,
+  mime_type TEXT,
+  image_id TEXT NOT NULL,
+  FOREIGN KEY (image_id) REFERENCES mcp_server_images (id)
+);
+
+CREATE TABLE mcp_server_resource_templates (
+  id TEXT NOT NULL,
+  uri_template TEXT NOT NULL,
+  name TEXT NOT NULL,
+  description TEXT,
+  mime_type TEXT,
+  image_id TEXT NOT NULL,
+  FOREIGN KEY (image_id) REFERENCES mcp_server_images (id)
+);
+
+/*
+Prompt templates an image exposes. arguments is a JSON array of {name, description, required}
+*/
+CREATE TABLE mcp_server_prompts (
+  id TEXT NOT NULL,
+  name TEXT NOT NULL,
+  description TEXT,
+  arguments JSON NOT NULL,
+  image_id TEXT NOT NULL,
   FOREIGN KEY (image_id) REFERENCES mcp_server_images (id)
 );
 
@@ -188,5 +280,58 @@ CREATE TABLE mcp_server_instances (
   address TEXT NOT NULL,
   env JSON NOT NULL,
   created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  desired_state TEXT NOT NULL DEFAULT 'RUNNING' CHECK (desired_state IN ('RUNNING', 'STOPPED')),
+  runtime_state TEXT NOT NULL DEFAULT 'RUNNING' CHECK (runtime_state IN ('RUNNING', 'STOPPED')),
+  last_used_at DATETIME,
+  user_id TEXT,
   FOREIGN KEY (slug, version) REFERENCES mcp_server_images (slug, version)
 );
+
+/*
+Time it took to start a stopped instance for the request that needed it. Instances are deleted
+when killed so there is no foreign key
+*/
+CREATE TABLE mcp_server_instance_cold_starts (
+  id TEXT PRIMARY KEY,
+  instance_id TEXT NOT NULL,
+  duration_ms INTEGER NOT NULL,
+  error TEXT,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+/*
+Tools an instance discovered with its real env (JSON list of tools), these replace the image's
+tools for the instance. Written by the instance before its row exists so there is no foreign key
+*/
+CREATE TABLE mcp_server_instance_tools (
+  instance_id TEXT PRIMARY KEY,
+  tools TEXT NOT NULL,
+  updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+/*
+Audit log of sampling/createMessage requests MCP servers sent to our LLM. Instances are deleted
+when stopped so there are no foreign keys, the log outlives them
+*/
+CREATE TABLE mcp_sampling_requests (
+  id TEXT PRIMARY KEY,
+  instance_id TEXT NOT NULL,
+  image_id TEXT NOT NULL,
+  status TEXT NOT NULL CHECK (status in ('COMPLETED', 'DENIED', 'FAILED')),
+  model TEXT,
+  max_tokens INTEGER NOT NULL,
+  request TEXT NOT NULL,
+  response TEXT,
+  error TEXT,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+/*
+Values env schemas use as $secret.<name>, encrypted with SECRETS_KEY (AES-GCM, nonce first)
+*/
+CREATE TABLE secrets (
+  name TEXT PRIMARY KEY,
+  value BLOB NOT NULL,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
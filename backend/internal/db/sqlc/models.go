@@ -18,38 +18,114 @@ type AiMessagePart struct {
 	MessageID string
 }
 
+type ApiKey struct {
+	ID         string
+	UserID     string
+	Name       string
+	Prefix     string
+	KeyHash    string
+	Scopes     models.APIKeyScopes
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	CreatedAt  sql.NullTime
+}
+
 type Chat struct {
-	ID string
+	ID     string
+	UserID sql.NullString
+}
+
+type McpSamplingRequest struct {
+	ID         string
+	InstanceID string
+	ImageID    string
+	Status     string
+	Model      sql.NullString
+	MaxTokens  int64
+	Request    string
+	Response   sql.NullString
+	Error      sql.NullString
+	CreatedAt  sql.NullTime
 }
 
 type McpServerImage struct {
-	ID            string
-	Slug          string
-	Version       int64
-	Name          string
-	DockerImage   string
-	Type          string
-	OauthProvider sql.NullString
-	EnvSchema     models.EnvSchema
+	ID             string
+	Slug           string
+	Version        int64
+	Name           string
+	DockerImage    string
+	Type           string
+	OauthProvider  sql.NullString
+	EnvSchema      models.EnvSchema
+	Launcher       models.MCPLauncher
+	ToolTimeouts   models.ToolTimeouts
+	SamplingPolicy models.SamplingPolicy
+	WarmPool       models.WarmPool
+	OauthScopes    models.OauthScopes
 }
 
 type McpServerInstance struct {
-	ID        string
-	Slug      string
-	Version   int64
-	Address   string
-	Env       interface{}
-	CreatedAt sql.NullTime
+	ID           string
+	Slug         string
+	Version      int64
+	Address      string
+	Env          models.InstanceEnv
+	CreatedAt    sql.NullTime
+	DesiredState string
+	RuntimeState string
+	LastUsedAt   sql.NullTime
+	UserID       sql.NullString
 }
 
-type McpServerTool struct {
+type McpServerInstanceColdStart struct {
+	ID         string
+	InstanceID string
+	DurationMs int64
+	Error      sql.NullString
+	CreatedAt  sql.NullTime
+}
+
+type McpServerInstanceTool struct {
+	InstanceID string
+	Tools      string
+	UpdatedAt  sql.NullTime
+}
+
+type McpServerPrompt struct {
+	ID          string
+	Name        string
+	Description sql.NullString
+	Arguments   models.MCPPromptArguments
+	ImageID     string
+}
+
+type McpServerResource struct {
+	ID          string
+	Uri         string
+	Name        string
+	Description sql.NullString
+	MimeType    sql.NullString
+	ImageID     string
+}
+
+type McpServerResourceTemplate struct {
 	ID          string
+	UriTemplate string
 	Name        string
 	Description sql.NullString
-	Schema      string
+	MimeType    sql.NullString
 	ImageID     string
 }
 
+type McpServerTool struct {
+	ID             string
+	Name           string
+	Description    sql.NullString
+	Schema         string
+	ImageID        string
+	TimeoutSeconds int64
+}
+
 type Message struct {
 	ID         string
 	Role       string
@@ -64,12 +140,31 @@ type OauthProvider struct {
 	ClientSecret string
 	CallbackUrl  string
 	Scopes       sql.NullString
+	TokenUrl     sql.NullString
+	Kind         models.OauthProviderKind
+	AuthUrl      sql.NullString
+	UserInfoUrl  sql.NullString
+	Pkce         bool
+	AuthParams   models.OauthAuthParams
+	RevokeUrl    sql.NullString
 }
 
 type OauthToken struct {
-	ID            string
-	RefreshToken  string
-	OauthProvider string
+	ID              string
+	RefreshToken    string
+	OauthProvider   string
+	Account         sql.NullString
+	Scopes          sql.NullString
+	CreatedAt       sql.NullTime
+	LastRefreshedAt sql.NullTime
+	UserID          sql.NullString
+}
+
+type Secret struct {
+	Name      string
+	Value     []byte
+	CreatedAt sql.NullTime
+	UpdatedAt sql.NullTime
 }
 
 type TextPart struct {
@@ -92,6 +187,16 @@ type ToolCallResult struct {
 	Name       string
 	Content    string
 	IsError    bool
+	Status     string
+}
+
+type User struct {
+	ID          string
+	Email       string
+	Name        sql.NullString
+	CreatedAt   sql.NullTime
+	LastLoginAt sql.NullTime
+	IsAdmin     bool
 }
 
 type VGetChatMessage struct {
@@ -6,34 +6,93 @@ package db
 
 import (
 	"context"
+	"database/sql"
+
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/db/models"
 )
 
 type Querier interface {
+	ClaimUnownedChats(ctx context.Context, userID sql.NullString) error
+	ClaimUnownedMCPServerInstances(ctx context.Context, userID sql.NullString) error
+	ClaimUnownedOauthTokens(ctx context.Context, userID sql.NullString) error
+	CountUsers(ctx context.Context) (int64, error)
+	DeleteAPIKey(ctx context.Context, id string) (int64, error)
 	DeleteAllMCPinstances(ctx context.Context) error
 	DeleteMCPServerInstance(ctx context.Context, id string) error
-	//*********************************
-	GetChatMessages(ctx context.Context, chatID string) ([]GetChatMessagesRow, error)
-	GetChatsWithMessageCount(ctx context.Context) ([]GetChatsWithMessageCountRow, error)
+	DeleteMCPServerInstanceTools(ctx context.Context, instanceID string) error
+	DeleteOauthToken(ctx context.Context, id string) (int64, error)
+	DeleteSecret(ctx context.Context, name string) (int64, error)
+	GetAPIKey(ctx context.Context, id string) (ApiKey, error)
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (ApiKey, error)
+	GetAPIKeys(ctx context.Context, userID string) ([]ApiKey, error)
+	GetAllMCPServerInstanceTools(ctx context.Context) ([]McpServerInstanceTool, error)
+	GetAllMCPServerInstances(ctx context.Context) ([]McpServerInstance, error)
+	GetChat(ctx context.Context, id string) (Chat, error)
+	GetIdleMCPServerInstances(ctx context.Context, idleFor interface{}) ([]McpServerInstance, error)
+	GetLatestMCPServerImageBySlug(ctx context.Context, slug string) (McpServerImage, error)
+	GetMCPSamplingRequestsByInstance(ctx context.Context, instanceID string) ([]McpSamplingRequest, error)
 	GetMCPServerImage(ctx context.Context, id string) (GetMCPServerImageRow, error)
-	GetMCPServerInstances(ctx context.Context) ([]GetMCPServerInstancesRow, error)
-	GetOauthTokenByProvider(ctx context.Context, oauthProvider string) (OauthToken, error)
+	GetMCPServerImageBySlugVersion(ctx context.Context, arg GetMCPServerImageBySlugVersionParams) (GetMCPServerImageBySlugVersionRow, error)
+	GetMCPServerImages(ctx context.Context) ([]McpServerImage, error)
+	GetMCPServerInstance(ctx context.Context, id string) (McpServerInstance, error)
+	GetMCPServerInstanceColdStarts(ctx context.Context, instanceID string) ([]McpServerInstanceColdStart, error)
+	GetMCPServerInstancePrompts(ctx context.Context, userID sql.NullString) ([]GetMCPServerInstancePromptsRow, error)
+	GetMCPServerInstanceTools(ctx context.Context, instanceID string) (McpServerInstanceTool, error)
+	GetMCPServerInstances(ctx context.Context, userID sql.NullString) ([]GetMCPServerInstancesRow, error)
+	GetMCPServerInstancesByOauthProvider(ctx context.Context, arg GetMCPServerInstancesByOauthProviderParams) ([]McpServerInstance, error)
+	GetMCPServerPromptsByImage(ctx context.Context, imageID string) ([]McpServerPrompt, error)
+	GetMCPServerResourceTemplatesByImage(ctx context.Context, imageID string) ([]McpServerResourceTemplate, error)
+	GetMCPServerResourcesByImage(ctx context.Context, imageID string) ([]McpServerResource, error)
+	GetMCPServerToolsByImage(ctx context.Context, imageID string) ([]McpServerTool, error)
+	GetOauthProvider(ctx context.Context, name string) (OauthProvider, error)
+	GetOauthProviders(ctx context.Context) ([]OauthProvider, error)
+	GetOauthScopesInUseByProvider(ctx context.Context, arg GetOauthScopesInUseByProviderParams) ([]models.OauthScopes, error)
+	GetOauthToken(ctx context.Context, id string) (OauthToken, error)
+	GetOauthTokenByProvider(ctx context.Context, arg GetOauthTokenByProviderParams) (OauthToken, error)
+	GetOauthTokens(ctx context.Context, userID sql.NullString) ([]OauthToken, error)
+	GetSecret(ctx context.Context, name string) (Secret, error)
+	GetSecrets(ctx context.Context) ([]GetSecretsRow, error)
+	GetUser(ctx context.Context, id string) (User, error)
+	GetUserByEmail(ctx context.Context, email string) (User, error)
+	//*********************************
 	GetViewChatMessges(ctx context.Context, chatID string) ([]VGetChatMessage, error)
 	InsertAIMessagePart(ctx context.Context, arg InsertAIMessagePartParams) (int64, error)
+	InsertAPIKey(ctx context.Context, arg InsertAPIKeyParams) error
 	//*********************************
-	InsertChat(ctx context.Context, id string) error
+	InsertChat(ctx context.Context, arg InsertChatParams) error
+	InsertMCPSamplingRequest(ctx context.Context, arg InsertMCPSamplingRequestParams) error
 	//*********************************
 	InsertMCPServerImage(ctx context.Context, arg InsertMCPServerImageParams) error
 	//*********************************
 	InsertMCPServerInstance(ctx context.Context, arg InsertMCPServerInstanceParams) error
+	InsertMCPServerInstanceColdStart(ctx context.Context, arg InsertMCPServerInstanceColdStartParams) error
 	InsertMCPServerInstanceTool(ctx context.Context, arg InsertMCPServerInstanceToolParams) error
+	InsertMCPServerPrompt(ctx context.Context, arg InsertMCPServerPromptParams) error
+	InsertMCPServerResource(ctx context.Context, arg InsertMCPServerResourceParams) error
+	InsertMCPServerResourceTemplate(ctx context.Context, arg InsertMCPServerResourceTemplateParams) error
 	InsertMessage(ctx context.Context, arg InsertMessageParams) error
-	//*********************************
-	InsertOauthProvider(ctx context.Context, arg InsertOauthProviderParams) error
 	InsertOauthToken(ctx context.Context, arg InsertOauthTokenParams) error
 	InsertTextPart(ctx context.Context, arg InsertTextPartParams) error
 	InsertToolCallPart(ctx context.Context, arg InsertToolCallPartParams) error
 	InsertToolCallResult(ctx context.Context, arg InsertToolCallResultParams) error
+	//*********************************
+	InsertUser(ctx context.Context, arg InsertUserParams) error
+	MarkOauthTokenRefreshed(ctx context.Context, arg MarkOauthTokenRefreshedParams) error
+	ReconnectOauthTokenByProvider(ctx context.Context, arg ReconnectOauthTokenByProviderParams) error
+	SetUserAdmin(ctx context.Context, arg SetUserAdminParams) error
+	StopMCPServerInstancesByAddress(ctx context.Context, address string) error
+	TouchAPIKey(ctx context.Context, id string) error
+	TouchMCPServerInstance(ctx context.Context, id string) error
+	UpdateMCPServerInstanceDesiredState(ctx context.Context, arg UpdateMCPServerInstanceDesiredStateParams) error
+	UpdateMCPServerInstanceRuntimeState(ctx context.Context, arg UpdateMCPServerInstanceRuntimeStateParams) error
+	UpdateMCPServerInstanceVersion(ctx context.Context, arg UpdateMCPServerInstanceVersionParams) error
 	UpdateOauthTokenByProivder(ctx context.Context, arg UpdateOauthTokenByProivderParams) error
+	UpdateUserLogin(ctx context.Context, arg UpdateUserLoginParams) error
+	UpsertMCPServerInstanceTools(ctx context.Context, arg UpsertMCPServerInstanceToolsParams) error
+	//*********************************
+	UpsertOauthProvider(ctx context.Context, arg UpsertOauthProviderParams) error
+	//*********************************
+	UpsertSecret(ctx context.Context, arg UpsertSecretParams) error
 }
 
 var _ Querier = (*Queries)(nil)
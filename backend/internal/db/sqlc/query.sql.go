@@ -12,6 +12,73 @@ import (
 	"github.com/AbhinavPalacharla/xtrn-personal/internal/db/models"
 )
 
+const claimUnownedChats = `-- name: ClaimUnownedChats :exec
+UPDATE chats
+SET
+  user_id = ?
+WHERE
+  user_id IS NULL
+`
+
+func (q *Queries) ClaimUnownedChats(ctx context.Context, userID sql.NullString) error {
+	_, err := q.db.ExecContext(ctx, claimUnownedChats, userID)
+	return err
+}
+
+const claimUnownedMCPServerInstances = `-- name: ClaimUnownedMCPServerInstances :exec
+UPDATE mcp_server_instances
+SET
+  user_id = ?
+WHERE
+  user_id IS NULL
+`
+
+func (q *Queries) ClaimUnownedMCPServerInstances(ctx context.Context, userID sql.NullString) error {
+	_, err := q.db.ExecContext(ctx, claimUnownedMCPServerInstances, userID)
+	return err
+}
+
+const claimUnownedOauthTokens = `-- name: ClaimUnownedOauthTokens :exec
+UPDATE oauth_tokens
+SET
+  user_id = ?
+WHERE
+  user_id IS NULL
+`
+
+func (q *Queries) ClaimUnownedOauthTokens(ctx context.Context, userID sql.NullString) error {
+	_, err := q.db.ExecContext(ctx, claimUnownedOauthTokens, userID)
+	return err
+}
+
+const countUsers = `-- name: CountUsers :one
+SELECT
+  COUNT(*)
+FROM
+  users
+`
+
+func (q *Queries) CountUsers(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUsers)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const deleteAPIKey = `-- name: DeleteAPIKey :execrows
+DELETE FROM api_keys
+WHERE
+  id = ?
+`
+
+func (q *Queries) DeleteAPIKey(ctx context.Context, id string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteAPIKey, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const deleteAllMCPinstances = `-- name: DeleteAllMCPinstances :exec
 DELETE FROM mcp_server_instances
 `
@@ -32,111 +99,1022 @@ func (q *Queries) DeleteMCPServerInstance(ctx context.Context, id string) error
 	return err
 }
 
-const getChatMessages = `-- name: GetChatMessages :many
-SELECT
-  m.id, m.role, m.content, m.stop_reason, m.chat_id,
-  CASE
-    WHEN m.role = 'ai' THEN (
-      SELECT
-        json_group_array(p.part_json)
-      FROM
-        (
-          SELECT
-            CASE
-              WHEN amp.type = 'text' THEN json_object(
-                'type',
-                'text',
-                'index',
-                amp.part_index,
-                'text',
-                tp.text
-              )
-              WHEN amp.type = 'function' THEN json_object(
-                'type',
-                'tool_call',
-                'index',
-                amp.part_index,
-                'tool_call_id',
-                tcp.tool_call_id,
-                'name',
-                tcp.name,
-                'arguments',
-                tcp.arguments
-              )
-            END AS part_json
-          FROM
-            ai_message_parts amp
-            LEFT JOIN text_part tp ON tp.message_part_id = amp.id
-            AND amp.type = 'text'
-            LEFT JOIN tool_call_part tcp ON tcp.message_part_id = amp.id
-            AND amp.type = 'function'
-          WHERE
-            amp.message_id = m.id
-          ORDER BY
-            amp.part_index,
-            amp.id
-        ) p
-    )
-  END AS ai_message,
-  CASE
-    WHEN m.role = 'tool' THEN (
-      SELECT
-        json_object(
-          'tool_call_id',
-          t.tool_call_id,
-          'name',
-          t.name,
-          'content',
-          t.content,
-          'is_error',
-          t.is_error
-        )
-      FROM
-        tool_call_result t
-      WHERE
-        t.message_id = m.id
-    )
-  END AS tool_result
-FROM
-  messages m
-WHERE
-  m.chat_id = ?
+const deleteMCPServerInstanceTools = `-- name: DeleteMCPServerInstanceTools :exec
+DELETE FROM mcp_server_instance_tools
+WHERE
+  instance_id = ?
+`
+
+func (q *Queries) DeleteMCPServerInstanceTools(ctx context.Context, instanceID string) error {
+	_, err := q.db.ExecContext(ctx, deleteMCPServerInstanceTools, instanceID)
+	return err
+}
+
+const deleteOauthToken = `-- name: DeleteOauthToken :execrows
+DELETE FROM oauth_tokens
+WHERE
+  id = ?
+`
+
+func (q *Queries) DeleteOauthToken(ctx context.Context, id string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteOauthToken, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteSecret = `-- name: DeleteSecret :execrows
+DELETE FROM secrets
+WHERE
+  name = ?
+`
+
+func (q *Queries) DeleteSecret(ctx context.Context, name string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteSecret, name)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getAPIKey = `-- name: GetAPIKey :one
+SELECT
+  id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, created_at
+FROM
+  api_keys
+WHERE
+  id = ?
+`
+
+func (q *Queries) GetAPIKey(ctx context.Context, id string) (ApiKey, error) {
+	row := q.db.QueryRowContext(ctx, getAPIKey, id)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Name,
+		&i.Prefix,
+		&i.KeyHash,
+		&i.Scopes,
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getAPIKeyByHash = `-- name: GetAPIKeyByHash :one
+SELECT
+  id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, created_at
+FROM
+  api_keys
+WHERE
+  key_hash = ?
+`
+
+func (q *Queries) GetAPIKeyByHash(ctx context.Context, keyHash string) (ApiKey, error) {
+	row := q.db.QueryRowContext(ctx, getAPIKeyByHash, keyHash)
+	var i ApiKey
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Name,
+		&i.Prefix,
+		&i.KeyHash,
+		&i.Scopes,
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getAPIKeys = `-- name: GetAPIKeys :many
+SELECT
+  id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, created_at
+FROM
+  api_keys
+WHERE
+  user_id = ?
+ORDER BY
+  created_at DESC
+`
+
+func (q *Queries) GetAPIKeys(ctx context.Context, userID string) ([]ApiKey, error) {
+	rows, err := q.db.QueryContext(ctx, getAPIKeys, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ApiKey
+	for rows.Next() {
+		var i ApiKey
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Name,
+			&i.Prefix,
+			&i.KeyHash,
+			&i.Scopes,
+			&i.ExpiresAt,
+			&i.LastUsedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAllMCPServerInstanceTools = `-- name: GetAllMCPServerInstanceTools :many
+SELECT
+  instance_id, tools, updated_at
+FROM
+  mcp_server_instance_tools
+`
+
+func (q *Queries) GetAllMCPServerInstanceTools(ctx context.Context) ([]McpServerInstanceTool, error) {
+	rows, err := q.db.QueryContext(ctx, getAllMCPServerInstanceTools)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []McpServerInstanceTool
+	for rows.Next() {
+		var i McpServerInstanceTool
+		if err := rows.Scan(&i.InstanceID, &i.Tools, &i.UpdatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAllMCPServerInstances = `-- name: GetAllMCPServerInstances :many
+SELECT
+  id, slug, version, address, env, created_at, desired_state, runtime_state, last_used_at, user_id
+FROM
+  mcp_server_instances
+`
+
+func (q *Queries) GetAllMCPServerInstances(ctx context.Context) ([]McpServerInstance, error) {
+	rows, err := q.db.QueryContext(ctx, getAllMCPServerInstances)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []McpServerInstance
+	for rows.Next() {
+		var i McpServerInstance
+		if err := rows.Scan(
+			&i.ID,
+			&i.Slug,
+			&i.Version,
+			&i.Address,
+			&i.Env,
+			&i.CreatedAt,
+			&i.DesiredState,
+			&i.RuntimeState,
+			&i.LastUsedAt,
+			&i.UserID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChat = `-- name: GetChat :one
+SELECT
+  id, user_id
+FROM
+  chats
+WHERE
+  id = ?
+`
+
+func (q *Queries) GetChat(ctx context.Context, id string) (Chat, error) {
+	row := q.db.QueryRowContext(ctx, getChat, id)
+	var i Chat
+	err := row.Scan(&i.ID, &i.UserID)
+	return i, err
+}
+
+const getIdleMCPServerInstances = `-- name: GetIdleMCPServerInstances :many
+SELECT
+  id, slug, version, address, env, created_at, desired_state, runtime_state, last_used_at, user_id
+FROM
+  mcp_server_instances
+WHERE
+  runtime_state = 'RUNNING'
+  AND COALESCE(last_used_at, created_at) < datetime('now', ?1)
+`
+
+func (q *Queries) GetIdleMCPServerInstances(ctx context.Context, idleFor interface{}) ([]McpServerInstance, error) {
+	rows, err := q.db.QueryContext(ctx, getIdleMCPServerInstances, idleFor)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []McpServerInstance
+	for rows.Next() {
+		var i McpServerInstance
+		if err := rows.Scan(
+			&i.ID,
+			&i.Slug,
+			&i.Version,
+			&i.Address,
+			&i.Env,
+			&i.CreatedAt,
+			&i.DesiredState,
+			&i.RuntimeState,
+			&i.LastUsedAt,
+			&i.UserID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getLatestMCPServerImageBySlug = `-- name: GetLatestMCPServerImageBySlug :one
+SELECT
+  id, slug, version, name, docker_image, type, oauth_provider, env_schema, launcher, tool_timeouts, sampling_policy, warm_pool, oauth_scopes
+FROM
+  mcp_server_images
+WHERE
+  slug = ?
+ORDER BY
+  version DESC
+LIMIT
+  1
+`
+
+func (q *Queries) GetLatestMCPServerImageBySlug(ctx context.Context, slug string) (McpServerImage, error) {
+	row := q.db.QueryRowContext(ctx, getLatestMCPServerImageBySlug, slug)
+	var i McpServerImage
+	err := row.Scan(
+		&i.ID,
+		&i.Slug,
+		&i.Version,
+		&i.Name,
+		&i.DockerImage,
+		&i.Type,
+		&i.OauthProvider,
+		&i.EnvSchema,
+		&i.Launcher,
+		&i.ToolTimeouts,
+		&i.SamplingPolicy,
+		&i.WarmPool,
+		&i.OauthScopes,
+	)
+	return i, err
+}
+
+const getMCPSamplingRequestsByInstance = `-- name: GetMCPSamplingRequestsByInstance :many
+SELECT
+  id, instance_id, image_id, status, model, max_tokens, request, response, error, created_at
+FROM
+  mcp_sampling_requests
+WHERE
+  instance_id = ?
 ORDER BY
-  m.id
+  created_at DESC
+`
+
+func (q *Queries) GetMCPSamplingRequestsByInstance(ctx context.Context, instanceID string) ([]McpSamplingRequest, error) {
+	rows, err := q.db.QueryContext(ctx, getMCPSamplingRequestsByInstance, instanceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []McpSamplingRequest
+	for rows.Next() {
+		var i McpSamplingRequest
+		if err := rows.Scan(
+			&i.ID,
+			&i.InstanceID,
+			&i.ImageID,
+			&i.Status,
+			&i.Model,
+			&i.MaxTokens,
+			&i.Request,
+			&i.Response,
+			&i.Error,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMCPServerImage = `-- name: GetMCPServerImage :one
+SELECT
+  images.id, images.slug, images.version, images.name, images.docker_image, images.type, images.oauth_provider, images.env_schema, images.launcher, images.tool_timeouts, images.sampling_policy, images.warm_pool, images.oauth_scopes,
+  providers.name as provider_name,
+  providers.client_id,
+  providers.client_secret
+FROM
+  mcp_server_images AS images
+  LEFT JOIN oauth_providers AS providers ON images.oauth_provider = providers.name
+WHERE
+  images.id = ?
+`
+
+type GetMCPServerImageRow struct {
+	ID             string
+	Slug           string
+	Version        int64
+	Name           string
+	DockerImage    string
+	Type           string
+	OauthProvider  sql.NullString
+	EnvSchema      models.EnvSchema
+	Launcher       models.MCPLauncher
+	ToolTimeouts   models.ToolTimeouts
+	SamplingPolicy models.SamplingPolicy
+	WarmPool       models.WarmPool
+	OauthScopes    models.OauthScopes
+	ProviderName   sql.NullString
+	ClientID       sql.NullString
+	ClientSecret   sql.NullString
+}
+
+func (q *Queries) GetMCPServerImage(ctx context.Context, id string) (GetMCPServerImageRow, error) {
+	row := q.db.QueryRowContext(ctx, getMCPServerImage, id)
+	var i GetMCPServerImageRow
+	err := row.Scan(
+		&i.ID,
+		&i.Slug,
+		&i.Version,
+		&i.Name,
+		&i.DockerImage,
+		&i.Type,
+		&i.OauthProvider,
+		&i.EnvSchema,
+		&i.Launcher,
+		&i.ToolTimeouts,
+		&i.SamplingPolicy,
+		&i.WarmPool,
+		&i.OauthScopes,
+		&i.ProviderName,
+		&i.ClientID,
+		&i.ClientSecret,
+	)
+	return i, err
+}
+
+const getMCPServerImageBySlugVersion = `-- name: GetMCPServerImageBySlugVersion :one
+SELECT
+  images.id, images.slug, images.version, images.name, images.docker_image, images.type, images.oauth_provider, images.env_schema, images.launcher, images.tool_timeouts, images.sampling_policy, images.warm_pool, images.oauth_scopes,
+  providers.name as provider_name,
+  providers.client_id,
+  providers.client_secret
+FROM
+  mcp_server_images AS images
+  LEFT JOIN oauth_providers AS providers ON images.oauth_provider = providers.name
+WHERE
+  images.slug = ?
+  AND images.version = ?
+`
+
+type GetMCPServerImageBySlugVersionParams struct {
+	Slug    string
+	Version int64
+}
+
+type GetMCPServerImageBySlugVersionRow struct {
+	ID             string
+	Slug           string
+	Version        int64
+	Name           string
+	DockerImage    string
+	Type           string
+	OauthProvider  sql.NullString
+	EnvSchema      models.EnvSchema
+	Launcher       models.MCPLauncher
+	ToolTimeouts   models.ToolTimeouts
+	SamplingPolicy models.SamplingPolicy
+	WarmPool       models.WarmPool
+	OauthScopes    models.OauthScopes
+	ProviderName   sql.NullString
+	ClientID       sql.NullString
+	ClientSecret   sql.NullString
+}
+
+func (q *Queries) GetMCPServerImageBySlugVersion(ctx context.Context, arg GetMCPServerImageBySlugVersionParams) (GetMCPServerImageBySlugVersionRow, error) {
+	row := q.db.QueryRowContext(ctx, getMCPServerImageBySlugVersion, arg.Slug, arg.Version)
+	var i GetMCPServerImageBySlugVersionRow
+	err := row.Scan(
+		&i.ID,
+		&i.Slug,
+		&i.Version,
+		&i.Name,
+		&i.DockerImage,
+		&i.Type,
+		&i.OauthProvider,
+		&i.EnvSchema,
+		&i.Launcher,
+		&i.ToolTimeouts,
+		&i.SamplingPolicy,
+		&i.WarmPool,
+		&i.OauthScopes,
+		&i.ProviderName,
+		&i.ClientID,
+		&i.ClientSecret,
+	)
+	return i, err
+}
+
+const getMCPServerImages = `-- name: GetMCPServerImages :many
+SELECT
+  id, slug, version, name, docker_image, type, oauth_provider, env_schema, launcher, tool_timeouts, sampling_policy, warm_pool, oauth_scopes
+FROM
+  mcp_server_images
+ORDER BY
+  slug,
+  version
+`
+
+func (q *Queries) GetMCPServerImages(ctx context.Context) ([]McpServerImage, error) {
+	rows, err := q.db.QueryContext(ctx, getMCPServerImages)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []McpServerImage
+	for rows.Next() {
+		var i McpServerImage
+		if err := rows.Scan(
+			&i.ID,
+			&i.Slug,
+			&i.Version,
+			&i.Name,
+			&i.DockerImage,
+			&i.Type,
+			&i.OauthProvider,
+			&i.EnvSchema,
+			&i.Launcher,
+			&i.ToolTimeouts,
+			&i.SamplingPolicy,
+			&i.WarmPool,
+			&i.OauthScopes,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMCPServerInstance = `-- name: GetMCPServerInstance :one
+SELECT
+  id, slug, version, address, env, created_at, desired_state, runtime_state, last_used_at, user_id
+FROM
+  mcp_server_instances
+WHERE
+  id = ?
+`
+
+func (q *Queries) GetMCPServerInstance(ctx context.Context, id string) (McpServerInstance, error) {
+	row := q.db.QueryRowContext(ctx, getMCPServerInstance, id)
+	var i McpServerInstance
+	err := row.Scan(
+		&i.ID,
+		&i.Slug,
+		&i.Version,
+		&i.Address,
+		&i.Env,
+		&i.CreatedAt,
+		&i.DesiredState,
+		&i.RuntimeState,
+		&i.LastUsedAt,
+		&i.UserID,
+	)
+	return i, err
+}
+
+const getMCPServerInstanceColdStarts = `-- name: GetMCPServerInstanceColdStarts :many
+SELECT
+  id, instance_id, duration_ms, error, created_at
+FROM
+  mcp_server_instance_cold_starts
+WHERE
+  instance_id = ?
+ORDER BY
+  created_at DESC
+`
+
+func (q *Queries) GetMCPServerInstanceColdStarts(ctx context.Context, instanceID string) ([]McpServerInstanceColdStart, error) {
+	rows, err := q.db.QueryContext(ctx, getMCPServerInstanceColdStarts, instanceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []McpServerInstanceColdStart
+	for rows.Next() {
+		var i McpServerInstanceColdStart
+		if err := rows.Scan(
+			&i.ID,
+			&i.InstanceID,
+			&i.DurationMs,
+			&i.Error,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMCPServerInstancePrompts = `-- name: GetMCPServerInstancePrompts :many
+SELECT
+  inst.id as instance_id,
+  inst.address,
+  prompt.name as prompt_name,
+  prompt.description as prompt_desc,
+  prompt.arguments as prompt_arguments
+FROM
+  mcp_server_instances inst
+  JOIN mcp_server_images AS img ON inst.slug = img.slug
+  AND inst.version = img.version
+  JOIN mcp_server_prompts as prompt ON img.id = prompt.image_id
+WHERE
+  inst.desired_state = 'RUNNING'
+  AND inst.user_id = ?
+`
+
+type GetMCPServerInstancePromptsRow struct {
+	InstanceID      string
+	Address         string
+	PromptName      string
+	PromptDesc      sql.NullString
+	PromptArguments models.MCPPromptArguments
+}
+
+func (q *Queries) GetMCPServerInstancePrompts(ctx context.Context, userID sql.NullString) ([]GetMCPServerInstancePromptsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getMCPServerInstancePrompts, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetMCPServerInstancePromptsRow
+	for rows.Next() {
+		var i GetMCPServerInstancePromptsRow
+		if err := rows.Scan(
+			&i.InstanceID,
+			&i.Address,
+			&i.PromptName,
+			&i.PromptDesc,
+			&i.PromptArguments,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMCPServerInstanceTools = `-- name: GetMCPServerInstanceTools :one
+SELECT
+  instance_id, tools, updated_at
+FROM
+  mcp_server_instance_tools
+WHERE
+  instance_id = ?
+`
+
+func (q *Queries) GetMCPServerInstanceTools(ctx context.Context, instanceID string) (McpServerInstanceTool, error) {
+	row := q.db.QueryRowContext(ctx, getMCPServerInstanceTools, instanceID)
+	var i McpServerInstanceTool
+	err := row.Scan(&i.InstanceID, &i.Tools, &i.UpdatedAt)
+	return i, err
+}
+
+const getMCPServerInstances = `-- name: GetMCPServerInstances :many
+SELECT
+  inst.id as instance_id,
+  inst.address,
+  img.id AS image_id,
+  tool.name as tool_name,
+  tool.description as tool_desc,
+  tool.schema as tool_schema
+FROM
+  mcp_server_instances inst
+  LEFT JOIN mcp_server_images AS img ON inst.slug = img.slug
+  AND inst.version = img.version
+  LEFT JOIN mcp_server_tools as tool ON img.id = tool.image_id
+WHERE
+  inst.desired_state = 'RUNNING'
+  AND inst.user_id = ?
+`
+
+type GetMCPServerInstancesRow struct {
+	InstanceID string
+	Address    string
+	ImageID    sql.NullString
+	ToolName   sql.NullString
+	ToolDesc   sql.NullString
+	ToolSchema sql.NullString
+}
+
+func (q *Queries) GetMCPServerInstances(ctx context.Context, userID sql.NullString) ([]GetMCPServerInstancesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getMCPServerInstances, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetMCPServerInstancesRow
+	for rows.Next() {
+		var i GetMCPServerInstancesRow
+		if err := rows.Scan(
+			&i.InstanceID,
+			&i.Address,
+			&i.ImageID,
+			&i.ToolName,
+			&i.ToolDesc,
+			&i.ToolSchema,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMCPServerInstancesByOauthProvider = `-- name: GetMCPServerInstancesByOauthProvider :many
+SELECT
+  inst.id, inst.slug, inst.version, inst.address, inst.env, inst.created_at, inst.desired_state, inst.runtime_state, inst.last_used_at, inst.user_id
+FROM
+  mcp_server_instances inst
+  JOIN mcp_server_images img ON inst.slug = img.slug
+  AND inst.version = img.version
+WHERE
+  img.oauth_provider = ?
+  AND inst.user_id = ?
+ORDER BY
+  inst.id
+`
+
+type GetMCPServerInstancesByOauthProviderParams struct {
+	OauthProvider sql.NullString
+	UserID        sql.NullString
+}
+
+func (q *Queries) GetMCPServerInstancesByOauthProvider(ctx context.Context, arg GetMCPServerInstancesByOauthProviderParams) ([]McpServerInstance, error) {
+	rows, err := q.db.QueryContext(ctx, getMCPServerInstancesByOauthProvider, arg.OauthProvider, arg.UserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []McpServerInstance
+	for rows.Next() {
+		var i McpServerInstance
+		if err := rows.Scan(
+			&i.ID,
+			&i.Slug,
+			&i.Version,
+			&i.Address,
+			&i.Env,
+			&i.CreatedAt,
+			&i.DesiredState,
+			&i.RuntimeState,
+			&i.LastUsedAt,
+			&i.UserID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMCPServerPromptsByImage = `-- name: GetMCPServerPromptsByImage :many
+SELECT
+  id, name, description, arguments, image_id
+FROM
+  mcp_server_prompts
+WHERE
+  image_id = ?
+`
+
+func (q *Queries) GetMCPServerPromptsByImage(ctx context.Context, imageID string) ([]McpServerPrompt, error) {
+	rows, err := q.db.QueryContext(ctx, getMCPServerPromptsByImage, imageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []McpServerPrompt
+	for rows.Next() {
+		var i McpServerPrompt
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Description,
+			&i.Arguments,
+			&i.ImageID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMCPServerResourceTemplatesByImage = `-- name: GetMCPServerResourceTemplatesByImage :many
+SELECT
+  id, uri_template, name, description, mime_type, image_id
+FROM
+  mcp_server_resource_templates
+WHERE
+  image_id = ?
+`
+
+func (q *Queries) GetMCPServerResourceTemplatesByImage(ctx context.Context, imageID string) ([]McpServerResourceTemplate, error) {
+	rows, err := q.db.QueryContext(ctx, getMCPServerResourceTemplatesByImage, imageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []McpServerResourceTemplate
+	for rows.Next() {
+		var i McpServerResourceTemplate
+		if err := rows.Scan(
+			&i.ID,
+			&i.UriTemplate,
+			&i.Name,
+			&i.Description,
+			&i.MimeType,
+			&i.ImageID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMCPServerResourcesByImage = `-- name: GetMCPServerResourcesByImage :many
+SELECT
+  id, uri, name, description, mime_type, image_id
+FROM
+  mcp_server_resources
+WHERE
+  image_id = ?
+`
+
+func (q *Queries) GetMCPServerResourcesByImage(ctx context.Context, imageID string) ([]McpServerResource, error) {
+	rows, err := q.db.QueryContext(ctx, getMCPServerResourcesByImage, imageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []McpServerResource
+	for rows.Next() {
+		var i McpServerResource
+		if err := rows.Scan(
+			&i.ID,
+			&i.Uri,
+			&i.Name,
+			&i.Description,
+			&i.MimeType,
+			&i.ImageID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMCPServerToolsByImage = `-- name: GetMCPServerToolsByImage :many
+SELECT
+  id, name, description, schema, image_id, timeout_seconds
+FROM
+  mcp_server_tools
+WHERE
+  image_id = ?
+`
+
+func (q *Queries) GetMCPServerToolsByImage(ctx context.Context, imageID string) ([]McpServerTool, error) {
+	rows, err := q.db.QueryContext(ctx, getMCPServerToolsByImage, imageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []McpServerTool
+	for rows.Next() {
+		var i McpServerTool
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Description,
+			&i.Schema,
+			&i.ImageID,
+			&i.TimeoutSeconds,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getOauthProvider = `-- name: GetOauthProvider :one
+SELECT
+  name, client_id, client_secret, callback_url, scopes, token_url, kind, auth_url, user_info_url, pkce, auth_params, revoke_url
+FROM
+  oauth_providers
+WHERE
+  name = ?
+`
+
+func (q *Queries) GetOauthProvider(ctx context.Context, name string) (OauthProvider, error) {
+	row := q.db.QueryRowContext(ctx, getOauthProvider, name)
+	var i OauthProvider
+	err := row.Scan(
+		&i.Name,
+		&i.ClientID,
+		&i.ClientSecret,
+		&i.CallbackUrl,
+		&i.Scopes,
+		&i.TokenUrl,
+		&i.Kind,
+		&i.AuthUrl,
+		&i.UserInfoUrl,
+		&i.Pkce,
+		&i.AuthParams,
+		&i.RevokeUrl,
+	)
+	return i, err
+}
+
+const getOauthProviders = `-- name: GetOauthProviders :many
+SELECT
+  name, client_id, client_secret, callback_url, scopes, token_url, kind, auth_url, user_info_url, pkce, auth_params, revoke_url
+FROM
+  oauth_providers
+ORDER BY
+  name
+`
+
+func (q *Queries) GetOauthProviders(ctx context.Context) ([]OauthProvider, error) {
+	rows, err := q.db.QueryContext(ctx, getOauthProviders)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []OauthProvider
+	for rows.Next() {
+		var i OauthProvider
+		if err := rows.Scan(
+			&i.Name,
+			&i.ClientID,
+			&i.ClientSecret,
+			&i.CallbackUrl,
+			&i.Scopes,
+			&i.TokenUrl,
+			&i.Kind,
+			&i.AuthUrl,
+			&i.UserInfoUrl,
+			&i.Pkce,
+			&i.AuthParams,
+			&i.RevokeUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getOauthScopesInUseByProvider = `-- name: GetOauthScopesInUseByProvider :many
+SELECT DISTINCT
+  img.oauth_scopes
+FROM
+  mcp_server_instances inst
+  JOIN mcp_server_images img ON inst.slug = img.slug
+  AND inst.version = img.version
+WHERE
+  img.oauth_provider = ?
+  AND inst.user_id = ?
 `
 
-type GetChatMessagesRow struct {
-	ID         string
-	Role       string
-	Content    sql.NullString
-	StopReason sql.NullString
-	ChatID     string
-	AiMessage  interface{}
-	ToolResult interface{}
+type GetOauthScopesInUseByProviderParams struct {
+	OauthProvider sql.NullString
+	UserID        sql.NullString
 }
 
-// *********************************
-func (q *Queries) GetChatMessages(ctx context.Context, chatID string) ([]GetChatMessagesRow, error) {
-	rows, err := q.db.QueryContext(ctx, getChatMessages, chatID)
+func (q *Queries) GetOauthScopesInUseByProvider(ctx context.Context, arg GetOauthScopesInUseByProviderParams) ([]models.OauthScopes, error) {
+	rows, err := q.db.QueryContext(ctx, getOauthScopesInUseByProvider, arg.OauthProvider, arg.UserID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []GetChatMessagesRow
+	var items []models.OauthScopes
 	for rows.Next() {
-		var i GetChatMessagesRow
-		if err := rows.Scan(
-			&i.ID,
-			&i.Role,
-			&i.Content,
-			&i.StopReason,
-			&i.ChatID,
-			&i.AiMessage,
-			&i.ToolResult,
-		); err != nil {
+		var oauth_scopes models.OauthScopes
+		if err := rows.Scan(&oauth_scopes); err != nil {
 			return nil, err
 		}
-		items = append(items, i)
+		items = append(items, oauth_scopes)
 	}
 	if err := rows.Close(); err != nil {
 		return nil, err
@@ -147,49 +1125,92 @@ func (q *Queries) GetChatMessages(ctx context.Context, chatID string) ([]GetChat
 	return items, nil
 }
 
-const getChatsWithMessageCount = `-- name: GetChatsWithMessageCount :many
-SELECT
-  c.id,
-  (
-    SELECT
-      COUNT(*)
-    FROM
-      messages m
-    WHERE
-      m.chat_id = c.id
-  ) as message_count
-FROM
-  chats c
-WHERE
-  c.id IN (
-    SELECT
-      chat_id
-    FROM
-      messages
-    GROUP BY
-      chat_id
-    HAVING
-      COUNT(*) > 0
-  )
-ORDER BY
-  message_count DESC
+const getOauthToken = `-- name: GetOauthToken :one
+SELECT
+  id, refresh_token, oauth_provider, account, scopes, created_at, last_refreshed_at, user_id
+FROM
+  oauth_tokens
+WHERE
+  id = ?
 `
 
-type GetChatsWithMessageCountRow struct {
-	ID           string
-	MessageCount int64
+func (q *Queries) GetOauthToken(ctx context.Context, id string) (OauthToken, error) {
+	row := q.db.QueryRowContext(ctx, getOauthToken, id)
+	var i OauthToken
+	err := row.Scan(
+		&i.ID,
+		&i.RefreshToken,
+		&i.OauthProvider,
+		&i.Account,
+		&i.Scopes,
+		&i.CreatedAt,
+		&i.LastRefreshedAt,
+		&i.UserID,
+	)
+	return i, err
 }
 
-func (q *Queries) GetChatsWithMessageCount(ctx context.Context) ([]GetChatsWithMessageCountRow, error) {
-	rows, err := q.db.QueryContext(ctx, getChatsWithMessageCount)
+const getOauthTokenByProvider = `-- name: GetOauthTokenByProvider :one
+SELECT
+  id, refresh_token, oauth_provider, account, scopes, created_at, last_refreshed_at, user_id
+FROM
+  oauth_tokens
+WHERE
+  oauth_tokens.user_id = ?
+  AND oauth_tokens.oauth_provider = ?
+`
+
+type GetOauthTokenByProviderParams struct {
+	UserID        sql.NullString
+	OauthProvider string
+}
+
+func (q *Queries) GetOauthTokenByProvider(ctx context.Context, arg GetOauthTokenByProviderParams) (OauthToken, error) {
+	row := q.db.QueryRowContext(ctx, getOauthTokenByProvider, arg.UserID, arg.OauthProvider)
+	var i OauthToken
+	err := row.Scan(
+		&i.ID,
+		&i.RefreshToken,
+		&i.OauthProvider,
+		&i.Account,
+		&i.Scopes,
+		&i.CreatedAt,
+		&i.LastRefreshedAt,
+		&i.UserID,
+	)
+	return i, err
+}
+
+const getOauthTokens = `-- name: GetOauthTokens :many
+SELECT
+  id, refresh_token, oauth_provider, account, scopes, created_at, last_refreshed_at, user_id
+FROM
+  oauth_tokens
+WHERE
+  user_id = ?
+ORDER BY
+  created_at
+`
+
+func (q *Queries) GetOauthTokens(ctx context.Context, userID sql.NullString) ([]OauthToken, error) {
+	rows, err := q.db.QueryContext(ctx, getOauthTokens, userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []GetChatsWithMessageCountRow
+	var items []OauthToken
 	for rows.Next() {
-		var i GetChatsWithMessageCountRow
-		if err := rows.Scan(&i.ID, &i.MessageCount); err != nil {
+		var i OauthToken
+		if err := rows.Scan(
+			&i.ID,
+			&i.RefreshToken,
+			&i.OauthProvider,
+			&i.Account,
+			&i.Scopes,
+			&i.CreatedAt,
+			&i.LastRefreshedAt,
+			&i.UserID,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -203,93 +1224,54 @@ func (q *Queries) GetChatsWithMessageCount(ctx context.Context) ([]GetChatsWithM
 	return items, nil
 }
 
-const getMCPServerImage = `-- name: GetMCPServerImage :one
+const getSecret = `-- name: GetSecret :one
 SELECT
-  images.id, images.slug, images.version, images.name, images.docker_image, images.type, images.oauth_provider, images.env_schema,
-  providers.name as provider_name,
-  providers.client_id,
-  providers.client_secret
+  name, value, created_at, updated_at
 FROM
-  mcp_server_images AS images
-  LEFT JOIN oauth_providers AS providers ON images.oauth_provider = providers.name
+  secrets
 WHERE
-  images.id = ?
+  name = ?
 `
 
-type GetMCPServerImageRow struct {
-	ID            string
-	Slug          string
-	Version       int64
-	Name          string
-	DockerImage   string
-	Type          string
-	OauthProvider sql.NullString
-	EnvSchema     models.EnvSchema
-	ProviderName  sql.NullString
-	ClientID      sql.NullString
-	ClientSecret  sql.NullString
-}
-
-func (q *Queries) GetMCPServerImage(ctx context.Context, id string) (GetMCPServerImageRow, error) {
-	row := q.db.QueryRowContext(ctx, getMCPServerImage, id)
-	var i GetMCPServerImageRow
+func (q *Queries) GetSecret(ctx context.Context, name string) (Secret, error) {
+	row := q.db.QueryRowContext(ctx, getSecret, name)
+	var i Secret
 	err := row.Scan(
-		&i.ID,
-		&i.Slug,
-		&i.Version,
 		&i.Name,
-		&i.DockerImage,
-		&i.Type,
-		&i.OauthProvider,
-		&i.EnvSchema,
-		&i.ProviderName,
-		&i.ClientID,
-		&i.ClientSecret,
+		&i.Value,
+		&i.CreatedAt,
+		&i.UpdatedAt,
 	)
 	return i, err
 }
 
-const getMCPServerInstances = `-- name: GetMCPServerInstances :many
+const getSecrets = `-- name: GetSecrets :many
 SELECT
-  inst.id as instance_id,
-  inst.address,
-  img.id AS image_id,
-  tool.name as tool_name,
-  tool.description as tool_desc,
-  tool.schema as tool_schema
+  name,
+  created_at,
+  updated_at
 FROM
-  mcp_server_instances inst
-  LEFT JOIN mcp_server_images AS img ON inst.slug = img.slug
-  AND inst.version = img.version
-  LEFT JOIN mcp_server_tools as tool ON img.id = tool.image_id
+  secrets
+ORDER BY
+  name
 `
 
-type GetMCPServerInstancesRow struct {
-	InstanceID string
-	Address    string
-	ImageID    sql.NullString
-	ToolName   sql.NullString
-	ToolDesc   sql.NullString
-	ToolSchema sql.NullString
+type GetSecretsRow struct {
+	Name      string
+	CreatedAt sql.NullTime
+	UpdatedAt sql.NullTime
 }
 
-func (q *Queries) GetMCPServerInstances(ctx context.Context) ([]GetMCPServerInstancesRow, error) {
-	rows, err := q.db.QueryContext(ctx, getMCPServerInstances)
+func (q *Queries) GetSecrets(ctx context.Context) ([]GetSecretsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getSecrets)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []GetMCPServerInstancesRow
+	var items []GetSecretsRow
 	for rows.Next() {
-		var i GetMCPServerInstancesRow
-		if err := rows.Scan(
-			&i.InstanceID,
-			&i.Address,
-			&i.ImageID,
-			&i.ToolName,
-			&i.ToolDesc,
-			&i.ToolSchema,
-		); err != nil {
+		var i GetSecretsRow
+		if err := rows.Scan(&i.Name, &i.CreatedAt, &i.UpdatedAt); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -303,19 +1285,49 @@ func (q *Queries) GetMCPServerInstances(ctx context.Context) ([]GetMCPServerInst
 	return items, nil
 }
 
-const getOauthTokenByProvider = `-- name: GetOauthTokenByProvider :one
+const getUser = `-- name: GetUser :one
 SELECT
-  id, refresh_token, oauth_provider
+  id, email, name, created_at, last_login_at, is_admin
 FROM
-  oauth_tokens
+  users
 WHERE
-  oauth_tokens.oauth_provider = ?
+  id = ?
 `
 
-func (q *Queries) GetOauthTokenByProvider(ctx context.Context, oauthProvider string) (OauthToken, error) {
-	row := q.db.QueryRowContext(ctx, getOauthTokenByProvider, oauthProvider)
-	var i OauthToken
-	err := row.Scan(&i.ID, &i.RefreshToken, &i.OauthProvider)
+func (q *Queries) GetUser(ctx context.Context, id string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.Name,
+		&i.CreatedAt,
+		&i.LastLoginAt,
+		&i.IsAdmin,
+	)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT
+  id, email, name, created_at, last_login_at, is_admin
+FROM
+  users
+WHERE
+  email = ?
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.Name,
+		&i.CreatedAt,
+		&i.LastLoginAt,
+		&i.IsAdmin,
+	)
 	return i, err
 }
 
@@ -328,6 +1340,7 @@ WHERE
   chat_id = ?
 `
 
+// *********************************
 func (q *Queries) GetViewChatMessges(ctx context.Context, chatID string) ([]VGetChatMessage, error) {
 	rows, err := q.db.QueryContext(ctx, getViewChatMessges, chatID)
 	if err != nil {
@@ -379,19 +1392,98 @@ func (q *Queries) InsertAIMessagePart(ctx context.Context, arg InsertAIMessagePa
 	return id, err
 }
 
+const insertAPIKey = `-- name: InsertAPIKey :exec
+INSERT INTO
+  api_keys (id, user_id, name, prefix, key_hash, scopes, expires_at)
+VALUES
+  (?, ?, ?, ?, ?, ?, ?)
+`
+
+type InsertAPIKeyParams struct {
+	ID        string
+	UserID    string
+	Name      string
+	Prefix    string
+	KeyHash   string
+	Scopes    models.APIKeyScopes
+	ExpiresAt sql.NullTime
+}
+
+func (q *Queries) InsertAPIKey(ctx context.Context, arg InsertAPIKeyParams) error {
+	_, err := q.db.ExecContext(ctx, insertAPIKey,
+		arg.ID,
+		arg.UserID,
+		arg.Name,
+		arg.Prefix,
+		arg.KeyHash,
+		arg.Scopes,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
 const insertChat = `-- name: InsertChat :exec
 /*
 Chat Queries
 */
 INSERT INTO
-  chats (id)
+  chats (id, user_id)
 VALUES
-  (?)
+  (?, ?)
 `
 
+type InsertChatParams struct {
+	ID     string
+	UserID sql.NullString
+}
+
 // *********************************
-func (q *Queries) InsertChat(ctx context.Context, id string) error {
-	_, err := q.db.ExecContext(ctx, insertChat, id)
+func (q *Queries) InsertChat(ctx context.Context, arg InsertChatParams) error {
+	_, err := q.db.ExecContext(ctx, insertChat, arg.ID, arg.UserID)
+	return err
+}
+
+const insertMCPSamplingRequest = `-- name: InsertMCPSamplingRequest :exec
+INSERT INTO
+  mcp_sampling_requests (
+    id,
+    instance_id,
+    image_id,
+    status,
+    model,
+    max_tokens,
+    request,
+    response,
+    error
+  )
+VALUES
+  (?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+type InsertMCPSamplingRequestParams struct {
+	ID         string
+	InstanceID string
+	ImageID    string
+	Status     string
+	Model      sql.NullString
+	MaxTokens  int64
+	Request    string
+	Response   sql.NullString
+	Error      sql.NullString
+}
+
+func (q *Queries) InsertMCPSamplingRequest(ctx context.Context, arg InsertMCPSamplingRequestParams) error {
+	_, err := q.db.ExecContext(ctx, insertMCPSamplingRequest,
+		arg.ID,
+		arg.InstanceID,
+		arg.ImageID,
+		arg.Status,
+		arg.Model,
+		arg.MaxTokens,
+		arg.Request,
+		arg.Response,
+		arg.Error,
+	)
 	return err
 }
 
@@ -408,89 +1500,221 @@ INSERT INTO
     docker_image,
     type,
     oauth_provider,
-    env_schema
+    env_schema,
+    launcher,
+    tool_timeouts,
+    sampling_policy,
+    warm_pool,
+    oauth_scopes
   )
 VALUES
-  (?, ?, ?, ?, ?, ?, ?, ?)
+  (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 `
 
 type InsertMCPServerImageParams struct {
-	ID            string
-	Slug          string
-	Version       int64
-	Name          string
-	DockerImage   string
-	Type          string
-	OauthProvider sql.NullString
-	EnvSchema     models.EnvSchema
+	ID             string
+	Slug           string
+	Version        int64
+	Name           string
+	DockerImage    string
+	Type           string
+	OauthProvider  sql.NullString
+	EnvSchema      models.EnvSchema
+	Launcher       models.MCPLauncher
+	ToolTimeouts   models.ToolTimeouts
+	SamplingPolicy models.SamplingPolicy
+	WarmPool       models.WarmPool
+	OauthScopes    models.OauthScopes
+}
+
+// *********************************
+func (q *Queries) InsertMCPServerImage(ctx context.Context, arg InsertMCPServerImageParams) error {
+	_, err := q.db.ExecContext(ctx, insertMCPServerImage,
+		arg.ID,
+		arg.Slug,
+		arg.Version,
+		arg.Name,
+		arg.DockerImage,
+		arg.Type,
+		arg.OauthProvider,
+		arg.EnvSchema,
+		arg.Launcher,
+		arg.ToolTimeouts,
+		arg.SamplingPolicy,
+		arg.WarmPool,
+		arg.OauthScopes,
+	)
+	return err
+}
+
+const insertMCPServerInstance = `-- name: InsertMCPServerInstance :exec
+/*
+MCP Server Instance Queries
+*/
+INSERT INTO
+  mcp_server_instances (id, slug, version, address, env, user_id)
+VALUES
+  (?, ?, ?, ?, ?, ?)
+`
+
+type InsertMCPServerInstanceParams struct {
+	ID      string
+	Slug    string
+	Version int64
+	Address string
+	Env     models.InstanceEnv
+	UserID  sql.NullString
+}
+
+// *********************************
+func (q *Queries) InsertMCPServerInstance(ctx context.Context, arg InsertMCPServerInstanceParams) error {
+	_, err := q.db.ExecContext(ctx, insertMCPServerInstance,
+		arg.ID,
+		arg.Slug,
+		arg.Version,
+		arg.Address,
+		arg.Env,
+		arg.UserID,
+	)
+	return err
+}
+
+const insertMCPServerInstanceColdStart = `-- name: InsertMCPServerInstanceColdStart :exec
+INSERT INTO
+  mcp_server_instance_cold_starts (id, instance_id, duration_ms, error)
+VALUES
+  (?, ?, ?, ?)
+`
+
+type InsertMCPServerInstanceColdStartParams struct {
+	ID         string
+	InstanceID string
+	DurationMs int64
+	Error      sql.NullString
+}
+
+func (q *Queries) InsertMCPServerInstanceColdStart(ctx context.Context, arg InsertMCPServerInstanceColdStartParams) error {
+	_, err := q.db.ExecContext(ctx, insertMCPServerInstanceColdStart,
+		arg.ID,
+		arg.InstanceID,
+		arg.DurationMs,
+		arg.Error,
+	)
+	return err
+}
+
+const insertMCPServerInstanceTool = `-- name: InsertMCPServerInstanceTool :exec
+INSERT INTO
+  mcp_server_tools (id, name, description, schema, image_id, timeout_seconds)
+VALUES
+  (?, ?, ?, ?, ?, ?)
+`
+
+type InsertMCPServerInstanceToolParams struct {
+	ID             string
+	Name           string
+	Description    sql.NullString
+	Schema         string
+	ImageID        string
+	TimeoutSeconds int64
+}
+
+func (q *Queries) InsertMCPServerInstanceTool(ctx context.Context, arg InsertMCPServerInstanceToolParams) error {
+	_, err := q.db.ExecContext(ctx, insertMCPServerInstanceTool,
+		arg.ID,
+		arg.Name,
+		arg.Description,
+		arg.Schema,
+		arg.ImageID,
+		arg.TimeoutSeconds,
+	)
+	return err
+}
+
+const insertMCPServerPrompt = `-- name: InsertMCPServerPrompt :exec
+INSERT INTO
+  mcp_server_prompts (id, name, description, arguments, image_id)
+VALUES
+  (?, ?, ?, ?, ?)
+`
+
+type InsertMCPServerPromptParams struct {
+	ID          string
+	Name        string
+	Description sql.NullString
+	Arguments   models.MCPPromptArguments
+	ImageID     string
 }
 
-// *********************************
-func (q *Queries) InsertMCPServerImage(ctx context.Context, arg InsertMCPServerImageParams) error {
-	_, err := q.db.ExecContext(ctx, insertMCPServerImage,
+func (q *Queries) InsertMCPServerPrompt(ctx context.Context, arg InsertMCPServerPromptParams) error {
+	_, err := q.db.ExecContext(ctx, insertMCPServerPrompt,
 		arg.ID,
-		arg.Slug,
-		arg.Version,
 		arg.Name,
-		arg.DockerImage,
-		arg.Type,
-		arg.OauthProvider,
-		arg.EnvSchema,
+		arg.Description,
+		arg.Arguments,
+		arg.ImageID,
 	)
 	return err
 }
 
-const insertMCPServerInstance = `-- name: InsertMCPServerInstance :exec
-/*
-MCP Server Instance Queries
-*/
+const insertMCPServerResource = `-- name: InsertMCPServerResource :exec
 INSERT INTO
-  mcp_server_instances (id, slug, version, address, env)
+  mcp_server_resources (id, uri, name, description, mime_type, image_id)
 VALUES
-  (?, ?, ?, ?, ?)
+  (?, ?, ?, ?, ?, ?)
 `
 
-type InsertMCPServerInstanceParams struct {
-	ID      string
-	Slug    string
-	Version int64
-	Address string
-	Env     interface{}
+type InsertMCPServerResourceParams struct {
+	ID          string
+	Uri         string
+	Name        string
+	Description sql.NullString
+	MimeType    sql.NullString
+	ImageID     string
 }
 
-// *********************************
-func (q *Queries) InsertMCPServerInstance(ctx context.Context, arg InsertMCPServerInstanceParams) error {
-	_, err := q.db.ExecContext(ctx, insertMCPServerInstance,
+func (q *Queries) InsertMCPServerResource(ctx context.Context, arg InsertMCPServerResourceParams) error {
+	_, err := q.db.ExecContext(ctx, insertMCPServerResource,
 		arg.ID,
-		arg.Slug,
-		arg.Version,
-		arg.Address,
-		arg.Env,
+		arg.Uri,
+		arg.Name,
+		arg.Description,
+		arg.MimeType,
+		arg.ImageID,
 	)
 	return err
 }
 
-const insertMCPServerInstanceTool = `-- name: InsertMCPServerInstanceTool :exec
+const insertMCPServerResourceTemplate = `-- name: InsertMCPServerResourceTemplate :exec
 INSERT INTO
-  mcp_server_tools (id, name, description, schema, image_id)
+  mcp_server_resource_templates (
+    id,
+    uri_template,
+    name,
+    description,
+    mime_type,
+    image_id
+  )
 VALUES
-  (?, ?, ?, ?, ?)
+  (?, ?, ?, ?, ?, ?)
 `
 
-type InsertMCPServerInstanceToolParams struct {
+type InsertMCPServerResourceTemplateParams struct {
 	ID          string
+	UriTemplate string
 	Name        string
 	Description sql.NullString
-	Schema      string
+	MimeType    sql.NullString
 	ImageID     string
 }
 
-func (q *Queries) InsertMCPServerInstanceTool(ctx context.Context, arg InsertMCPServerInstanceToolParams) error {
-	_, err := q.db.ExecContext(ctx, insertMCPServerInstanceTool,
+func (q *Queries) InsertMCPServerResourceTemplate(ctx context.Context, arg InsertMCPServerResourceTemplateParams) error {
+	_, err := q.db.ExecContext(ctx, insertMCPServerResourceTemplate,
 		arg.ID,
+		arg.UriTemplate,
 		arg.Name,
 		arg.Description,
-		arg.Schema,
+		arg.MimeType,
 		arg.ImageID,
 	)
 	return err
@@ -522,57 +1746,39 @@ func (q *Queries) InsertMessage(ctx context.Context, arg InsertMessageParams) er
 	return err
 }
 
-const insertOauthProvider = `-- name: InsertOauthProvider :exec
-/*
-OAUTH token queries
-*/
-INSERT INTO
-  oauth_providers (
-    name,
-    client_id,
-    client_secret,
-    callback_url,
-    scopes
-  )
-VALUES
-  (?, ?, ?, ?, ?)
-`
-
-type InsertOauthProviderParams struct {
-	Name         string
-	ClientID     string
-	ClientSecret string
-	CallbackUrl  string
-	Scopes       sql.NullString
-}
-
-// *********************************
-func (q *Queries) InsertOauthProvider(ctx context.Context, arg InsertOauthProviderParams) error {
-	_, err := q.db.ExecContext(ctx, insertOauthProvider,
-		arg.Name,
-		arg.ClientID,
-		arg.ClientSecret,
-		arg.CallbackUrl,
-		arg.Scopes,
-	)
-	return err
-}
-
 const insertOauthToken = `-- name: InsertOauthToken :exec
 INSERT INTO
-  oauth_tokens (id, refresh_token, oauth_provider)
+  oauth_tokens (
+    id,
+    refresh_token,
+    oauth_provider,
+    account,
+    scopes,
+    user_id,
+    created_at
+  )
 VALUES
-  (?, ?, ?)
+  (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
 `
 
 type InsertOauthTokenParams struct {
 	ID            string
 	RefreshToken  string
 	OauthProvider string
+	Account       sql.NullString
+	Scopes        sql.NullString
+	UserID        sql.NullString
 }
 
 func (q *Queries) InsertOauthToken(ctx context.Context, arg InsertOauthTokenParams) error {
-	_, err := q.db.ExecContext(ctx, insertOauthToken, arg.ID, arg.RefreshToken, arg.OauthProvider)
+	_, err := q.db.ExecContext(ctx, insertOauthToken,
+		arg.ID,
+		arg.RefreshToken,
+		arg.OauthProvider,
+		arg.Account,
+		arg.Scopes,
+		arg.UserID,
+	)
 	return err
 }
 
@@ -619,9 +1825,9 @@ func (q *Queries) InsertToolCallPart(ctx context.Context, arg InsertToolCallPart
 
 const insertToolCallResult = `-- name: InsertToolCallResult :exec
 INSERT INTO
-  tool_call_result (message_id, tool_call_id, name, content, is_error)
+  tool_call_result (message_id, tool_call_id, name, content, is_error, status)
 VALUES
-  (?, ?, ?, ?, ?)
+  (?, ?, ?, ?, ?, ?)
 `
 
 type InsertToolCallResultParams struct {
@@ -630,6 +1836,7 @@ type InsertToolCallResultParams struct {
 	Name       string
 	Content    string
 	IsError    bool
+	Status     string
 }
 
 func (q *Queries) InsertToolCallResult(ctx context.Context, arg InsertToolCallResultParams) error {
@@ -639,24 +1846,351 @@ func (q *Queries) InsertToolCallResult(ctx context.Context, arg InsertToolCallRe
 		arg.Name,
 		arg.Content,
 		arg.IsError,
+		arg.Status,
+	)
+	return err
+}
+
+const insertUser = `-- name: InsertUser :exec
+/*
+User queries
+*/
+INSERT INTO
+  users (id, email, name, last_login_at)
+VALUES
+  (?, ?, ?, CURRENT_TIMESTAMP)
+`
+
+type InsertUserParams struct {
+	ID    string
+	Email string
+	Name  sql.NullString
+}
+
+// *********************************
+func (q *Queries) InsertUser(ctx context.Context, arg InsertUserParams) error {
+	_, err := q.db.ExecContext(ctx, insertUser, arg.ID, arg.Email, arg.Name)
+	return err
+}
+
+const markOauthTokenRefreshed = `-- name: MarkOauthTokenRefreshed :exec
+UPDATE oauth_tokens
+SET
+  last_refreshed_at = CURRENT_TIMESTAMP
+WHERE
+  user_id = ?
+  AND oauth_provider = ?
+`
+
+type MarkOauthTokenRefreshedParams struct {
+	UserID        sql.NullString
+	OauthProvider string
+}
+
+func (q *Queries) MarkOauthTokenRefreshed(ctx context.Context, arg MarkOauthTokenRefreshedParams) error {
+	_, err := q.db.ExecContext(ctx, markOauthTokenRefreshed, arg.UserID, arg.OauthProvider)
+	return err
+}
+
+const reconnectOauthTokenByProvider = `-- name: ReconnectOauthTokenByProvider :exec
+UPDATE oauth_tokens
+SET
+  refresh_token = ?,
+  account = ?,
+  scopes = ?,
+  created_at = CURRENT_TIMESTAMP,
+  last_refreshed_at = NULL
+WHERE
+  user_id = ?
+  AND oauth_provider = ?
+`
+
+type ReconnectOauthTokenByProviderParams struct {
+	RefreshToken  string
+	Account       sql.NullString
+	Scopes        sql.NullString
+	UserID        sql.NullString
+	OauthProvider string
+}
+
+func (q *Queries) ReconnectOauthTokenByProvider(ctx context.Context, arg ReconnectOauthTokenByProviderParams) error {
+	_, err := q.db.ExecContext(ctx, reconnectOauthTokenByProvider,
+		arg.RefreshToken,
+		arg.Account,
+		arg.Scopes,
+		arg.UserID,
+		arg.OauthProvider,
 	)
 	return err
 }
 
+const setUserAdmin = `-- name: SetUserAdmin :exec
+UPDATE users
+SET
+  is_admin = ?
+WHERE
+  id = ?
+`
+
+type SetUserAdminParams struct {
+	IsAdmin bool
+	ID      string
+}
+
+func (q *Queries) SetUserAdmin(ctx context.Context, arg SetUserAdminParams) error {
+	_, err := q.db.ExecContext(ctx, setUserAdmin, arg.IsAdmin, arg.ID)
+	return err
+}
+
+const stopMCPServerInstancesByAddress = `-- name: StopMCPServerInstancesByAddress :exec
+UPDATE mcp_server_instances
+SET
+  runtime_state = 'STOPPED',
+  address = ''
+WHERE
+  address = ?
+`
+
+func (q *Queries) StopMCPServerInstancesByAddress(ctx context.Context, address string) error {
+	_, err := q.db.ExecContext(ctx, stopMCPServerInstancesByAddress, address)
+	return err
+}
+
+const touchAPIKey = `-- name: TouchAPIKey :exec
+UPDATE api_keys
+SET
+  last_used_at = CURRENT_TIMESTAMP
+WHERE
+  id = ?
+`
+
+func (q *Queries) TouchAPIKey(ctx context.Context, id string) error {
+	_, err := q.db.ExecContext(ctx, touchAPIKey, id)
+	return err
+}
+
+const touchMCPServerInstance = `-- name: TouchMCPServerInstance :exec
+UPDATE mcp_server_instances
+SET
+  last_used_at = CURRENT_TIMESTAMP
+WHERE
+  id = ?
+`
+
+func (q *Queries) TouchMCPServerInstance(ctx context.Context, id string) error {
+	_, err := q.db.ExecContext(ctx, touchMCPServerInstance, id)
+	return err
+}
+
+const updateMCPServerInstanceDesiredState = `-- name: UpdateMCPServerInstanceDesiredState :exec
+UPDATE mcp_server_instances
+SET
+  desired_state = ?
+WHERE
+  id = ?
+`
+
+type UpdateMCPServerInstanceDesiredStateParams struct {
+	DesiredState string
+	ID           string
+}
+
+func (q *Queries) UpdateMCPServerInstanceDesiredState(ctx context.Context, arg UpdateMCPServerInstanceDesiredStateParams) error {
+	_, err := q.db.ExecContext(ctx, updateMCPServerInstanceDesiredState, arg.DesiredState, arg.ID)
+	return err
+}
+
+const updateMCPServerInstanceRuntimeState = `-- name: UpdateMCPServerInstanceRuntimeState :exec
+UPDATE mcp_server_instances
+SET
+  runtime_state = ?,
+  address = ?
+WHERE
+  id = ?
+`
+
+type UpdateMCPServerInstanceRuntimeStateParams struct {
+	RuntimeState string
+	Address      string
+	ID           string
+}
+
+func (q *Queries) UpdateMCPServerInstanceRuntimeState(ctx context.Context, arg UpdateMCPServerInstanceRuntimeStateParams) error {
+	_, err := q.db.ExecContext(ctx, updateMCPServerInstanceRuntimeState, arg.RuntimeState, arg.Address, arg.ID)
+	return err
+}
+
+const updateMCPServerInstanceVersion = `-- name: UpdateMCPServerInstanceVersion :exec
+UPDATE mcp_server_instances
+SET
+  version = ?,
+  env = ?
+WHERE
+  id = ?
+`
+
+type UpdateMCPServerInstanceVersionParams struct {
+	Version int64
+	Env     models.InstanceEnv
+	ID      string
+}
+
+func (q *Queries) UpdateMCPServerInstanceVersion(ctx context.Context, arg UpdateMCPServerInstanceVersionParams) error {
+	_, err := q.db.ExecContext(ctx, updateMCPServerInstanceVersion, arg.Version, arg.Env, arg.ID)
+	return err
+}
+
 const updateOauthTokenByProivder = `-- name: UpdateOauthTokenByProivder :exec
 UPDATE oauth_tokens
 SET
   refresh_token = ?
 WHERE
-  oauth_provider = ?
+  user_id = ?
+  AND oauth_provider = ?
 `
 
 type UpdateOauthTokenByProivderParams struct {
 	RefreshToken  string
+	UserID        sql.NullString
 	OauthProvider string
 }
 
 func (q *Queries) UpdateOauthTokenByProivder(ctx context.Context, arg UpdateOauthTokenByProivderParams) error {
-	_, err := q.db.ExecContext(ctx, updateOauthTokenByProivder, arg.RefreshToken, arg.OauthProvider)
+	_, err := q.db.ExecContext(ctx, updateOauthTokenByProivder, arg.RefreshToken, arg.UserID, arg.OauthProvider)
+	return err
+}
+
+const updateUserLogin = `-- name: UpdateUserLogin :exec
+UPDATE users
+SET
+  name = ?,
+  last_login_at = CURRENT_TIMESTAMP
+WHERE
+  id = ?
+`
+
+type UpdateUserLoginParams struct {
+	Name sql.NullString
+	ID   string
+}
+
+func (q *Queries) UpdateUserLogin(ctx context.Context, arg UpdateUserLoginParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserLogin, arg.Name, arg.ID)
+	return err
+}
+
+const upsertMCPServerInstanceTools = `-- name: UpsertMCPServerInstanceTools :exec
+INSERT INTO
+  mcp_server_instance_tools (instance_id, tools)
+VALUES
+  (?, ?) ON CONFLICT (instance_id) DO
+UPDATE
+SET
+  tools = excluded.tools,
+  updated_at = CURRENT_TIMESTAMP
+`
+
+type UpsertMCPServerInstanceToolsParams struct {
+	InstanceID string
+	Tools      string
+}
+
+func (q *Queries) UpsertMCPServerInstanceTools(ctx context.Context, arg UpsertMCPServerInstanceToolsParams) error {
+	_, err := q.db.ExecContext(ctx, upsertMCPServerInstanceTools, arg.InstanceID, arg.Tools)
+	return err
+}
+
+const upsertOauthProvider = `-- name: UpsertOauthProvider :exec
+/*
+OAUTH token queries
+*/
+INSERT INTO
+  oauth_providers (
+    name,
+    kind,
+    client_id,
+    client_secret,
+    callback_url,
+    scopes,
+    auth_url,
+    token_url,
+    user_info_url,
+    pkce,
+    auth_params,
+    revoke_url
+  )
+VALUES
+  (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (name) DO
+UPDATE
+SET
+  kind = excluded.kind,
+  client_id = excluded.client_id,
+  client_secret = excluded.client_secret,
+  callback_url = excluded.callback_url,
+  scopes = excluded.scopes,
+  auth_url = excluded.auth_url,
+  token_url = excluded.token_url,
+  user_info_url = excluded.user_info_url,
+  pkce = excluded.pkce,
+  auth_params = excluded.auth_params,
+  revoke_url = excluded.revoke_url
+`
+
+type UpsertOauthProviderParams struct {
+	Name         string
+	Kind         models.OauthProviderKind
+	ClientID     string
+	ClientSecret string
+	CallbackUrl  string
+	Scopes       sql.NullString
+	AuthUrl      sql.NullString
+	TokenUrl     sql.NullString
+	UserInfoUrl  sql.NullString
+	Pkce         bool
+	AuthParams   models.OauthAuthParams
+	RevokeUrl    sql.NullString
+}
+
+// *********************************
+func (q *Queries) UpsertOauthProvider(ctx context.Context, arg UpsertOauthProviderParams) error {
+	_, err := q.db.ExecContext(ctx, upsertOauthProvider,
+		arg.Name,
+		arg.Kind,
+		arg.ClientID,
+		arg.ClientSecret,
+		arg.CallbackUrl,
+		arg.Scopes,
+		arg.AuthUrl,
+		arg.TokenUrl,
+		arg.UserInfoUrl,
+		arg.Pkce,
+		arg.AuthParams,
+		arg.RevokeUrl,
+	)
+	return err
+}
+
+const upsertSecret = `-- name: UpsertSecret :exec
+/*
+Secret Queries
+*/
+INSERT INTO
+  secrets (name, value)
+VALUES
+  (?, ?) ON CONFLICT (name) DO
+UPDATE
+SET
+  value = excluded.value,
+  updated_at = CURRENT_TIMESTAMP
+`
+
+type UpsertSecretParams struct {
+	Name  string
+	Value []byte
+}
+
+// *********************************
+func (q *Queries) UpsertSecret(ctx context.Context, arg UpsertSecretParams) error {
+	_, err := q.db.ExecContext(ctx, upsertSecret, arg.Name, arg.Value)
 	return err
 }
@@ -5,12 +5,51 @@ const CLIENT_ID = process.env.GOOGLE_CLIENT_ID || process.env.CLIENT_ID;
 const CLIENT_SECRET = process.env.GOOGLE_CLIENT_SECRET || process.env.CLIENT_SECRET;
 const REFRESH_TOKEN = process.env.TEST_GOOGLE_CALENDAR_REFRESH_TOKEN || process.env.REFRESH_TOKEN;
 
+//Set when xtrn owns the refresh token, access tokens are fetched from its token broker instead
+const TOKEN_URL = process.env.TOKEN_URL;
+const TOKEN_KEY = process.env.TOKEN_KEY;
+
 const client = new OAuth2Client(CLIENT_ID, CLIENT_SECRET, "http://localhost");
 
 let accessToken: string = "";
 let expiresAt: number = 0;
 
+const brokerOauthClient = async () => {
+  const res = await fetch(TOKEN_URL as string, {
+    method: "POST",
+    headers: { Authorization: `Bearer ${TOKEN_KEY}` },
+  }).catch((err) => {
+    throw new UnknownAuthError(err?.message);
+  });
+
+  //409 = refresh token was revoked, the user has to sign in again
+  if (res.status === 409) {
+    throw new InvalidGrantError();
+  }
+
+  if (!res.ok) {
+    throw new UnknownAuthError(`Token broker responded with ${res.status}`);
+  }
+
+  const body = (await res.json()) as { access_token?: string; expires_at?: string };
+  if (!body.access_token) {
+    throw new MissingTokenFieldsError();
+  }
+
+  client.setCredentials({
+    access_token: body.access_token,
+    expiry_date: body.expires_at ? Date.parse(body.expires_at) : undefined,
+  });
+
+  return client;
+};
+
 const googleOauthClient = async () => {
+  //The broker caches and refreshes tokens so it's asked on every call
+  if (TOKEN_URL && TOKEN_KEY) {
+    return brokerOauthClient();
+  }
+
   const now = Date.now();
 
   //Get access token if no token or expired
@@ -0,0 +1,300 @@
+package egress_proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/db/models"
+)
+
+/*
+HTTP proxy for EGRESS_ALLOWLIST containers. It is the only thing on the egress network that can
+reach the internet, and only connects containers to the hosts in their
+models.EGRESS_ALLOWED_HOSTS_LABEL label. Containers are found by source address through the
+docker API. Plain HTTP requests are forwarded, HTTPS goes through CONNECT tunnels.
+*/
+type Proxy struct {
+	Network   string // Docker network the containers are on
+	Docker    *http.Client
+	Logger    *log.Logger
+	ErrLogger *log.Logger
+
+	mu      sync.Mutex
+	allowed map[string]egressContainer // Container IP -> container
+}
+
+// IPs are reused once a container exits so every connection checks the container still has it
+type egressContainer struct {
+	ID    string
+	Hosts []string
+}
+
+const DIAL_TIMEOUT = time.Second * 10
+
+// Talks to the docker API over its unix socket
+func NewDockerClient(socketPath string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _ string, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+			},
+		},
+		Timeout: time.Second * 10,
+	}
+}
+
+func NewProxy(network string, docker *http.Client, logger *log.Logger, errLogger *log.Logger) *Proxy {
+	return &Proxy{
+		Network:   network,
+		Docker:    docker,
+		Logger:    logger,
+		ErrLogger: errLogger,
+		allowed:   map[string]egressContainer{},
+	}
+}
+
+type dockerContainer struct {
+	ID              string            `json:"Id"`
+	Labels          map[string]string `json:"Labels"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress string `json:"IPAddress"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+// Reloads the allowed hosts of the containers on the network
+func (p *Proxy) refresh() error {
+	filters, _ := json.Marshal(map[string][]string{"network": {p.Network}})
+
+	res, err := p.Docker.Get("http://docker/containers/json?filters=" + url.QueryEscape(string(filters)))
+	if err != nil {
+		return fmt.Errorf("Failed to list containers - %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("Failed to list containers - status %d: %s", res.StatusCode, body)
+	}
+
+	containers := []dockerContainer{}
+	if err := json.NewDecoder(res.Body).Decode(&containers); err != nil {
+		return fmt.Errorf("Failed to decode containers - %w", err)
+	}
+
+	allowed := map[string]egressContainer{}
+
+	for _, c := range containers {
+		n, ok := c.NetworkSettings.Networks[p.Network]
+		if !ok || n.IPAddress == "" {
+			continue
+		}
+
+		hosts := []string{}
+		if label := c.Labels[models.EGRESS_ALLOWED_HOSTS_LABEL]; label != "" {
+			hosts = strings.Split(label, ",")
+		}
+
+		allowed[n.IPAddress] = egressContainer{ID: c.ID, Hosts: hosts}
+	}
+
+	p.allowed = allowed
+
+	return nil
+}
+
+// Whether the container is still running on the network with ip
+func (p *Proxy) hasIP(containerID string, ip string) (bool, error) {
+	res, err := p.Docker.Get("http://docker/containers/" + url.PathEscape(containerID) + "/json")
+	if err != nil {
+		return false, fmt.Errorf("Failed to inspect container %s - %w", containerID, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return false, nil
+	} else if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(res.Body)
+		return false, fmt.Errorf("Failed to inspect container %s - status %d: %s", containerID, res.StatusCode, body)
+	}
+
+	var c struct {
+		dockerContainer
+		State struct {
+			Running bool `json:"Running"`
+		} `json:"State"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&c); err != nil {
+		return false, fmt.Errorf("Failed to decode container %s - %w", containerID, err)
+	}
+
+	n, ok := c.NetworkSettings.Networks[p.Network]
+
+	return c.State.Running && ok && n.IPAddress == ip, nil
+}
+
+/*
+Hosts the container at ip may connect to, none if it isn't a container on the network. The cached
+container is checked on every call and the containers are listed again when it no longer has ip
+(e.g. it exited and docker gave the ip to a new one) or ip isn't cached yet.
+*/
+func (p *Proxy) allowedHosts(ip string) ([]string, error) {
+	p.mu.Lock()
+	c, ok := p.allowed[ip]
+	p.mu.Unlock()
+
+	if ok {
+		current, err := p.hasIP(c.ID, ip)
+		if err != nil {
+			return nil, err
+		}
+		if current {
+			return c.Hosts, nil
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.refresh(); err != nil {
+		return nil, err
+	}
+
+	return p.allowed[ip].Hosts, nil
+}
+
+// Host and port a request goes to, the default port of its scheme if it has none
+func requestTarget(r *http.Request) (string, string) {
+	hostport := r.Host
+	defaultPort := "443"
+
+	if r.Method != http.MethodConnect {
+		hostport = r.URL.Host
+		if r.URL.Scheme == "http" {
+			defaultPort = "80"
+		}
+	}
+
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return hostport, defaultPort
+	}
+
+	return host, port
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ip, _, _ := net.SplitHostPort(r.RemoteAddr)
+	host, port := requestTarget(r)
+
+	allowed, err := p.allowedHosts(ip)
+	if err != nil {
+		p.ErrLogger.Print(err)
+		http.Error(w, "Failed to look up allowed hosts", http.StatusBadGateway)
+		return
+	}
+
+	if !models.EgressHostAllowed(allowed, host, port) {
+		p.Logger.Printf("DENIED %s -> %s:%s\n", ip, host, port)
+		http.Error(w, fmt.Sprintf("%s:%s is not in the allowlist", host, port), http.StatusForbidden)
+		return
+	}
+
+	p.Logger.Printf("ALLOWED %s -> %s:%s\n", ip, host, port)
+
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, host, port)
+		return
+	}
+
+	p.forward(w, r)
+}
+
+// CONNECT: the client talks to the host through the tunnel (TLS end to end)
+func (p *Proxy) tunnel(w http.ResponseWriter, host string, port string) {
+	upstream, err := net.DialTimeout("tcp", net.JoinHostPort(host, port), DIAL_TIMEOUT)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "Tunnelling is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	client, buf, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		p.ErrLogger.Printf("Failed to hijack connection - %v\n", err)
+		return
+	}
+
+	client.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n"))
+
+	//Bytes the client sent after the CONNECT request
+	if n := buf.Reader.Buffered(); n > 0 {
+		b, _ := buf.Reader.Peek(n)
+		upstream.Write(b)
+	}
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstream, client)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(client, upstream)
+		done <- struct{}{}
+	}()
+
+	<-done
+	client.Close()
+	upstream.Close()
+}
+
+// Headers that only apply to one connection, they aren't passed on
+var hopHeaders = []string{"Connection", "Proxy-Connection", "Keep-Alive", "Proxy-Authorization", "Proxy-Authenticate", "Te", "Trailer", "Transfer-Encoding", "Upgrade"}
+
+var forwardTransport = &http.Transport{
+	Proxy:       nil,
+	DialContext: (&net.Dialer{Timeout: DIAL_TIMEOUT}).DialContext,
+}
+
+// Plain HTTP: the request is sent on and the response copied back
+func (p *Proxy) forward(w http.ResponseWriter, r *http.Request) {
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	for _, h := range hopHeaders {
+		out.Header.Del(h)
+	}
+
+	res, err := forwardTransport.RoundTrip(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer res.Body.Close()
+
+	for _, h := range hopHeaders {
+		res.Header.Del(h)
+	}
+	for k, v := range res.Header {
+		w.Header()[k] = v
+	}
+
+	w.WriteHeader(res.StatusCode)
+	io.Copy(w, res.Body)
+}
@@ -0,0 +1,185 @@
+package mcp_instance_host
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	new_mcp_instance "github.com/AbhinavPalacharla/xtrn-personal/internal/new-mcp-instance"
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/types"
+)
+
+// Route the API serves hosted instances' /callTool, /getPrompt etc. on
+const ROUTE = "/instances/{instanceID}/mcp/"
+
+type hostedInstance struct {
+	server  *new_mcp_instance.HTTPServer
+	handler http.Handler
+}
+
+/*
+Runs MCP instances inside the API process. Each instance is a new_mcp_instance.App, the
+same as in a start-mcp-instance process, with its HTTP handlers served on ROUTE so callers
+that use an instance's address don't need to know where it runs.
+*/
+type Host struct {
+	apiAddress string
+
+	mu        sync.RWMutex
+	instances map[string]*hostedInstance
+}
+
+func NewHost(apiAddress string) *Host {
+	return &Host{
+		apiAddress: apiAddress,
+		instances:  map[string]*hostedInstance{},
+	}
+}
+
+func (h *Host) address(instanceID string) string {
+	return h.apiAddress + "/instances/" + instanceID + "/mcp"
+}
+
+func (h *Host) Serves(address string) bool {
+	return strings.HasPrefix(address, h.apiAddress+"/instances/") && strings.HasSuffix(address, "/mcp")
+}
+
+func (h *Host) get(instanceID string) *hostedInstance {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.instances[instanceID]
+}
+
+// Removes the instance if it is still the one that was hosted (it may have been restarted since)
+func (h *Host) remove(instanceID string, hosted *hostedInstance) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.instances[instanceID] == hosted {
+		delete(h.instances, instanceID)
+	}
+}
+
+func (h *Host) Start(inst *types.MCPServerInstance, toolTimeouts map[string]int) error {
+	app, err := new_mcp_instance.NewInProcessApp(new_mcp_instance.InstanceConfig{
+		InstanceID:         inst.InstanceID,
+		ImageID:            inst.ImageID,
+		DockerImage:        inst.DockerImage,
+		Launcher:           inst.Launcher,
+		ToolTimeouts:       toolTimeouts,
+		Env:                inst.InstanceEnv,
+		MaxConcurrentCalls: inst.MaxConcurrentCalls,
+	})
+	if err != nil {
+		return fmt.Errorf("%w - %s", types.ErrInstanceInitFailed, Redact(err.Error()))
+	}
+
+	server := new_mcp_instance.NewHTTPServer(app)
+	hosted := &hostedInstance{
+		server:  server,
+		handler: http.StripPrefix("/instances/"+inst.InstanceID+"/mcp", server.Handler()),
+	}
+
+	//A /kill or /stop stops the MCP server, the instance just has to stop being served
+	server.OnStop = func() {
+		h.remove(inst.InstanceID, hosted)
+	}
+
+	h.mu.Lock()
+	h.instances[inst.InstanceID] = hosted
+	h.mu.Unlock()
+
+	inst.Address = h.address(inst.InstanceID)
+
+	return nil
+}
+
+func (h *Host) take(instanceID string) (*hostedInstance, error) {
+	hosted := h.get(instanceID)
+	if hosted == nil {
+		return nil, fmt.Errorf("MCP instance %s is not running", instanceID)
+	}
+
+	h.remove(instanceID, hosted)
+
+	return hosted, nil
+}
+
+// Stops the instance's MCP server and keeps the instance
+func (h *Host) Stop(instanceID string) error {
+	hosted, err := h.take(instanceID)
+	if err != nil {
+		return err
+	}
+
+	hosted.server.Stop("Stopped")
+
+	return nil
+}
+
+// Stops the instance's MCP server and removes the instance
+func (h *Host) Kill(instanceID string) error {
+	hosted, err := h.take(instanceID)
+	if err != nil {
+		return err
+	}
+
+	hosted.server.Kill()
+
+	return nil
+}
+
+// Served on the API's listener so callers need the instance's key
+func (h *Host) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	instanceID := r.PathValue("instanceID")
+
+	instanceKey := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !VerifyInstanceKey(instanceID, instanceKey) {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  types.ErrInvalidInstanceKey.Error(),
+			Code: http.StatusUnauthorized,
+		})
+		return
+	}
+
+	hosted := h.get(instanceID)
+	if hosted == nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  fmt.Sprintf("MCP instance %s is not running", instanceID),
+			Code: http.StatusNotFound,
+		})
+		return
+	}
+
+	hosted.handler.ServeHTTP(w, r)
+}
+
+/*
+Calls a tool on a hosted instance without going through HTTP. Returns the status code and
+body /callTool would respond with, ok is false if the instance isn't hosted here (nil Host included).
+*/
+func (h *Host) CallTool(ctx context.Context, instanceID string, toolUseID string, name string, args map[string]any, onEvent func(types.ToolCallEvent)) (int, []byte, bool) {
+	if h == nil {
+		return 0, nil, false
+	}
+
+	hosted := h.get(instanceID)
+	if hosted == nil {
+		return 0, nil, false
+	}
+
+	status, body := hosted.server.CallTool(ctx, &new_mcp_instance.ToolCallReq{
+		ToolUseID: toolUseID,
+		Name:      name,
+		Arguments: args,
+	}, onEvent)
+
+	bodyJSON, _ := json.Marshal(body)
+
+	return status, bodyJSON, true
+}
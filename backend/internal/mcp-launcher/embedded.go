@@ -0,0 +1,49 @@
+package mcp_launcher
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Builds an MCP server that runs inside the xtrn process. env is the resolved instance env
+type EmbeddedServerFactory func(env map[string]string) *server.MCPServer
+
+var (
+	embeddedServers   = map[string]EmbeddedServerFactory{}
+	embeddedServersMu sync.RWMutex
+)
+
+func RegisterEmbeddedServer(name string, factory EmbeddedServerFactory) {
+	embeddedServersMu.Lock()
+	defer embeddedServersMu.Unlock()
+
+	embeddedServers[name] = factory
+}
+
+type embeddedTransport struct {
+	*transport.InProcessTransport
+}
+
+func (t *embeddedTransport) Stderr() io.Reader {
+	return nil
+}
+
+func (t *embeddedTransport) OnServerRequest(handler ServerRequestHandler) {}
+
+func newEmbeddedTransport(name string, env map[string]string) (*embeddedTransport, error) {
+	embeddedServersMu.RLock()
+	factory, ok := embeddedServers[name]
+	embeddedServersMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("No embedded MCP server named `%s`", name)
+	}
+
+	return &embeddedTransport{
+		InProcessTransport: transport.NewInProcessTransport(factory(env)),
+	}, nil
+}
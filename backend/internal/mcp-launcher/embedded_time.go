@@ -0,0 +1,42 @@
+package mcp_launcher
+
+import (
+	"context"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const TIME_SERVER_NAME = "time"
+
+func init() {
+	RegisterEmbeddedServer(TIME_SERVER_NAME, newTimeServer)
+}
+
+// Small built-in server so the current time is available without running a container
+func newTimeServer(env map[string]string) *server.MCPServer {
+	s := server.NewMCPServer("xtrn-time", "1.0.0")
+
+	defaultTimezone := env["TIMEZONE"]
+	if defaultTimezone == "" {
+		defaultTimezone = "UTC"
+	}
+
+	s.AddTool(mcp.NewTool("get_current_time",
+		mcp.WithDescription("Gets the current date and time"),
+		mcp.WithString("timezone", mcp.Description("IANA timezone e.g. America/Los_Angeles (default: "+defaultTimezone+")")),
+		mcp.WithReadOnlyHintAnnotation(true),
+	), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		tz := req.GetString("timezone", defaultTimezone)
+
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return mcp.NewToolResultErrorf("Unknown timezone `%s`", tz), nil
+		}
+
+		return mcp.NewToolResultText(time.Now().In(loc).Format(time.RFC1123Z)), nil
+	})
+
+	return s
+}
@@ -0,0 +1,297 @@
+package mcp_launcher
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/db/models"
+	"github.com/mark3labs/mcp-go/client/transport"
+)
+
+// MCP transport for a launched server. Stderr is nil for servers that don't run as a process
+type Transport interface {
+	transport.Interface
+	Stderr() io.Reader
+	// Requests from the server go to handler. No-op unless SupportsServerRequests is true for the launcher
+	OnServerRequest(handler ServerRequestHandler)
+}
+
+type StdioCommand struct {
+	Command string
+	Args    []string
+	Env     []string
+	Dir     string
+	EnvFile string //Holds secrets, removed once the server answers its first request
+}
+
+// Only pass through what package managers like npx / uvx need to run, not the parent's secrets
+var passthroughEnv = []string{"PATH", "HOME", "TMPDIR", "LANG"}
+
+func formatEnv(env map[string]string) []string {
+	fmtEnv := []string{}
+
+	for k, v := range env {
+		fmtEnv = append(fmtEnv, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	return fmtEnv
+}
+
+// docker run flags for a sandbox. No sandbox means no flags (docker defaults)
+func dockerSandboxArgs(s *models.DockerSandbox) []string {
+	if s == nil {
+		return []string{}
+	}
+
+	args := []string{}
+
+	if s.Memory != "" {
+		args = append(args, "--memory", s.Memory, "--memory-swap", s.Memory) //Same as memory so it can't swap past the limit
+	}
+	if s.CPUs > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(s.CPUs, 'f', -1, 64))
+	}
+	if s.PidsLimit > 0 {
+		args = append(args, "--pids-limit", strconv.Itoa(s.PidsLimit))
+	}
+	if s.ReadOnly {
+		args = append(args, "--read-only", "--tmpfs", "/tmp")
+	}
+	for _, c := range s.CapDrop {
+		args = append(args, "--cap-drop", c)
+	}
+
+	switch s.Network.Mode {
+	case models.DockerNetworkModeNone:
+		args = append(args, "--network", "none")
+	case models.DockerNetworkModeEgressAllowlist:
+		//The proxy reads the allowlist from the label, the container can't change it
+		args = append(args, "--network", s.Network.NetworkName())
+		args = append(args, "--label", models.EGRESS_ALLOWED_HOSTS_LABEL+"="+strings.Join(s.Network.AllowedHosts, ","))
+
+		proxy := s.Network.ProxyURL()
+		for _, k := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+			args = append(args, "-e", k+"="+proxy)
+		}
+		args = append(args, "-e", "NO_PROXY=localhost,127.0.0.1", "-e", "no_proxy=localhost,127.0.0.1")
+	}
+
+	return args
+}
+
+/*
+Writes the env to a 0600 file for `docker run --env-file` so values aren't on the docker
+command line. docker reads env files line by line so values can't contain newlines.
+*/
+func writeDockerEnvFile(env map[string]string) (string, error) {
+	dir, err := os.MkdirTemp("", "xtrn-env-")
+	if err != nil {
+		return "", fmt.Errorf("Failed to create env file dir - %w", err)
+	}
+
+	path := filepath.Join(dir, "env")
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("Failed to create env file - %w", err)
+	}
+	defer f.Close()
+
+	for k, v := range env {
+		if strings.ContainsAny(k+v, "\r\n") {
+			os.RemoveAll(dir)
+			return "", fmt.Errorf("Env var %s can't be passed to docker - values can't contain newlines", k)
+		}
+
+		if _, err := fmt.Fprintf(f, "%s=%s\n", k, v); err != nil {
+			os.RemoveAll(dir)
+			return "", fmt.Errorf("Failed to write env file - %w", err)
+		}
+	}
+
+	return path, nil
+}
+
+func removeEnvFile(path string) {
+	if path != "" {
+		os.RemoveAll(filepath.Dir(path))
+	}
+}
+
+/*
+Builds the command used to run the MCP server over stdio. For docker the env is passed to
+the container in an env file, for local commands it is set on the process.
+containerName is optional and only used by docker.
+*/
+func NewStdioCommand(l models.MCPLauncher, containerName string, dockerImage string, env map[string]string) (*StdioCommand, error) {
+	switch l.Type {
+	case models.MCPLauncherTypeDocker:
+		args := []string{"run"}
+
+		if containerName != "" {
+			args = append(args, "--name", containerName)
+		}
+
+		args = append(args, "-i", "--rm")
+		args = append(args, dockerSandboxArgs(l.Sandbox)...)
+
+		envFile := ""
+		if len(env) > 0 {
+			var err error
+			if envFile, err = writeDockerEnvFile(env); err != nil {
+				return nil, err
+			}
+
+			args = append(args, "--env-file", envFile)
+		}
+
+		args = append(args, dockerImage)
+
+		return &StdioCommand{
+			Command: "docker",
+			Args:    args,
+			EnvFile: envFile,
+		}, nil
+
+	case models.MCPLauncherTypeCommand:
+		processEnv := []string{}
+
+		for _, k := range passthroughEnv {
+			if v, ok := os.LookupEnv(k); ok {
+				processEnv = append(processEnv, k+"="+v)
+			}
+		}
+
+		return &StdioCommand{
+			Command: l.Command,
+			Args:    l.Args,
+			Env:     append(processEnv, formatEnv(env)...),
+			Dir:     l.WorkingDir,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s is not a stdio launcher", models.InvalidMCPLauncherTypeError, l.Type)
+}
+
+// transport.NewStdio can't set a working directory so the process is spawned here and wrapped with transport.NewIO
+type stdioTransport struct {
+	*transport.Stdio
+	cmd      *exec.Cmd
+	stderr   io.Reader
+	requests *serverRequestRouter
+
+	envFile       string
+	removeEnvOnce sync.Once
+}
+
+func startStdioTransport(c *StdioCommand) (*stdioTransport, error) {
+	cmd := exec.Command(c.Command, c.Args...)
+	cmd.Dir = c.Dir
+	if c.Env != nil {
+		cmd.Env = c.Env
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create stdin pipe - %w", err)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create stdout pipe - %w", err)
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create stderr pipe - %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		removeEnvFile(c.EnvFile)
+		return nil, fmt.Errorf("Failed to start `%s` - %w", c.Command, err)
+	}
+
+	requests, responses := newServerRequestRouter(stdout, stdin)
+
+	return &stdioTransport{
+		Stdio:    transport.NewIO(bufio.NewReader(responses), stdin, stderr),
+		cmd:      cmd,
+		stderr:   stderr,
+		requests: requests,
+		envFile:  c.EnvFile,
+	}, nil
+}
+
+func (t *stdioTransport) removeEnvFile() {
+	t.removeEnvOnce.Do(func() {
+		removeEnvFile(t.envFile)
+	})
+}
+
+// Once the server has answered docker has read the env file and created the container so it can be removed
+func (t *stdioTransport) SendRequest(ctx context.Context, request transport.JSONRPCRequest) (*transport.JSONRPCResponse, error) {
+	res, err := t.Stdio.SendRequest(ctx, request)
+	if err == nil {
+		t.removeEnvFile()
+	}
+
+	return res, err
+}
+
+func (t *stdioTransport) Stderr() io.Reader {
+	return t.stderr
+}
+
+func (t *stdioTransport) OnServerRequest(handler ServerRequestHandler) {
+	t.requests.setHandler(handler)
+}
+
+func (t *stdioTransport) Close() error {
+	t.removeEnvFile()
+
+	if err := t.Stdio.Close(); err != nil {
+		t.cmd.Process.Kill()
+	}
+
+	return t.cmd.Wait()
+}
+
+/*
+Starts the MCP server process described by the launcher (remote servers are connected to later).
+The transport is not started yet, pass it to client.NewClient and call client.Start so server
+notifications reach the client's notification handlers. bearerToken keeps a remote server's bearer
+token fresh, nil sends the env's value.
+*/
+func NewTransport(l models.MCPLauncher, containerName string, dockerImage string, env map[string]string, bearerToken BearerTokenFunc) (Transport, error) {
+	if l.Type == "" {
+		l = models.DockerLauncher
+	}
+
+	if err := l.Validate(); err != nil {
+		return nil, err
+	}
+
+	if l.Type == models.MCPLauncherTypeEmbedded {
+		return newEmbeddedTransport(l.Server, env)
+	}
+
+	if l.IsRemote() {
+		return newRemoteTransport(l, env, bearerToken)
+	}
+
+	c, err := NewStdioCommand(l, containerName, dockerImage, env)
+	if err != nil {
+		return nil, err
+	}
+
+	return startStdioTransport(c)
+}
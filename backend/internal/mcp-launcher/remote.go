@@ -0,0 +1,89 @@
+package mcp_launcher
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/db/models"
+	"github.com/mark3labs/mcp-go/client/transport"
+)
+
+/*
+Gets the bearer token sent to a remote server, called before every request so short-lived tokens
+are kept fresh. If it fails the env's token is sent, report the error where it happened.
+*/
+type BearerTokenFunc func(ctx context.Context) (string, error)
+
+type remoteTransport struct {
+	transport.Interface
+}
+
+func (t *remoteTransport) Stderr() io.Reader {
+	return nil
+}
+
+func (t *remoteTransport) OnServerRequest(handler ServerRequestHandler) {}
+
+func remoteHeaders(l models.MCPLauncher, env map[string]string) (map[string]string, error) {
+	headers := map[string]string{}
+
+	if l.BearerTokenEnv == "" {
+		return headers, nil
+	}
+
+	token, ok := env[l.BearerTokenEnv]
+	if !ok || token == "" {
+		return nil, fmt.Errorf("Missing bearer token - env key `%s` not set", l.BearerTokenEnv)
+	}
+
+	headers["Authorization"] = "Bearer " + token
+
+	return headers, nil
+}
+
+// Replaces the env's bearer token with bearerToken's
+func remoteHeaderFunc(l models.MCPLauncher, bearerToken BearerTokenFunc) transport.HTTPHeaderFunc {
+	return func(ctx context.Context) map[string]string {
+		if bearerToken == nil || l.BearerTokenEnv == "" {
+			return nil
+		}
+
+		token, err := bearerToken(ctx)
+		if err != nil {
+			return nil
+		}
+
+		return map[string]string{"Authorization": "Bearer " + token}
+	}
+}
+
+// Connects to a hosted MCP server over SSE or Streamable HTTP
+func newRemoteTransport(l models.MCPLauncher, env map[string]string, bearerToken BearerTokenFunc) (*remoteTransport, error) {
+	headers, err := remoteHeaders(l, env)
+	if err != nil {
+		return nil, err
+	}
+
+	headerFunc := remoteHeaderFunc(l, bearerToken)
+
+	switch l.Type {
+	case models.MCPLauncherTypeRemoteSSE:
+		t, err := transport.NewSSE(l.URL, transport.WithHeaders(headers), transport.WithHeaderFunc(headerFunc))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create SSE transport for %s - %w", l.URL, err)
+		}
+
+		return &remoteTransport{Interface: t}, nil
+
+	case models.MCPLauncherTypeRemoteHTTP:
+		t, err := transport.NewStreamableHTTP(l.URL, transport.WithHTTPHeaders(headers), transport.WithHTTPHeaderFunc(headerFunc))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create streamable HTTP transport for %s - %w", l.URL, err)
+		}
+
+		return &remoteTransport{Interface: t}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s is not a remote launcher", models.InvalidMCPLauncherTypeError, l.Type)
+}
@@ -0,0 +1,141 @@
+package mcp_launcher
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/db/models"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+var ErrMethodNotFound = errors.New("Method not found")
+
+/*
+Handles a request the MCP server sends to the client (e.g. sampling/createMessage).
+The result is sent back as the JSON-RPC result, errors are sent as JSON-RPC errors.
+*/
+type ServerRequestHandler func(ctx context.Context, method string, params json.RawMessage) (any, error)
+
+// mcp-go's transports drop server -> client requests so only stdio servers (where we own the pipes) can send them
+func SupportsServerRequests(l models.MCPLauncher) bool {
+	switch l.Type {
+	case "", models.MCPLauncherTypeDocker, models.MCPLauncherTypeCommand:
+		return true
+	}
+	return false
+}
+
+type serverRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *mcp.RequestId  `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+type serverResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type serverResponse struct {
+	JSONRPC string               `json:"jsonrpc"`
+	ID      mcp.RequestId        `json:"id"`
+	Result  any                  `json:"result,omitempty"`
+	Error   *serverResponseError `json:"error,omitempty"`
+}
+
+/*
+Sits between the server's stdout and the mcp-go transport. Requests from the server are
+answered by the handler on stdin, everything else is passed through to the transport.
+*/
+type serverRequestRouter struct {
+	stdin io.Writer
+
+	mu      sync.RWMutex
+	handler ServerRequestHandler
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newServerRequestRouter(stdout io.Reader, stdin io.Writer) (*serverRequestRouter, io.Reader) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r := &serverRequestRouter{
+		stdin:  stdin,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	pr, pw := io.Pipe()
+	go r.route(bufio.NewReader(stdout), pw)
+
+	return r, pr
+}
+
+func (r *serverRequestRouter) setHandler(handler ServerRequestHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.handler = handler
+}
+
+func (r *serverRequestRouter) route(stdout *bufio.Reader, out *io.PipeWriter) {
+	defer r.cancel()
+
+	for {
+		line, err := stdout.ReadBytes('\n')
+		if len(line) > 0 {
+			var req serverRequest
+
+			if json.Unmarshal(line, &req) == nil && req.ID != nil && req.Method != "" {
+				go r.handle(req)
+			} else if _, err := out.Write(line); err != nil {
+				return
+			}
+		}
+
+		if err != nil {
+			out.CloseWithError(err)
+			return
+		}
+	}
+}
+
+func (r *serverRequestRouter) handle(req serverRequest) {
+	r.mu.RLock()
+	handler := r.handler
+	r.mu.RUnlock()
+
+	res := serverResponse{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		ID:      *req.ID,
+	}
+
+	var result any
+	err := ErrMethodNotFound
+
+	if handler != nil {
+		result, err = handler(r.ctx, req.Method, req.Params)
+	}
+
+	if errors.Is(err, ErrMethodNotFound) {
+		res.Error = &serverResponseError{Code: mcp.METHOD_NOT_FOUND, Message: err.Error()}
+	} else if err != nil {
+		res.Error = &serverResponseError{Code: mcp.INTERNAL_ERROR, Message: err.Error()}
+	} else {
+		res.Result = result
+	}
+
+	b, err := json.Marshal(res)
+	if err != nil {
+		return
+	}
+
+	//Lines are written in a single Write so they don't interleave with the transport's requests
+	r.stdin.Write(append(b, '\n'))
+}
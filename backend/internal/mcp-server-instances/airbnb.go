@@ -4,8 +4,8 @@ import "github.com/AbhinavPalacharla/xtrn-personal/internal/types"
 
 const AIRBNB_IMAGE_ID = "airbnb-v1"
 
-func NewAirBNBInstance(userEnv map[string]string) (*types.MCPServerInstance, error) {
-	img, err := types.NewMCPServerInstace(AIRBNB_IMAGE_ID, userEnv)
+func NewAirBNBInstance(userID string, userEnv map[string]string) (*types.MCPServerInstance, error) {
+	img, err := types.NewMCPServerInstace(userID, AIRBNB_IMAGE_ID, userEnv)
 
 	if err != nil {
 		return nil, err
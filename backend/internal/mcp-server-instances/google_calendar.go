@@ -6,8 +6,8 @@ const GOOGLE_CALENDAR_IMAGE_ID = "google-calendar-v1"
 
 // const GOOGLE_CALENDAR_IMAGE_ID = "xmcp-google-calendar"
 
-func NewGoogleCalendarInstance(userEnv map[string]string) (*types.MCPServerInstance, error) {
-	img, err := types.NewMCPServerInstace(GOOGLE_CALENDAR_IMAGE_ID, userEnv)
+func NewGoogleCalendarInstance(userID string, userEnv map[string]string) (*types.MCPServerInstance, error) {
+	img, err := types.NewMCPServerInstace(userID, GOOGLE_CALENDAR_IMAGE_ID, userEnv)
 
 	if err != nil {
 		return nil, err
@@ -1,35 +1,140 @@
 package new_mcp_instance
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net"
 
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/db/models"
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/types"
 	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
 )
 
 type App struct {
-	InstanceID      string            //cmd arg
-	DockerImage     string            //cmd arg
-	InstanceEnv     map[string]string //cmd arg
-	CallbackAddress string            //cmd arg
-	Address         string            //runtime
-	Logger          *log.Logger       //runtime
-	ErrLogger       *log.Logger       //runtime
-	InstanceClient  *client.Client    //runtime
-	Listener        net.Listener      //runtime
+	InstanceID      string                 //cmd arg
+	ImageID         string                 //cmd arg
+	DockerImage     string                 //cmd arg
+	Launcher        models.MCPLauncher     //cmd arg
+	ToolTimeouts    map[string]int         //cmd arg
+	InstanceEnv     map[string]string      //cmd arg
+	CallbackAddress string                 //cmd arg
+	MaxConcurrent   int                    //cmd arg, 0 = no cap on concurrent tool calls
+	Address         string                 //runtime
+	Logger          *log.Logger            //runtime
+	ErrLogger       *log.Logger            //runtime
+	InstanceClient  *client.Client         //runtime
+	Transport       transport.Interface    //runtime
+	Notifications   *NotificationRouter    //runtime
+	Listener        net.Listener           //runtime
+	Control         *types.InstanceControl //runtime
+	callSlots       chan struct{}          //runtime, nil = no cap
 }
 
 func NewApp() (*App, error) {
 	app := App{}
 	app.init()
 
+	//Without a callback address (running by hand) there is no one to report to
+	if app.CallbackAddress != "" {
+		control, err := types.DialInstanceControl(app.CallbackAddress)
+		if err != nil {
+			return nil, err
+		}
+
+		app.Control = control
+		go app.heartbeat()
+	}
+
+	if err := app.start(); err != nil {
+		app.reportInitFailed(err)
+		return nil, err
+	}
+
+	return &app, nil
+}
+
+// What an instance runs with when it isn't started from the command line
+type InstanceConfig struct {
+	InstanceID   string
+	ImageID      string
+	DockerImage  string
+	Launcher     models.MCPLauncher
+	ToolTimeouts map[string]int
+	Env          map[string]string
+	// Cap on tool calls running at once, 0 = no cap
+	MaxConcurrentCalls int
+}
+
+// Runs an instance inside the calling process instead of a start-mcp-instance process
+func NewInProcessApp(cfg InstanceConfig) (*App, error) {
+	instanceLoggers := shared.NewMCPInstanceLogger(cfg.InstanceID)
+
+	app := App{
+		InstanceID:    cfg.InstanceID,
+		ImageID:       cfg.ImageID,
+		DockerImage:   cfg.DockerImage,
+		Launcher:      cfg.Launcher,
+		ToolTimeouts:  cfg.ToolTimeouts,
+		InstanceEnv:   cfg.Env,
+		MaxConcurrent: cfg.MaxConcurrentCalls,
+		Logger:        instanceLoggers.Logger,
+		ErrLogger:     instanceLoggers.ErrLogger,
+	}
+
+	if err := app.start(); err != nil {
+		return nil, err
+	}
+
+	return &app, nil
+}
+
+func (app *App) start() error {
+	app.Notifications = NewNotificationRouter()
+
+	if app.MaxConcurrent > 0 {
+		app.callSlots = make(chan struct{}, app.MaxConcurrent)
+	}
+
 	client, err := app.createClient()
 	if err != nil {
-		return nil, fmt.Errorf("Failed to start MCP Client - %w", err)
+		return fmt.Errorf("Failed to start MCP Client - %w", err)
 	}
 
 	app.InstanceClient = client
 
-	return &app, nil
+	//Image tools were discovered with placeholder env, the real env may expose different ones
+	if err := app.refreshTools(); err != nil {
+		app.ErrLogger.Printf("Failed to discover instance tools, using image tools - %v\n", err)
+	}
+
+	return nil
+}
+
+func (app *App) heartbeat() {
+	if err := app.Control.Heartbeat(); err != nil {
+		app.ErrLogger.Printf("Stopped sending heartbeats - %v\n", err)
+	}
+}
+
+func (app *App) reportInitFailed(err error) {
+	if err := app.Control.InitFailed(err); err != nil {
+		app.ErrLogger.Print(err)
+	}
+}
+
+// Waits for a free tool call slot, the returned func frees it. Shared instances cap their concurrent calls
+func (app *App) acquireCallSlot(ctx context.Context) (func(), error) {
+	if app.callSlots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case app.callSlots <- struct{}{}:
+		return func() { <-app.callSlots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 }
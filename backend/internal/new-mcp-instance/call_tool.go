@@ -0,0 +1,88 @@
+package new_mcp_instance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Default time a tool call may take when the image doesn't set a timeout for the tool
+const MAX_TOOL_USE_TIME = time.Second * 20
+
+// Time to wait for the server to accept a notifications/cancelled message
+const CANCEL_NOTIFICATION_TIMEOUT = time.Second * 5
+
+func (app *App) toolTimeout(name string) time.Duration {
+	if s, ok := app.ToolTimeouts[name]; ok && s > 0 {
+		return time.Duration(s) * time.Second
+	}
+
+	return MAX_TOOL_USE_TIME
+}
+
+/*
+Calls a tool on the MCP server. The request is sent on the transport instead of with
+client.CallTool so its JSON-RPC ID is known, when ctx ends (timeout or the /callTool
+request was aborted) the server is sent notifications/cancelled for that ID.
+*/
+func (app *App) callTool(ctx context.Context, toolUseID string, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	//String IDs so they can't collide with the client's numeric IDs
+	requestID := mcp.NewRequestId("xtrn-call-" + toolUseID)
+
+	res, err := app.Transport.SendRequest(ctx, transport.JSONRPCRequest{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		ID:      requestID,
+		Method:  string(mcp.MethodToolsCall),
+		Params:  req.Params,
+	})
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		reason := "Tool call request aborted"
+		if errors.Is(ctxErr, context.DeadlineExceeded) {
+			reason = "Tool call request timeout"
+		}
+
+		app.cancelRequest(requestID, reason)
+
+		return nil, ctxErr
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("transport error: %w", err)
+	}
+
+	if res.Error != nil {
+		return nil, errors.New(res.Error.Message)
+	}
+
+	return mcp.ParseCallToolResult(&res.Result)
+}
+
+func (app *App) cancelRequest(requestID mcp.RequestId, reason string) {
+	notification := mcp.JSONRPCNotification{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		Notification: mcp.Notification{
+			Method: "notifications/cancelled",
+			Params: mcp.NotificationParams{
+				AdditionalFields: map[string]any{
+					"requestId": requestID,
+					"reason":    reason,
+				},
+			},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), CANCEL_NOTIFICATION_TIMEOUT)
+	defer cancel()
+
+	if err := app.Transport.SendNotification(ctx, notification); err != nil {
+		app.ErrLogger.Printf("Failed to send cancel notification for %v - %v\n", requestID.Value(), err)
+		return
+	}
+
+	app.Logger.Printf("CANCELLED TOOL CALL %v: %s\n", requestID.Value(), reason)
+}
@@ -6,90 +6,55 @@ import (
 	"fmt"
 	"time"
 
+	mcp_launcher "github.com/AbhinavPalacharla/xtrn-personal/internal/mcp-launcher"
 	"github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/types"
 	"github.com/mark3labs/mcp-go/client"
-	"github.com/mark3labs/mcp-go/client/transport"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
-func formatEnv(env map[string]string) []string {
-	// fmtEnv := ""
-
-	// for k, v := range env {
-	// 	if fmtEnv != "" {
-	// 		fmtEnv += " "
-	// 	}
-
-	// 	fmtEnv += fmt.Sprintf("-e %s=%s", k, v)
-	// }
-
-	// return fmtEnv
-
-	fmtEnv := []string{}
-
-	for k, v := range env {
-		// fmtEnv = append(fmtEnv, fmt.Sprintf("-e \"%s=%s\"", k, v))
-		fmtEnv = append(fmtEnv, "-e", fmt.Sprintf("%s=%s", k, v))
-	}
-
-	return fmtEnv
-}
-
-func (app *App) formatMCPServerStartCommand() []string {
-	// command := ""
-
-	// if len(app.InstanceEnv) > 0 {
-	// 	command = fmt.Sprintf("docker run --name %s -i --rm %s %s", app.InstanceID, formatEnv(app.InstanceEnv), app.DockerImage)
-	// } else {
-	// 	command = fmt.Sprintf("docker run --name %s -i --rm %s", app.InstanceID, app.DockerImage)
-	// }
-
-	// commandArgs := strings.Split(command, " ")
+func (app *App) createClient() (*client.Client, error) {
+	app.Logger.Printf("\n\nStarting MCP server with launcher: %#v\n", app.Launcher)
 
-	command := []string{
-		"docker",
-		"run",
-		"--name", app.InstanceID,
-		"-i", "--rm",
+	//Docker containers are named after the instance so they can be found with `docker ps`
+	bearerToken, err := types.MCPServerInstanceBearerToken(app.ImageID, app.InstanceID, app.Launcher)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(app.InstanceEnv) > 0 {
-		command = append(command, formatEnv(app.InstanceEnv)...)
+	transport, err := mcp_launcher.NewTransport(app.Launcher, app.InstanceID, app.DockerImage, app.InstanceEnv, bearerToken)
+	if err != nil {
+		return nil, err
 	}
 
-	command = append(command, app.DockerImage)
-
-	return command
-}
-
-func (app *App) createClient() (*client.Client, error) {
-	mcpServerStartCommand := app.formatMCPServerStartCommand()
-
-	fmt.Printf("\nRunning container with command: %v\n", mcpServerStartCommand)
-	fmt.Printf("DOCKER CONTAINER: %s\n", app.DockerImage)
-	app.Logger.Printf("\n\nRunning container with command: %v\n", mcpServerStartCommand)
-
-	transport := transport.NewStdio(mcpServerStartCommand[0], nil, mcpServerStartCommand[1:]...)
-	transport.Start(context.Background())
+	transport.OnServerRequest(app.handleServerRequest)
 
 	instanceLoggers := shared.NewMCPInstanceLogger(app.InstanceID)
 	errorLogger := instanceLoggers.ErrLogger
 
-	//Async stderr logging
-	go func() {
-		stderr := transport.Stderr()
-		scanner := bufio.NewScanner(stderr)
+	//Async stderr logging (embedded servers have no stderr)
+	if stderr := transport.Stderr(); stderr != nil {
+		go func() {
+			scanner := bufio.NewScanner(stderr)
 
-		for scanner.Scan() {
-			errorLogger.Print(scanner.Text())
-		}
+			for scanner.Scan() {
+				errorLogger.Print(scanner.Text())
+			}
 
-		if err := scanner.Err(); err != nil {
-			errorLogger.Printf("Could not read stderr: %v\n", err)
-		}
-	}()
+			if err := scanner.Err(); err != nil {
+				errorLogger.Printf("Could not read stderr: %v\n", err)
+			}
+		}()
+	}
 
 	client := client.NewClient(transport)
+	client.OnNotification(app.handleNotification)
+	app.Transport = transport //Tool calls are sent on the transport so they can be cancelled
+
+	//Remote SSE streams live as long as the start context so don't use the timeout one
+	if err := client.Start(context.Background()); err != nil {
+		return nil, fmt.Errorf("Failed to start MCP transport - %w", err)
+	}
 
 	//Give container 30 sconds to start up
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -102,13 +67,27 @@ func (app *App) createClient() (*client.Client, error) {
 		Name:    fmt.Sprint(app.InstanceID),
 		Version: "0",
 	}
+	if mcp_launcher.SupportsServerRequests(app.Launcher) {
+		initRequest.Params.Capabilities.Sampling = &struct{}{}
+	}
 	initResult, err := client.Initialize(ctx, initRequest)
 	if err != nil {
+		client.Close() //Stops the server and removes its env file
 		return nil, err
 	}
 
 	//Client initialized with server
 	app.Logger.Printf("CLIENT INITIALIZED\n↳ Server Info: %s %s\n", initResult.ServerInfo.Name, initResult.ServerInfo.Version)
 
+	//Ask for log messages so they end up in the instance log
+	if initResult.Capabilities.Logging != nil {
+		setLevelRequest := mcp.SetLevelRequest{}
+		setLevelRequest.Params.Level = mcp.LoggingLevelInfo
+
+		if err := client.SetLevel(ctx, setLevelRequest); err != nil {
+			app.ErrLogger.Printf("Failed to set server log level - %v\n", err)
+		}
+	}
+
 	return client, nil
 }
@@ -6,15 +6,14 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"time"
 
 	"github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
 	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/types"
 	"github.com/mark3labs/mcp-go/mcp"
+	gonanoid "github.com/matoous/go-nanoid/v2"
 )
 
-const MAX_TOOL_USE_TIME = time.Second * 20
-
 func (s *HTTPServer) handleListTools(w http.ResponseWriter, r *http.Request) {
 	tools, err := s.app.InstanceClient.ListTools(context.Background(), mcp.ListToolsRequest{})
 
@@ -74,116 +73,285 @@ type XtrnHeader struct {
 	Message         *string         `json:"message,omitempty"`
 }
 
-func (s *HTTPServer) handleCallTool(w http.ResponseWriter, r *http.Request) {
-	req, err := DecodeJSONBody[ToolCallReq](r, w)
-	if err != nil {
-		return
-	}
-
-	ViewObjectAsJSON("RAW REQUEST", req, s.app.Logger.Printf)
+// Servers built for xtrn send an XtrnHeader as the first content item
+func parseXtrnHeader(res *mcp.CallToolResult) (XtrnHeader, bool) {
+	var xtrnHeader XtrnHeader
 
-	toolCallRequest := mcp.CallToolRequest{}
-	toolCallRequest.Params.Name = req.Name
-	toolCallRequest.Params.Arguments = req.Arguments
+	if len(res.Content) == 0 {
+		return xtrnHeader, false
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), MAX_TOOL_USE_TIME)
-	defer cancel()
+	text, ok := res.Content[0].(mcp.TextContent)
+	if !ok {
+		return xtrnHeader, false
+	}
 
-	res, err := s.app.InstanceClient.CallTool(ctx, toolCallRequest)
+	if err := json.Unmarshal([]byte(text.Text), &xtrnHeader); err != nil {
+		return xtrnHeader, false
+	}
 
-	if err != nil {
-		s.app.ErrLogger.Printf("TOOL CALL ERROR: %s\n", err.Error())
+	switch xtrnHeader.XtrnMessageType {
+	case XtrnMessageTypeResponse, XtrnMessageTypeError, XtrnMessageTypeLLMError:
+		return xtrnHeader, true
 	}
 
-	ViewObjectAsJSON("RAW TOOL RESPONSE", res, s.app.Logger.Printf)
+	return xtrnHeader, false
+}
 
+func errorBody(err string) map[string]string {
+	return map[string]string{"error": err}
+}
+
+// Status code and body /callTool responds with for a finished tool call
+func (s *HTTPServer) toolCallResult(req *ToolCallReq, res *mcp.CallToolResult, err error) (int, any) {
 	if errors.Is(err, context.DeadlineExceeded) {
+		//Tool call took too long, the server has been told to cancel it
+		s.app.ErrLogger.Printf("Tool call request timeout - %v\n", err)
 
-		//Tool call took too long
-		HTTPReturnError(w, ErrorOptions{
-			Err:  "Tool call request timeout",
-			Code: http.StatusRequestTimeout,
-		})
+		return http.StatusRequestTimeout, errorBody(fmt.Sprintf("Tool call timed out after %s and was cancelled", s.app.toolTimeout(req.Name)))
 
-		s.app.ErrLogger.Printf("Tool call request timeout - %v\n", err)
+	} else if errors.Is(err, context.Canceled) {
+		//Caller went away, nobody will read the response
+		s.app.ErrLogger.Printf("Tool call request aborted - %v\n", err)
 
-	} else if err != nil {
+		return http.StatusServiceUnavailable, errorBody("Tool call request aborted")
 
+	} else if err != nil {
 		// Tool call failed for unknown reason
-		HTTPReturnError(w, ErrorOptions{
-			Err:  err.Error(),
-			Code: http.StatusInternalServerError,
-		})
 		s.app.ErrLogger.Printf("Tool call failed for unknown reason - %v", err)
 
-	} else {
+		return http.StatusInternalServerError, errorBody(err.Error())
+	}
 
-		// Check xtrn header for type of request either REQUEST or ERROR
-		xtrnHeaderRaw := res.Content[0].(mcp.TextContent).Text
+	// Check xtrn header for type of request either REQUEST or ERROR
+	xtrnHeader, ok := parseXtrnHeader(res)
 
-		var xtrnHeader XtrnHeader
+	if !ok {
+		// Third party server (npx / uvx etc.) without an xtrn header so return the result as is
 
-		if err := json.Unmarshal([]byte(xtrnHeaderRaw), &xtrnHeader); err != nil {
-			s.app.ErrLogger.Printf("Failed to unmarshall header - %v\n", err)
+		toolCallRes := ToolCallRes{
+			ToolUseID: req.ToolUseID,
+			Content:   res.Content,
+			IsError:   res.IsError,
 		}
 
-		ViewObjectAsJSON("XTRN HEADER", xtrnHeader, s.app.Logger.Printf)
+		ViewObjectAsJSON("TOOL CALL RES (NO XTRN HEADER)", toolCallRes, s.app.Logger.Printf)
 
-		if xtrnHeader.XtrnMessageType == XtrnMessageTypeResponse {
-			// REGULAR RESPONSE
+		return http.StatusOK, toolCallRes
+	}
 
-			toolCallRes := ToolCallRes{
-				ToolUseID: req.ToolUseID,
-				Content:   res.Content[1:],
-				IsError:   false,
-			}
+	ViewObjectAsJSON("XTRN HEADER", xtrnHeader, s.app.Logger.Printf)
+
+	switch xtrnHeader.XtrnMessageType {
+	case XtrnMessageTypeResponse:
+		// REGULAR RESPONSE
+
+		toolCallRes := ToolCallRes{
+			ToolUseID: req.ToolUseID,
+			Content:   res.Content[1:],
+			IsError:   false,
+		}
+
+		ViewObjectAsJSON("TOOL CALL RES", toolCallRes, s.app.ErrLogger.Printf)
+
+		return http.StatusOK, toolCallRes
 
-			ViewObjectAsJSON("TOOL CALL RES", toolCallRes, s.app.ErrLogger.Printf)
+	case XtrnMessageTypeLLMError:
+		// LLM ERROR
+
+		toolCallRes := ToolCallRes{
+			ToolUseID: req.ToolUseID,
+			Content:   res.Content[1:],
+			IsError:   true,
+		}
+
+		ViewObjectAsJSON("TOOL CALL RES (LLM ERR)", toolCallRes, s.app.ErrLogger.Printf)
+
+		return http.StatusBadRequest, toolCallRes
+	}
+
+	// XTRN ERROR
+	errorType, message := "", ""
+	if xtrnHeader.ErrorType != nil {
+		errorType = *xtrnHeader.ErrorType
+	}
+	if xtrnHeader.Message != nil {
+		message = *xtrnHeader.Message
+	}
+
+	s.app.ErrLogger.Printf("XTRN ERROR - %v", xtrnHeader)
+
+	if errorType == "AUTH_INVALID_GRANT" {
+		return http.StatusUnauthorized, errorBody(fmt.Sprintf("ERROR: %s REASON: %s", errorType, message))
+	}
+
+	return http.StatusInternalServerError, errorBody(fmt.Sprintf("ERROR: %s REASON: %s", errorType, message))
+}
+
+/*
+Calls a tool on the MCP server and returns the status code and body /callTool responds with.
+Progress and log notifications for the call go to onEvent, which can be nil.
+*/
+func (s *HTTPServer) CallTool(ctx context.Context, req *ToolCallReq, onEvent func(types.ToolCallEvent)) (int, any) {
+	if req.ToolUseID == "" {
+		req.ToolUseID, _ = gonanoid.New()
+	}
+
+	toolCallRequest := mcp.CallToolRequest{}
+	toolCallRequest.Params.Name = req.Name
+	toolCallRequest.Params.Arguments = req.Arguments
+	toolCallRequest.Params.Meta = &mcp.Meta{
+		ProgressToken: req.ToolUseID,
+	}
 
-			if err := HTTPSendJSON(w, toolCallRes, nil); err != nil {
-				s.app.ErrLogger.Printf("Failed to send JSON - %v\n", err)
+	//Ending ctx (e.g. aborting the /callTool request) cancels the tool call
+	ctx, cancel := context.WithTimeout(ctx, s.app.toolTimeout(req.Name))
+	defer cancel()
+
+	//Time spent waiting for a slot counts towards the tool's timeout
+	release, err := s.app.acquireCallSlot(ctx)
+	if err != nil {
+		return s.toolCallResult(req, nil, err)
+	}
+	defer release()
+
+	events := s.app.Notifications.Subscribe(req.ToolUseID)
+	defer s.app.Notifications.Unsubscribe(req.ToolUseID)
+
+	type callResult struct {
+		res *mcp.CallToolResult
+		err error
+	}
+
+	done := make(chan callResult, 1)
+
+	go func() {
+		res, err := s.app.callTool(ctx, req.ToolUseID, toolCallRequest)
+		done <- callResult{res, err}
+	}()
+
+	sendEvent := func(e types.ToolCallEvent) {
+		if onEvent != nil {
+			onEvent(e)
+		}
+	}
+
+	for {
+		select {
+		case e := <-events:
+			sendEvent(e)
+
+		case result := <-done:
+			if result.err != nil {
+				s.app.ErrLogger.Printf("TOOL CALL ERROR: %s\n", result.err.Error())
 			}
 
-		} else if xtrnHeader.XtrnMessageType == XtrnMessageTypeLLMError {
-			// LLM ERROR
+			ViewObjectAsJSON("RAW TOOL RESPONSE", result.res, s.app.Logger.Printf)
 
-			toolCallRes := ToolCallRes{
-				ToolUseID: req.ToolUseID,
-				Content:   res.Content[1:],
-				IsError:   true,
+			//Notifications sent before the result may still be queued
+			for pending := true; pending; {
+				select {
+				case e := <-events:
+					sendEvent(e)
+				default:
+					pending = false
+				}
 			}
 
-			ViewObjectAsJSON("TOOL CALL RES (LLM ERR)", toolCallRes, s.app.ErrLogger.Printf)
+			return s.toolCallResult(req, result.res, result.err)
+		}
+	}
+}
 
-			HTTPSendJSON(w, toolCallRes, &JSONResponseOptions{
-				StatusCode: http.StatusBadRequest,
-			})
-		} else if xtrnHeader.XtrnMessageType == XtrnMessageTypeError {
-			// XTRN ERROR
+/*
+Calls a tool on the MCP server. If the request's Accept header is types.TOOL_CALL_STREAM_CONTENT_TYPE
+progress and log notifications are streamed as JSON lines followed by a RESULT event, otherwise the
+result is returned as JSON.
+*/
+func (s *HTTPServer) handleCallTool(w http.ResponseWriter, r *http.Request) {
+	req, err := DecodeJSONBody[ToolCallReq](r, w)
+	if err != nil {
+		return
+	}
 
-			s.app.ErrLogger.Printf("AUTH INVALID - %v", xtrnHeader)
+	ViewObjectAsJSON("RAW REQUEST", req, s.app.Logger.Printf)
 
-			if *xtrnHeader.ErrorType == "AUTH_INVALID_GRANT" {
-				HTTPReturnError(w, ErrorOptions{
-					Err:  fmt.Sprintf("ERROR: %s REASON: %s", *xtrnHeader.ErrorType, *xtrnHeader.Message),
-					Code: http.StatusUnauthorized,
-				})
-			}
-		} else {
-			// Unsupported type
+	if r.Header.Get("Accept") != types.TOOL_CALL_STREAM_CONTENT_TYPE {
+		status, body := s.CallTool(r.Context(), req, nil)
+
+		if err := HTTPSendJSON(w, body, &JSONResponseOptions{StatusCode: status}); err != nil {
+			s.app.ErrLogger.Printf("Failed to send JSON - %v\n", err)
 		}
 
+		return
 	}
-}
 
-func (s *HTTPServer) handleKill(w http.ResponseWriter, r *http.Request) {
-	s.app.Logger.Printf("KILL REQUEST RECIEVED\n")
+	w.Header().Set("Content-Type", types.TOOL_CALL_STREAM_CONTENT_TYPE)
+	w.WriteHeader(http.StatusOK)
 
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+
+	writeEvent := func(e types.ToolCallEvent) {
+		if err := encoder.Encode(e); err != nil {
+			s.app.ErrLogger.Printf("Failed to write tool call event - %v\n", err)
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	status, body := s.CallTool(r.Context(), req, writeEvent)
+	bodyJSON, _ := json.Marshal(body)
+
+	writeEvent(types.ToolCallEvent{
+		Type:      types.ToolCallEventTypeResult,
+		ToolUseID: req.ToolUseID,
+		Status:    status,
+		Body:      bodyJSON,
+	})
+}
+
+// Stops the MCP server. The instance is kept so it can be started again
+func (s *HTTPServer) Stop(reason string) {
 	s.app.InstanceClient.Close()
 
+	if err := s.app.Control.Shutdown(reason); err != nil {
+		s.app.ErrLogger.Print(err)
+	}
+}
+
+// Stops the MCP server and removes the instance
+func (s *HTTPServer) Kill() {
 	Q.DeleteMCPServerInstance(context.Background(), s.app.InstanceID)
+	Q.DeleteMCPServerInstanceTools(context.Background(), s.app.InstanceID)
+
+	s.Stop("Killed")
+}
 
+// Responds then stops serving once the MCP server is stopped
+func (s *HTTPServer) exit(w http.ResponseWriter) {
 	HTTPSendJSON[any](w, nil, &JSONResponseOptions{})
 
-	// s.app.Listener.Close()
+	if s.OnStop != nil {
+		go s.OnStop()
+	} else {
+		go s.shutdown()
+	}
+}
+
+func (s *HTTPServer) handleKill(w http.ResponseWriter, r *http.Request) {
+	s.app.Logger.Printf("KILL REQUEST RECIEVED\n")
+
+	s.Kill()
+	s.exit(w)
+}
+
+// Scales the instance to zero, it is started again when it is next used
+func (s *HTTPServer) handleStop(w http.ResponseWriter, r *http.Request) {
+	s.app.Logger.Printf("STOP REQUEST RECIEVED\n")
+
+	s.Stop("Stopped")
+	s.exit(w)
 }
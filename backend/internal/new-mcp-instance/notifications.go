@@ -0,0 +1,123 @@
+package new_mcp_instance
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/types"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Events waiting to be read by a streaming /callTool. Slow readers drop events instead of blocking the client
+const MAX_PENDING_TOOL_CALL_EVENTS = 64
+
+// Routes server notifications to the /callTool requests that are streaming them, keyed by progress token
+type NotificationRouter struct {
+	mu    sync.Mutex
+	calls map[string]chan types.ToolCallEvent
+}
+
+func NewNotificationRouter() *NotificationRouter {
+	return &NotificationRouter{
+		calls: map[string]chan types.ToolCallEvent{},
+	}
+}
+
+func (n *NotificationRouter) Subscribe(progressToken string) <-chan types.ToolCallEvent {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	events := make(chan types.ToolCallEvent, MAX_PENDING_TOOL_CALL_EVENTS)
+	n.calls[progressToken] = events
+
+	return events
+}
+
+func (n *NotificationRouter) Unsubscribe(progressToken string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	delete(n.calls, progressToken)
+}
+
+func (n *NotificationRouter) send(progressToken string, e types.ToolCallEvent) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if events, ok := n.calls[progressToken]; ok {
+		select {
+		case events <- e:
+		default:
+		}
+	}
+}
+
+// Log messages aren't tied to a request so every streaming call gets them
+func (n *NotificationRouter) broadcast(e types.ToolCallEvent) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for token, events := range n.calls {
+		e.ToolUseID = token
+
+		select {
+		case events <- e:
+		default:
+		}
+	}
+}
+
+func toFloat(v any) float64 {
+	f, _ := v.(float64)
+	return f
+}
+
+func toString(v any) string {
+	s, _ := v.(string)
+	return s
+}
+
+func (app *App) handleNotification(notification mcp.JSONRPCNotification) {
+	fields := notification.Params.AdditionalFields
+
+	switch notification.Method {
+	case "notifications/progress":
+		token := fmt.Sprint(fields["progressToken"])
+
+		e := types.ToolCallEvent{
+			Type:      types.ToolCallEventTypeProgress,
+			ToolUseID: token,
+			Progress:  toFloat(fields["progress"]),
+			Total:     toFloat(fields["total"]),
+			Message:   toString(fields["message"]),
+		}
+
+		app.Logger.Printf("PROGRESS [%s] %v/%v %s\n", token, e.Progress, e.Total, e.Message)
+		app.Notifications.send(token, e)
+
+	case "notifications/message":
+		e := types.ToolCallEvent{
+			Type:   types.ToolCallEventTypeLog,
+			Level:  toString(fields["level"]),
+			Logger: toString(fields["logger"]),
+			Data:   fields["data"],
+		}
+
+		switch mcp.LoggingLevel(e.Level) {
+		case mcp.LoggingLevelError, mcp.LoggingLevelCritical, mcp.LoggingLevelAlert, mcp.LoggingLevelEmergency:
+			app.ErrLogger.Printf("SERVER LOG [%s] %s: %v\n", e.Level, e.Logger, e.Data)
+		default:
+			app.Logger.Printf("SERVER LOG [%s] %s: %v\n", e.Level, e.Logger, e.Data)
+		}
+
+		app.Notifications.broadcast(e)
+
+	case mcp.MethodNotificationToolsListChanged:
+		//Listing tools waits on a response from the transport that is delivering this notification
+		go func() {
+			if err := app.refreshTools(); err != nil {
+				app.ErrLogger.Printf("Failed to refresh tools - %v\n", err)
+			}
+		}()
+	}
+}
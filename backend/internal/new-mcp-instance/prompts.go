@@ -0,0 +1,55 @@
+package new_mcp_instance
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+type GetPromptReq struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments"`
+}
+
+func (s *HTTPServer) handleListPrompts(w http.ResponseWriter, r *http.Request) {
+	prompts, err := s.app.InstanceClient.ListPrompts(context.Background(), mcp.ListPromptsRequest{})
+	if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: fmt.Sprintf("Failed to list prompts - %v", err),
+		})
+		s.app.ErrLogger.Printf("Failed to list prompts - %v\n", err)
+		return
+	}
+
+	HTTPSendJSON(w, prompts, nil)
+}
+
+func (s *HTTPServer) handleGetPrompt(w http.ResponseWriter, r *http.Request) {
+	req, err := DecodeJSONBody[GetPromptReq](r, w)
+	if err != nil {
+		return
+	}
+
+	getPromptRequest := mcp.GetPromptRequest{}
+	getPromptRequest.Params.Name = req.Name
+	getPromptRequest.Params.Arguments = req.Arguments
+
+	ctx, cancel := context.WithTimeout(context.Background(), MAX_TOOL_USE_TIME)
+	defer cancel()
+
+	res, err := s.app.InstanceClient.GetPrompt(ctx, getPromptRequest)
+	if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: fmt.Sprintf("Failed to get prompt %s - %v", req.Name, err),
+		})
+		s.app.ErrLogger.Printf("Failed to get prompt %s - %v\n", req.Name, err)
+		return
+	}
+
+	ViewObjectAsJSON("PROMPT", res, s.app.Logger.Printf)
+
+	HTTPSendJSON(w, res, nil)
+}
@@ -0,0 +1,74 @@
+package new_mcp_instance
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+type ReadResourceReq struct {
+	URI string `json:"uri"`
+}
+
+func (s *HTTPServer) handleListResources(w http.ResponseWriter, r *http.Request) {
+	resources, err := s.app.InstanceClient.ListResources(context.Background(), mcp.ListResourcesRequest{})
+	if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: fmt.Sprintf("Failed to list resources - %v", err),
+		})
+		s.app.ErrLogger.Printf("Failed to list resources - %v\n", err)
+		return
+	}
+
+	HTTPSendJSON(w, resources, nil)
+}
+
+func (s *HTTPServer) handleListResourceTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := s.app.InstanceClient.ListResourceTemplates(context.Background(), mcp.ListResourceTemplatesRequest{})
+	if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: fmt.Sprintf("Failed to list resource templates - %v", err),
+		})
+		s.app.ErrLogger.Printf("Failed to list resource templates - %v\n", err)
+		return
+	}
+
+	HTTPSendJSON(w, templates, nil)
+}
+
+func (s *HTTPServer) handleReadResource(w http.ResponseWriter, r *http.Request) {
+	req, err := DecodeJSONBody[ReadResourceReq](r, w)
+	if err != nil {
+		return
+	}
+
+	if req.URI == "" {
+		HTTPReturnError(w, ErrorOptions{
+			Err:  "`uri` is required",
+			Code: http.StatusBadRequest,
+		})
+		return
+	}
+
+	readRequest := mcp.ReadResourceRequest{}
+	readRequest.Params.URI = req.URI
+
+	ctx, cancel := context.WithTimeout(context.Background(), MAX_TOOL_USE_TIME)
+	defer cancel()
+
+	res, err := s.app.InstanceClient.ReadResource(ctx, readRequest)
+	if err != nil {
+		HTTPReturnError(w, ErrorOptions{
+			Err: fmt.Sprintf("Failed to read resource %s - %v", req.URI, err),
+		})
+		s.app.ErrLogger.Printf("Failed to read resource %s - %v\n", req.URI, err)
+		return
+	}
+
+	ViewObjectAsJSON("RESOURCE", res, s.app.Logger.Printf)
+
+	HTTPSendJSON(w, res, nil)
+}
@@ -0,0 +1,34 @@
+package new_mcp_instance
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	mcp_launcher "github.com/AbhinavPalacharla/xtrn-personal/internal/mcp-launcher"
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/types"
+)
+
+// Max time the API gets to run a sampling request through the LLM
+const MAX_SAMPLING_TIME = time.Second * 120
+
+// Answers requests the MCP server sends to the client. Sampling requests are forwarded to the API
+func (app *App) handleServerRequest(ctx context.Context, method string, params json.RawMessage) (any, error) {
+	if method != "sampling/createMessage" {
+		app.ErrLogger.Printf("Unsupported server request `%s`\n", method)
+		return nil, mcp_launcher.ErrMethodNotFound
+	}
+
+	app.Logger.Printf("SAMPLING REQUEST RECIEVED\n")
+
+	ctx, cancel := context.WithTimeout(ctx, MAX_SAMPLING_TIME)
+	defer cancel()
+
+	result, err := types.CreateMCPSamplingMessage(ctx, app.InstanceID, params)
+	if err != nil {
+		app.ErrLogger.Printf("Sampling request failed - %v\n", err)
+		return nil, err
+	}
+
+	return result, nil
+}
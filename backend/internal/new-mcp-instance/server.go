@@ -1,12 +1,18 @@
 package new_mcp_instance
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net"
 	"net/http"
 )
 
 type HTTPServer struct {
 	app *App
+	srv *http.Server
+
+	OnStop func() //Called after /kill or /stop stops the MCP server. Shuts down the HTTP server when nil
 }
 
 func NewHTTPServer(app *App) *HTTPServer {
@@ -17,14 +23,25 @@ func NewHTTPServer(app *App) *HTTPServer {
 	return &s
 }
 
-func (s *HTTPServer) StartServer() error {
+func (s *HTTPServer) Handler() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/listTools", s.handleListTools)
 	mux.HandleFunc("/callTool", s.handleCallTool)
+	mux.HandleFunc("/listResources", s.handleListResources)
+	mux.HandleFunc("/listResourceTemplates", s.handleListResourceTemplates)
+	mux.HandleFunc("/readResource", s.handleReadResource)
+	mux.HandleFunc("/listPrompts", s.handleListPrompts)
+	mux.HandleFunc("/getPrompt", s.handleGetPrompt)
 	mux.HandleFunc("/kill", s.handleKill)
+	mux.HandleFunc("/stop", s.handleStop)
+
+	return mux
+}
 
+func (s *HTTPServer) StartServer() error {
 	listener, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
+		s.app.reportInitFailed(fmt.Errorf("Failed to listen - %w", err))
 		return err
 	}
 
@@ -33,16 +50,29 @@ func (s *HTTPServer) StartServer() error {
 
 	//Write listen address back to creator
 	s.app.Logger.Print("TRANSMITTING SERVER ADDRESS\n")
-	conn, err := net.Dial("unix", s.app.CallbackAddress)
-	if err != nil {
+	if err := s.app.Control.Ready(s.app.Address); err != nil {
 		return err
 	}
-	defer conn.Close()
-
-	conn.Write([]byte(s.app.Address))
 
 	//Run Server
 	s.app.Logger.Printf("🚀 SERVER RUNNING ON %s\n", s.app.Address)
 
-	return http.Serve(listener, mux)
+	s.srv = &http.Server{Handler: s.Handler()}
+
+	//Shutdown after /kill or /stop is a clean exit
+	if err := s.srv.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// Stops accepting requests and returns from StartServer once in flight requests (the /kill or /stop) are done
+func (s *HTTPServer) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), MAX_TOOL_USE_TIME)
+	defer cancel()
+
+	if err := s.srv.Shutdown(ctx); err != nil {
+		s.app.ErrLogger.Printf("Failed to shut down HTTP server - %v\n", err)
+	}
 }
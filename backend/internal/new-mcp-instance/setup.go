@@ -5,8 +5,10 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/db/models"
 	"github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
 )
 
@@ -17,17 +19,25 @@ func (app *App) parseFlags() {
 
 Options:
   --instance-id     	string		MCP instance ID (Required)
+  --image-id        	string		ID of the instance's image
   --docker-image    	string		Docker image name (Required)
-  --instance-env    	string		JSON-encoded ENV object (default: "{}") (Required)
+  --instance-env-fd 	int   		fd to read the JSON-encoded ENV object from (default: no env)
   --callback-address	string		Address to write listener address to
+  --launcher        	string		JSON-encoded launcher (default: docker)
+  --tool-timeouts   	string		JSON-encoded tool name -> timeout seconds (default: "{}")
+  --max-concurrent-calls	int		Max tool calls running at once (default: 0 = no cap)
   --help, -h                 Show this help message`)
 	}
 
 	// Flags
 	instanceID := flag.String("instance-id", "", "MCP instance ID")
+	imageID := flag.String("image-id", "", "ID of the instance's image")
 	dockerImage := flag.String("docker-image", "", "Docker image name")
-	instanceEnvRaw := flag.String("instance-env", "{}", `JSON-encoded ENV object (default: "{}")`)
+	instanceEnvFD := flag.Int("instance-env-fd", -1, "fd to read the JSON-encoded ENV object from")
 	callbackAddress := flag.String("callback-address", "", "Address for instance to run on")
+	launcherRaw := flag.String("launcher", `{"type":"DOCKER"}`, "JSON-encoded launcher")
+	toolTimeoutsRaw := flag.String("tool-timeouts", "{}", "JSON-encoded tool name -> timeout seconds")
+	maxConcurrentCalls := flag.Int("max-concurrent-calls", 0, "Max tool calls running at once, 0 = no cap")
 
 	// Handle help
 	for _, arg := range os.Args[1:] {
@@ -39,20 +49,64 @@ Options:
 
 	flag.Parse()
 
-	// Convert instance-env to map[string]string
-	envMap := make(map[string]string)
+	envMap, err := readInstanceEnv(*instanceEnvFD)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid --instance-env-fd value: %v\n", err)
+		os.Exit(1)
+	}
 
-	fmt.Printf("\n\nRAW INSTANCE ENV: %s\n", *instanceEnvRaw)
+	for _, v := range envMap {
+		shared.RegisterSecrets(v)
+	}
+
+	launcher := models.MCPLauncher{}
 
-	if err := json.Unmarshal([]byte(*instanceEnvRaw), &envMap); err != nil {
-		fmt.Fprintf(os.Stderr, "Invalid --instance-env value: %v\n", err)
+	if err := json.Unmarshal([]byte(*launcherRaw), &launcher); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid --launcher value: %v\n", err)
+		os.Exit(1)
+	}
+
+	toolTimeouts := map[string]int{}
+
+	if err := json.Unmarshal([]byte(*toolTimeoutsRaw), &toolTimeouts); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid --tool-timeouts value: %v\n", err)
 		os.Exit(1)
 	}
 
 	app.InstanceID = *instanceID
+	app.ImageID = *imageID
 	app.DockerImage = *dockerImage
 	app.InstanceEnv = envMap
 	app.CallbackAddress = *callbackAddress
+	app.Launcher = launcher
+	app.ToolTimeouts = toolTimeouts
+	app.MaxConcurrent = *maxConcurrentCalls
+}
+
+// Env holds secrets so it comes over an inherited fd instead of argv. No fd means no env
+func readInstanceEnv(fd int) (map[string]string, error) {
+	envMap := make(map[string]string)
+
+	if fd < 0 {
+		return envMap, nil
+	}
+
+	f := os.NewFile(uintptr(fd), "instance-env")
+	if f == nil {
+		return nil, fmt.Errorf("fd %d is not open", fd)
+	}
+	defer f.Close()
+
+	raw, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(raw, &envMap); err != nil {
+		return nil, err
+	}
+
+	return envMap, nil
 }
 
 func (app *App) PANIC(reason string) {
@@ -0,0 +1,48 @@
+package new_mcp_instance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/types"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Max time to list the server's tools
+const MAX_TOOL_DISCOVERY_TIME = time.Second * 30
+
+/*
+Lists the server's tools with the instance's real env and saves them as the instance's tools.
+Called on start and when the server sends notifications/tools/list_changed
+*/
+func (app *App) refreshTools() error {
+	ctx, cancel := context.WithTimeout(context.Background(), MAX_TOOL_DISCOVERY_TIME)
+	defer cancel()
+
+	tools := []mcp.Tool{}
+	req := mcp.ListToolsRequest{}
+
+	for {
+		res, err := app.InstanceClient.ListTools(ctx, req)
+		if err != nil {
+			return fmt.Errorf("Failed to list tools - %w", err)
+		}
+
+		tools = append(tools, res.Tools...)
+
+		if res.NextCursor == "" {
+			break
+		}
+
+		req.Params.Cursor = res.NextCursor
+	}
+
+	if err := types.SaveMCPInstanceTools(app.InstanceID, tools); err != nil {
+		return err
+	}
+
+	app.Logger.Printf("TOOLS REFRESHED (%d tools)\n", len(tools))
+
+	return nil
+}
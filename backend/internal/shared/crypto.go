@@ -0,0 +1,115 @@
+package shared
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var ErrInvalidSecretsKey = errors.New("`SECRETS_KEY` must be 32 bytes encoded as base64 (e.g. openssl rand -base64 32)")
+
+func secretsKey() ([]byte, error) {
+	raw, err := GetEnv("SECRETS_KEY")
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil || len(key) != 32 {
+		return nil, ErrInvalidSecretsKey
+	}
+
+	return key, nil
+}
+
+func secretsCipher() (cipher.AEAD, error) {
+	key, err := secretsKey()
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create cipher - %w", err)
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// Encrypts with SECRETS_KEY (AES-256-GCM). The nonce is the first bytes of the result
+func EncryptSecret(plaintext string) ([]byte, error) {
+	aead, err := secretsCipher()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("Failed to create nonce - %w", err)
+	}
+
+	return aead.Seal(nonce, nonce, []byte(plaintext), nil), nil
+}
+
+func DecryptSecret(ciphertext []byte) (string, error) {
+	aead, err := secretsCipher()
+	if err != nil {
+		return "", err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return "", errors.New("Encrypted secret is too short")
+	}
+
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+
+	plaintext, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", fmt.Errorf("Failed to decrypt secret (was SECRETS_KEY changed?) - %w", err)
+	}
+
+	return string(plaintext), nil
+}
+
+/*
+Key an instance authenticates to the API with (e.g. to fetch oauth access tokens), an HMAC of its
+ID with SECRETS_KEY so nothing has to be stored. Changing SECRETS_KEY invalidates every key.
+*/
+func InstanceKey(instanceID string) (string, error) {
+	key, err := secretsKey()
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("instance-key:" + instanceID))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+func VerifyInstanceKey(instanceID string, instanceKey string) bool {
+	expected, err := InstanceKey(instanceID)
+	if err != nil {
+		return false
+	}
+
+	return hmac.Equal([]byte(expected), []byte(instanceKey))
+}
+
+// Authenticates a request to the instance's endpoints, which the API serves for IN_PROCESS instances
+func SetInstanceKeyHeader(req *http.Request, instanceID string) error {
+	instanceKey, err := InstanceKey(instanceID)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+instanceKey)
+
+	return nil
+}
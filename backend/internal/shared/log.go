@@ -7,7 +7,7 @@ import (
 	"path"
 )
 
-var StdErrLogger = log.New(os.Stderr, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
+var StdErrLogger = log.New(NewRedactingWriter(os.Stderr), "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
 
 type MCPInstanceLoggers struct {
 	Logger    *log.Logger
@@ -27,8 +27,11 @@ func NewMCPInstanceLogger(instanceID string) MCPInstanceLoggers {
 		panic(err)
 	}
 
-	l := log.New(logFile, fmt.Sprintf("%s: ", instanceID), log.Llongfile)
-	el := log.New(logFile, fmt.Sprintf("ERROR: %s: ", instanceID), log.Llongfile)
+	//Server stderr is logged here too so scrub anything it prints from the env
+	out := NewRedactingWriter(logFile)
+
+	l := log.New(out, fmt.Sprintf("%s: ", instanceID), log.Llongfile)
+	el := log.New(out, fmt.Sprintf("ERROR: %s: ", instanceID), log.Llongfile)
 
 	return MCPInstanceLoggers{
 		Logger:    l,
@@ -42,8 +45,8 @@ type APILoggers struct {
 }
 
 func NewAPILoggers() APILoggers {
-	l := log.New(os.Stdout, fmt.Sprintf("API"), log.Llongfile)
-	el := log.New(os.Stderr, fmt.Sprintf("ERROR: API: "), log.Llongfile)
+	l := log.New(NewRedactingWriter(os.Stdout), fmt.Sprintf("API"), log.Llongfile)
+	el := log.New(NewRedactingWriter(os.Stderr), fmt.Sprintf("ERROR: API: "), log.Llongfile)
 
 	return APILoggers{
 		Logger:    l,
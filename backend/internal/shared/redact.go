@@ -0,0 +1,100 @@
+package shared
+
+import (
+	"io"
+	"strings"
+	"sync"
+)
+
+const REDACTED = "[REDACTED]"
+
+// Values shorter than this are too likely to show up in normal log lines to be worth redacting
+const MIN_SECRET_LENGTH = 4
+
+/*
+Most secrets kept for redaction. Tokens are rotated and instances come and go in the API, so once
+there are more the ones registered longest ago are dropped. Values are registered again whenever
+they're used so the ones still in use stay.
+*/
+const MAX_SECRETS = 1024
+
+var secrets = struct {
+	mu       sync.RWMutex
+	replacer *strings.Replacer
+	values   map[string]uint64 // Value -> when it was last registered (seq)
+	seq      uint64
+}{
+	values: map[string]uint64{},
+}
+
+// Registers values that are replaced with [REDACTED] by every logger from this package
+func RegisterSecrets(values ...string) {
+	secrets.mu.Lock()
+	defer secrets.mu.Unlock()
+
+	added := false
+	for _, v := range values {
+		if len(v) < MIN_SECRET_LENGTH {
+			continue
+		}
+
+		if _, ok := secrets.values[v]; !ok {
+			added = true
+		}
+
+		secrets.seq++
+		secrets.values[v] = secrets.seq
+	}
+
+	//Known values only move up the eviction order, the replacer doesn't change
+	if !added {
+		return
+	}
+
+	for len(secrets.values) > MAX_SECRETS {
+		oldest, oldestSeq := "", secrets.seq+1
+		for v, seq := range secrets.values {
+			if seq < oldestSeq {
+				oldest, oldestSeq = v, seq
+			}
+		}
+
+		delete(secrets.values, oldest)
+	}
+
+	oldnew := []string{}
+	for v := range secrets.values {
+		oldnew = append(oldnew, v, REDACTED)
+	}
+
+	secrets.replacer = strings.NewReplacer(oldnew...)
+}
+
+// Replaces registered secrets in s
+func Redact(s string) string {
+	secrets.mu.RLock()
+	defer secrets.mu.RUnlock()
+
+	if secrets.replacer == nil {
+		return s
+	}
+
+	return secrets.replacer.Replace(s)
+}
+
+// Writer that redacts registered secrets. log.Logger writes a whole line per Write so secrets aren't split
+type redactingWriter struct {
+	w io.Writer
+}
+
+func NewRedactingWriter(w io.Writer) io.Writer {
+	return &redactingWriter{w: w}
+}
+
+func (r *redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, Redact(string(p))); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
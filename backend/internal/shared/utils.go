@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
 )
 
 type ErrorOptions struct {
@@ -81,6 +82,6 @@ func ViewObjectAsJSON(prefix string, obj any, printFn func(fmtString string, a .
 	if printFn != nil {
 		printFn(fmtStr)
 	} else {
-		fmt.Print(fmtStr)
+		fmt.Fprint(NewRedactingWriter(os.Stdout), fmtStr)
 	}
 }
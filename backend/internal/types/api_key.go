@@ -0,0 +1,200 @@
+package types
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/db/models"
+	db "github.com/AbhinavPalacharla/xtrn-personal/internal/db/sqlc"
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+/*
+Personal API keys let scripts and CI act as a user without a browser session. Keys are
+API_KEY_PREFIX followed by random bytes and are only shown when created, the database keeps their
+sha256. The prefix keeps them apart from other bearer tokens (e.g. instance keys).
+*/
+const (
+	API_KEY_PREFIX         = "xtrn_"
+	API_KEY_BYTES          = 32
+	API_KEY_DISPLAY_LENGTH = len(API_KEY_PREFIX) + 8 // Start of the key shown to tell keys apart
+)
+
+var ErrAPIKeyNotFound = errors.New("API key not found")
+var ErrInvalidAPIKey = errors.New("Invalid API key")
+var ErrAPIKeyExpired = errors.New("API key has expired")
+
+type APIKey struct {
+	ID         string              `json:"id"`
+	Name       string              `json:"name"`
+	Prefix     string              `json:"prefix"`
+	Scopes     models.APIKeyScopes `json:"scopes"`
+	ExpiresAt  *time.Time          `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time          `json:"last_used_at,omitempty"`
+	CreatedAt  *time.Time          `json:"created_at,omitempty"`
+}
+
+// A key that was just created. Key is never returned again
+type NewAPIKey struct {
+	*APIKey
+	Key string `json:"key"`
+}
+
+func apiKeyFromRow(row db.ApiKey) *APIKey {
+	k := APIKey{
+		ID:     row.ID,
+		Name:   row.Name,
+		Prefix: row.Prefix,
+		Scopes: row.Scopes,
+	}
+
+	if k.Scopes == nil {
+		k.Scopes = models.APIKeyScopes{}
+	}
+
+	if row.ExpiresAt.Valid {
+		k.ExpiresAt = &row.ExpiresAt.Time
+	}
+	if row.LastUsedAt.Valid {
+		k.LastUsedAt = &row.LastUsedAt.Time
+	}
+	if row.CreatedAt.Valid {
+		k.CreatedAt = &row.CreatedAt.Time
+	}
+
+	return &k
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Whether a bearer token is meant to be an API key
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, API_KEY_PREFIX)
+}
+
+// Creates a key for the user. expiresAt nil never expires
+func CreateAPIKey(ctx context.Context, userID string, name string, scopes models.APIKeyScopes, expiresAt *time.Time) (*NewAPIKey, error) {
+	if name == "" {
+		return nil, fmt.Errorf("API key needs a name")
+	}
+
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("API key needs at least one scope")
+	}
+	for _, s := range scopes {
+		if !s.IsValid() {
+			return nil, fmt.Errorf("%w: %s", models.InvalidAPIKeyScopeError, s)
+		}
+	}
+
+	expires := sql.NullTime{}
+	if expiresAt != nil {
+		if !expiresAt.After(time.Now()) {
+			return nil, fmt.Errorf("API key expiry must be in the future")
+		}
+		expires = sql.NullTime{Time: expiresAt.UTC(), Valid: true}
+	}
+
+	raw := make([]byte, API_KEY_BYTES)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("Failed to create API key - %w", err)
+	}
+	key := API_KEY_PREFIX + base64.RawURLEncoding.EncodeToString(raw)
+
+	id, _ := gonanoid.New()
+
+	if err := Q.InsertAPIKey(ctx, db.InsertAPIKeyParams{
+		ID:        id,
+		UserID:    userID,
+		Name:      name,
+		Prefix:    key[:API_KEY_DISPLAY_LENGTH],
+		KeyHash:   hashAPIKey(key),
+		Scopes:    scopes,
+		ExpiresAt: expires,
+	}); err != nil {
+		return nil, fmt.Errorf("Failed to save API key - %w", err)
+	}
+
+	row, err := Q.GetAPIKey(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get API key %s - %w", id, err)
+	}
+
+	return &NewAPIKey{
+		APIKey: apiKeyFromRow(row),
+		Key:    key,
+	}, nil
+}
+
+func GetAPIKeys(ctx context.Context, userID string) ([]*APIKey, error) {
+	rows, err := Q.GetAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get API keys - %w", err)
+	}
+
+	keys := make([]*APIKey, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, apiKeyFromRow(row))
+	}
+
+	return keys, nil
+}
+
+// ErrAPIKeyNotFound if the key doesn't exist, ErrForbidden if it isn't the user's
+func RevokeAPIKey(ctx context.Context, userID string, id string) error {
+	row, err := Q.GetAPIKey(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w - %s", ErrAPIKeyNotFound, id)
+	} else if err != nil {
+		return fmt.Errorf("Failed to get API key %s - %w", id, err)
+	}
+
+	if row.UserID != userID {
+		return fmt.Errorf("%w - API key %s", ErrForbidden, id)
+	}
+
+	if _, err := Q.DeleteAPIKey(ctx, id); err != nil {
+		return fmt.Errorf("Failed to revoke API key %s - %w", id, err)
+	}
+
+	return nil
+}
+
+// The user a key belongs to and the key, which is marked used. ErrInvalidAPIKey if it doesn't exist
+func AuthenticateAPIKey(ctx context.Context, key string) (*User, *APIKey, error) {
+	row, err := Q.GetAPIKeyByHash(ctx, hashAPIKey(key))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, ErrInvalidAPIKey
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("Failed to get API key - %w", err)
+	}
+
+	if row.ExpiresAt.Valid && !row.ExpiresAt.Time.After(time.Now()) {
+		return nil, nil, fmt.Errorf("%w - %s", ErrAPIKeyExpired, row.Prefix)
+	}
+
+	user, err := GetUser(ctx, row.UserID)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, nil, ErrInvalidAPIKey
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	if err := Q.TouchAPIKey(ctx, row.ID); err != nil {
+		StdErrLogger.Printf("Failed to mark API key %s used - %v\n", row.ID, err)
+	}
+
+	return user, apiKeyFromRow(row), nil
+}
@@ -0,0 +1,174 @@
+package types
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/db/models"
+	db "github.com/AbhinavPalacharla/xtrn-personal/internal/db/sqlc"
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+var ErrSamplingDenied = errors.New("Sampling is not allowed for this MCP server")
+
+const DEFAULT_API_ADDRESS = "http://localhost:8080"
+
+// Address instances use to reach the API. Set with API_ADDRESS
+func APIAddress() string {
+	if addr := os.Getenv("API_ADDRESS"); addr != "" {
+		return addr
+	}
+
+	return DEFAULT_API_ADDRESS
+}
+
+// Body of POST /sampling. Params is the sampling/createMessage params the MCP server sent
+type MCPSamplingRequest struct {
+	InstanceID string          `json:"instance_id"`
+	Params     json.RawMessage `json:"params"`
+}
+
+type MCPSamplingRecord struct {
+	ID         string                       `json:"id"`
+	InstanceID string                       `json:"instance_id"`
+	ImageID    string                       `json:"image_id"`
+	Status     models.SamplingRequestStatus `json:"status"`
+	Model      string                       `json:"model,omitempty"`
+	MaxTokens  int                          `json:"max_tokens"`
+	Request    json.RawMessage              `json:"request"`
+	Response   json.RawMessage              `json:"response,omitempty"`
+	Error      string                       `json:"error,omitempty"`
+	CreatedAt  *time.Time                   `json:"created_at,omitempty"`
+}
+
+// Image ID and sampling policy of the image an instance runs
+func GetMCPInstanceSamplingPolicy(instanceID string) (string, models.SamplingPolicy, error) {
+	inst, err := Q.GetMCPServerInstance(context.Background(), instanceID)
+	if err != nil {
+		return "", models.SamplingPolicy{}, fmt.Errorf("Failed to get instance %s - %w", instanceID, err)
+	}
+
+	img, err := Q.GetMCPServerImageBySlugVersion(context.Background(), db.GetMCPServerImageBySlugVersionParams{
+		Slug:    inst.Slug,
+		Version: inst.Version,
+	})
+	if err != nil {
+		return "", models.SamplingPolicy{}, fmt.Errorf("Failed to get image for instance %s - %w", instanceID, err)
+	}
+
+	return img.ID, img.SamplingPolicy, nil
+}
+
+func RecordMCPSamplingRequest(rec *MCPSamplingRecord) error {
+	if rec.ID == "" {
+		rec.ID, _ = gonanoid.New()
+	}
+
+	if err := Q.InsertMCPSamplingRequest(context.Background(), db.InsertMCPSamplingRequestParams{
+		ID:         rec.ID,
+		InstanceID: rec.InstanceID,
+		ImageID:    rec.ImageID,
+		Status:     string(rec.Status),
+		Model:      nullString(rec.Model),
+		MaxTokens:  int64(rec.MaxTokens),
+		Request:    string(rec.Request),
+		Response:   nullString(string(rec.Response)),
+		Error:      nullString(rec.Error),
+	}); err != nil {
+		return fmt.Errorf("Failed to record sampling request - %w", err)
+	}
+
+	return nil
+}
+
+func GetMCPSamplingRequests(instanceID string) ([]MCPSamplingRecord, error) {
+	rows, err := Q.GetMCPSamplingRequestsByInstance(context.Background(), instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get sampling requests for instance %s - %w", instanceID, err)
+	}
+
+	recs := []MCPSamplingRecord{}
+
+	for _, r := range rows {
+		rec := MCPSamplingRecord{
+			ID:         r.ID,
+			InstanceID: r.InstanceID,
+			ImageID:    r.ImageID,
+			Status:     models.SamplingRequestStatus(r.Status),
+			Model:      r.Model.String,
+			MaxTokens:  int(r.MaxTokens),
+			Request:    json.RawMessage(r.Request),
+			Error:      r.Error.String,
+		}
+
+		if r.Response.Valid {
+			rec.Response = json.RawMessage(r.Response.String)
+		}
+
+		if r.CreatedAt.Valid {
+			rec.CreatedAt = &r.CreatedAt.Time
+		}
+
+		recs = append(recs, rec)
+	}
+
+	return recs, nil
+}
+
+/*
+Sends a sampling/createMessage request from an instance's MCP server to the API and returns
+the raw CreateMessageResult. The request is authenticated with the instance's key. Denied
+requests return ErrSamplingDenied.
+*/
+func CreateMCPSamplingMessage(ctx context.Context, instanceID string, params json.RawMessage) (json.RawMessage, error) {
+	instanceKey, err := InstanceKey(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, _ := json.Marshal(MCPSamplingRequest{
+		InstanceID: instanceID,
+		Params:     params,
+	})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, APIAddress()+"/sampling", bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create /sampling request - %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+instanceKey)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to make request to /sampling - %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read /sampling response - %w", err)
+	}
+
+	if res.StatusCode == http.StatusForbidden {
+		return nil, ErrSamplingDenied
+	}
+
+	if res.StatusCode != http.StatusOK {
+		var errRes struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(body, &errRes)
+
+		return nil, fmt.Errorf("Sampling failed - status %d: %s", res.StatusCode, errRes.Error)
+	}
+
+	return json.RawMessage(body), nil
+}
@@ -0,0 +1,256 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/db/models"
+	db "github.com/AbhinavPalacharla/xtrn-personal/internal/db/sqlc"
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+)
+
+var ErrEnvResolve = errors.New("Failed to resolve env")
+var ErrUnknownEnvNamespace = errors.New("Unknown env source namespace")
+
+// What a source is resolved for
+type EnvResolveRequest struct {
+	Image      db.GetMCPServerImageRow
+	InstanceID string
+	UserID     string // The instance's user, "" for warm pool instances
+}
+
+/*
+Resolves the sources of one namespace, e.g. `secret` for $secret.<name>. Names limits the
+names the namespace has (checked when an image is created), nil allows any name.
+*/
+type EnvResolver struct {
+	Names   []string
+	Secret  bool // Resolved values are credentials, they're kept out of logs
+	Resolve func(req EnvResolveRequest, name string) (string, error)
+}
+
+var (
+	envResolvers   = map[string]EnvResolver{}
+	envResolversMu sync.RWMutex
+)
+
+func RegisterEnvResolver(namespace string, resolver EnvResolver) {
+	envResolversMu.Lock()
+	defer envResolversMu.Unlock()
+
+	envResolvers[namespace] = resolver
+}
+
+func getEnvResolver(namespace string) (EnvResolver, bool) {
+	envResolversMu.RLock()
+	defer envResolversMu.RUnlock()
+
+	r, ok := envResolvers[namespace]
+	return r, ok
+}
+
+func init() {
+	RegisterEnvResolver("provider", EnvResolver{
+		Names:  []string{"oauth_client_id", "oauth_client_secret"},
+		Secret: true,
+		Resolve: func(req EnvResolveRequest, name string) (string, error) {
+			if !req.Image.ProviderName.Valid {
+				return "", fmt.Errorf("image %s has no oauth provider", req.Image.ID)
+			}
+
+			if name == "oauth_client_id" {
+				return req.Image.ClientID.String, nil
+			}
+
+			return req.Image.ClientSecret.String, nil
+		},
+	})
+
+	RegisterEnvResolver("user", EnvResolver{
+		Names:  []string{"oauth_refresh_token", "oauth_access_token"},
+		Secret: true,
+		Resolve: func(req EnvResolveRequest, name string) (string, error) {
+			if name == "oauth_access_token" {
+				token, err := GetOauthAccessToken(context.Background(), req.UserID, req.Image.OauthProvider.String)
+				if err != nil {
+					return "", err
+				}
+
+				return token.AccessToken, nil
+			}
+
+			token, err := Q.GetOauthTokenByProvider(context.Background(), db.GetOauthTokenByProviderParams{
+				UserID:        nullString(req.UserID),
+				OauthProvider: req.Image.OauthProvider.String,
+			})
+			if err != nil {
+				return "", fmt.Errorf("No oauth token for %s - %w", req.Image.OauthProvider.String, err)
+			}
+
+			return token.RefreshToken, nil
+		},
+	})
+
+	//Where the instance fetches access tokens for its image's oauth provider instead of holding a refresh token
+	RegisterEnvResolver("broker", EnvResolver{
+		Names:  []string{"token_url", "token_key"},
+		Secret: true,
+		Resolve: func(req EnvResolveRequest, name string) (string, error) {
+			if !req.Image.OauthProvider.Valid {
+				return "", fmt.Errorf("image %s has no oauth provider", req.Image.ID)
+			}
+
+			if name == "token_url" {
+				return instanceTokenURL(req.InstanceID), nil
+			}
+
+			return InstanceKey(req.InstanceID)
+		},
+	})
+
+	//Encrypted values from the secrets table
+	RegisterEnvResolver("secret", EnvResolver{
+		Secret: true,
+		Resolve: func(req EnvResolveRequest, name string) (string, error) {
+			return getSecretValue(name)
+		},
+	})
+
+	//The API process's environment
+	RegisterEnvResolver("env", EnvResolver{
+		Secret: true,
+		Resolve: func(req EnvResolveRequest, name string) (string, error) {
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				return "", fmt.Errorf("`%s` is not set", name)
+			}
+
+			return value, nil
+		},
+	})
+
+	//ID of the instance being started
+	RegisterEnvResolver("instance", EnvResolver{
+		Names: []string{"id"},
+		Resolve: func(req EnvResolveRequest, name string) (string, error) {
+			return req.InstanceID, nil
+		},
+	})
+}
+
+func sortedEnvKeys(schema models.EnvSchema) []string {
+	keys := []string{}
+	for k := range schema {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Checks every source in the schema has a namespace and name that can be resolved
+func validateEnvSources(schema models.EnvSchema) error {
+	errs := []error{}
+
+	for _, k := range sortedEnvKeys(schema) {
+		source := schema[k].Source
+		if source == "" {
+			continue
+		}
+
+		namespace, name, err := models.ParseEnvSource(source)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("`%s`: %w", k, err))
+			continue
+		}
+
+		resolver, ok := getEnvResolver(namespace)
+		if !ok {
+			errs = append(errs, fmt.Errorf("`%s`: %w `%s`", k, ErrUnknownEnvNamespace, namespace))
+			continue
+		}
+
+		if resolver.Names != nil && !slices.Contains(resolver.Names, name) {
+			errs = append(errs, fmt.Errorf("`%s`: $%s has no `%s`, expected one of %s", k, namespace, name, strings.Join(resolver.Names, ", ")))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w - %w", models.InvalidEnvSchemaError, errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// Resolves every var with a source, errors say which key and namespace failed
+func resolveEnvSources(req EnvResolveRequest, env models.InstanceEnv) error {
+	errs := []error{}
+	secrets := []string{}
+
+	for _, k := range sortedEnvKeys(req.Image.EnvSchema) {
+		v := req.Image.EnvSchema[k]
+		if v.Source == "" {
+			continue
+		}
+
+		namespace, name, err := models.ParseEnvSource(v.Source)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("`%s`: %w", k, err))
+			continue
+		}
+
+		resolver, ok := getEnvResolver(namespace)
+		if !ok {
+			errs = append(errs, fmt.Errorf("`%s`: %w `%s`", k, ErrUnknownEnvNamespace, namespace))
+			continue
+		}
+
+		value, err := resolver.Resolve(req, name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("`%s` from %s (namespace `%s`): %w", k, v.Source, namespace, err))
+			continue
+		}
+
+		env[k] = value
+
+		if resolver.Secret {
+			secrets = append(secrets, value)
+		}
+	}
+
+	RegisterSecrets(secrets...)
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w - %w", ErrEnvResolve, errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// Values of env that shouldn't show up in logs, vars marked secret and credentials from a source
+func instanceEnvSecrets(schema models.EnvSchema, env models.InstanceEnv) []string {
+	secrets := []string{}
+
+	for k, v := range schema {
+		value, ok := env[k]
+		if !ok {
+			continue
+		}
+
+		if v.Secret {
+			secrets = append(secrets, value)
+		} else if namespace, _, err := models.ParseEnvSource(v.Source); err == nil {
+			if r, ok := getEnvResolver(namespace); ok && r.Secret {
+				secrets = append(secrets, value)
+			}
+		}
+	}
+
+	return secrets
+}
@@ -8,52 +8,50 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"os/exec"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/AbhinavPalacharla/xtrn-personal/internal/db/models"
 	db "github.com/AbhinavPalacharla/xtrn-personal/internal/db/sqlc"
+	mcp_launcher "github.com/AbhinavPalacharla/xtrn-personal/internal/mcp-launcher"
 	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
 	"github.com/mark3labs/mcp-go/client"
-	"github.com/mark3labs/mcp-go/client/transport"
 	"github.com/mark3labs/mcp-go/mcp"
 	gonanoid "github.com/matoous/go-nanoid/v2"
 )
 
-var ErrInvalidClientID = errors.New("Invalid CLIENT_ID value must be $provider.oauth_client_id")
-var ErrInvalidClientSecret = errors.New("Invalid CLIENT_SECRET value must be $provider.oauth_client_id")
-var ErrInvalidRefreshToken = errors.New("Invalid REFRESH_TOKEN value must be $user.oauth_refresh_token")
-
-func validateEnvSchema(schema map[string]string) (bool, error) {
-	for k, v := range schema {
-		if k == "CLIENT_ID" && v != "$provider.oauth_client_id" {
-			return false, ErrInvalidClientID
-		} else if k == "CLIENT_SECRET" && v != "$provider.oauth_client_secret" {
-			return false, ErrInvalidClientSecret
-		} else if k == "REFRESH_TOKEN" && v != "$user.oauth_refresh_token" {
-			return false, ErrInvalidRefreshToken
-		}
-	}
-
-	return true, nil
-}
+var ErrWarmPoolNotPublic = errors.New("Warm pools are only supported for PUBLIC images without env")
+var ErrOauthScopesWithoutProvider = errors.New("Oauth scopes can only be set for images with an oauth provider")
+var ErrEgressNetworkNotInternal = errors.New("Egress network must be an internal docker network")
 
 type MCPTool struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	InputSchema string `json:"input_schema"`
+	Name           string `json:"name"`
+	Description    string `json:"description"`
+	InputSchema    string `json:"input_schema"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"` // 0 = instance default
 }
 
 type MCPServerImage struct {
-	ImageID     string               `json:"id"`
-	Slug        string               `json:"slug"`
-	Version     int                  `json:"version"`
-	Name        string               `json:"name"`
-	DockerImage string               `json:"docker_image"`
-	ServerType  models.MCPServerType `json:"type"`
-	Provider    string               `json:"provider"`
-	EnvSchema   models.EnvSchema     `json:"env_schema"`
-	Tools       []MCPTool            `json:"tools"`
+	ImageID      string                `json:"id"`
+	Slug         string                `json:"slug"`
+	Version      int                   `json:"version"`
+	Name         string                `json:"name"`
+	DockerImage  string                `json:"docker_image"`
+	ServerType   models.MCPServerType  `json:"type"`
+	Provider     string                `json:"provider"`
+	EnvSchema    models.EnvSchema      `json:"env_schema"`
+	Launcher     models.MCPLauncher    `json:"launcher"`
+	ToolTimeouts models.ToolTimeouts   `json:"tool_timeouts"`
+	Sampling     models.SamplingPolicy `json:"sampling"`
+	WarmPool     models.WarmPool       `json:"warm_pool"`
+	OauthScopes  models.OauthScopes    `json:"oauth_scopes"` // Scopes needed from Provider, consented to when an instance is created
+	Tools        []MCPTool             `json:"tools"`
+
+	Resources         []MCPResource         `json:"resources"`
+	ResourceTemplates []MCPResourceTemplate `json:"resource_templates"`
+	Prompts           []MCPPrompt           `json:"prompts"`
 }
 
 func saveMCPServerImageToDB(img *MCPServerImage) error {
@@ -89,7 +87,12 @@ func saveMCPServerImageToDB(img *MCPServerImage) error {
 			String: img.Provider,
 			Valid:  img.Provider != "",
 		},
-		EnvSchema: img.EnvSchema,
+		EnvSchema:      img.EnvSchema,
+		Launcher:       img.Launcher,
+		ToolTimeouts:   img.ToolTimeouts,
+		SamplingPolicy: img.Sampling,
+		WarmPool:       img.WarmPool,
+		OauthScopes:    img.OauthScopes,
 	}); err != nil {
 		// return err
 		return fmt.Errorf("%w", err)
@@ -106,14 +109,23 @@ func saveMCPServerImageToDB(img *MCPServerImage) error {
 				String: tool.Description,
 				Valid:  tool.Description != "",
 			},
-			Schema:  tool.InputSchema,
-			ImageID: img.ImageID,
+			Schema:         tool.InputSchema,
+			ImageID:        img.ImageID,
+			TimeoutSeconds: int64(tool.TimeoutSeconds),
 		}); err != nil {
 			return fmt.Errorf("Tools: %w", err)
 
 		}
 	}
 
+	if err = saveMCPServerImageResources(qtx, img); err != nil {
+		return err
+	}
+
+	if err = saveMCPServerImagePrompts(qtx, img); err != nil {
+		return err
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		return fmt.Errorf("Failed to insert MCP server instance by tx - %w", err)
@@ -122,39 +134,44 @@ func saveMCPServerImageToDB(img *MCPServerImage) error {
 	return nil
 }
 
-func (img *MCPServerImage) getTools() ([]MCPTool, error) {
+// Starts a temporary server to discover the image's tools, resources, resource templates and prompts
+func (img *MCPServerImage) discover() error {
 	tools := []MCPTool{}
 
-	cmd := []string{"docker", "run", "-i", "--rm"}
-
+	env := map[string]string{}
 	for k, v := range img.EnvSchema {
-		_ = v
-		cmd = append(cmd, "-e", fmt.Sprintf("%s=%s", k, "abc")) // Just need placeholder values to get tools
+		env[k] = v.Placeholder() // Just need placeholder values to get tools
 	}
 
-	cmd = append(cmd, img.DockerImage)
-
-	fmt.Printf("STARTING SERVER WITH CMD: %v\n", cmd)
+	fmt.Printf("STARTING SERVER WITH LAUNCHER: %#v\n", img.Launcher)
 
-	transport := transport.NewStdio(cmd[0], nil, cmd[1:]...)
-
-	transport.Start(context.Background())
+	transport, err := mcp_launcher.NewTransport(img.Launcher, "", img.DockerImage, env, nil)
+	if err != nil {
+		return fmt.Errorf("Failed to launch MCP server - %w", err)
+	}
 
 	//Async stderr logging
-	go func() {
-		stderr := transport.Stderr()
-		scanner := bufio.NewScanner(stderr)
-
-		for scanner.Scan() {
-			fmt.Print(scanner.Text())
-		}
-
-		if err := scanner.Err(); err != nil {
-			fmt.Printf("Could not read stderr: %v\n", err)
-		}
-	}()
+	if stderr := transport.Stderr(); stderr != nil {
+		go func() {
+			scanner := bufio.NewScanner(stderr)
+
+			for scanner.Scan() {
+				fmt.Print(scanner.Text())
+			}
+
+			if err := scanner.Err(); err != nil {
+				fmt.Printf("Could not read stderr: %v\n", err)
+			}
+		}()
+	}
 
 	client := client.NewClient(transport)
+	defer client.Close()
+
+	//Remote SSE streams live as long as the start context so don't use the timeout one
+	if err := client.Start(context.Background()); err != nil {
+		return fmt.Errorf("Failed to start MCP transport - %w", err)
+	}
 
 	//Give container 30 sconds to start up
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -167,30 +184,83 @@ func (img *MCPServerImage) getTools() ([]MCPTool, error) {
 		Name:    "",
 		Version: "0",
 	}
-	_, err := client.Initialize(ctx, initRequest)
+	initResult, err := client.Initialize(ctx, initRequest)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	toolsRes, err := client.ListTools(ctx, mcp.ListToolsRequest{})
+	if err != nil {
+		return fmt.Errorf("Failed to list tools - %w", err)
+	}
 
 	b, _ := json.Marshal(toolsRes)
 	fmt.Printf("TOOLS: %s\n", string(b))
 
+	annotatedTimeouts, err := listMCPToolTimeoutAnnotations(ctx, transport)
+	if err != nil {
+		return err
+	}
+
 	for _, tool := range toolsRes.Tools {
-		schemaBytes, err := json.Marshal(tool.InputSchema)
+		t, err := newMCPTool(tool)
 		if err != nil {
-			return nil, fmt.Errorf("Failed to marshal tool input schema - %w\n", err)
+			return err
 		}
 
-		tools = append(tools, MCPTool{
-			Name:        tool.Name,
-			Description: tool.Description,
-			InputSchema: string(schemaBytes),
-		})
+		t.TimeoutSeconds = img.ToolTimeouts.For(tool.Name, annotatedTimeouts[tool.Name])
+		tools = append(tools, t)
 	}
 
-	return tools, nil
+	resources, templates, err := listMCPResources(ctx, client, initResult.Capabilities)
+	if err != nil {
+		return err
+	}
+
+	prompts, err := listMCPPrompts(ctx, client, initResult.Capabilities)
+	if err != nil {
+		return err
+	}
+
+	img.Tools = tools
+	img.Resources = resources
+	img.ResourceTemplates = templates
+	img.Prompts = prompts
+
+	return nil
+}
+
+func newMCPTool(tool mcp.Tool) (MCPTool, error) {
+	schemaBytes, err := json.Marshal(tool.InputSchema)
+	if err != nil {
+		return MCPTool{}, fmt.Errorf("Failed to marshal tool input schema - %w", err)
+	}
+
+	return MCPTool{
+		Name:        tool.Name,
+		Description: tool.Description,
+		InputSchema: string(schemaBytes),
+	}, nil
+}
+
+// EGRESS_ALLOWLIST containers can only be kept to the egress proxy on an existing --internal network
+func checkEgressNetwork(l models.MCPLauncher) error {
+	if l.Sandbox == nil || l.Sandbox.Network.Mode != models.DockerNetworkModeEgressAllowlist {
+		return nil
+	}
+
+	name := l.Sandbox.Network.NetworkName()
+
+	out, err := exec.Command("docker", "network", "inspect", "--format", "{{.Internal}}", name).Output()
+	if err != nil {
+		return fmt.Errorf("Docker network %s doesn't exist, run: make setup-egress-network - %w", name, err)
+	}
+
+	if strings.TrimSpace(string(out)) != "true" {
+		return fmt.Errorf("%w - %s", ErrEgressNetworkNotInternal, name)
+	}
+
+	return nil
 }
 
 func NewMCPServerImage(name string,
@@ -199,34 +269,75 @@ func NewMCPServerImage(name string,
 	dockerImage string,
 	serverType models.MCPServerType,
 	provider string,
-	envSchema map[string]string,
+	envSchema models.EnvSchema,
+	launcher models.MCPLauncher,
+	toolTimeouts models.ToolTimeouts,
+	sampling models.SamplingPolicy,
+	warmPool models.WarmPool,
+	oauthScopes models.OauthScopes,
 ) (*MCPServerImage, error) {
 	//Validation
 	if !serverType.IsValid() {
 		return nil, models.InvalidMCPTypeError
 	}
 
-	if ok, err := validateEnvSchema(envSchema); !ok {
+	if err := launcher.Validate(); err != nil {
+		return nil, fmt.Errorf("Invalid MCP Image Launcher - %w", err)
+	}
+
+	if err := checkEgressNetwork(launcher); err != nil {
+		return nil, fmt.Errorf("Invalid MCP Image Launcher - %w", err)
+	}
+
+	if err := toolTimeouts.Validate(); err != nil {
+		return nil, fmt.Errorf("Invalid MCP Image Tool Timeouts - %w", err)
+	}
+
+	if err := sampling.Validate(); err != nil {
+		return nil, fmt.Errorf("Invalid MCP Image Sampling Policy - %w", err)
+	}
+
+	if err := warmPool.Validate(); err != nil {
+		return nil, fmt.Errorf("Invalid MCP Image Warm Pool - %w", err)
+	}
+
+	//Pooled instances are started before anyone asks for them so there is no user env to give them
+	if warmPool.Enabled() && (serverType != models.MCPServerTypePublic || len(envSchema) > 0) {
+		return nil, ErrWarmPoolNotPublic
+	}
+
+	if len(oauthScopes) > 0 && provider == "" {
+		return nil, ErrOauthScopesWithoutProvider
+	}
+
+	if err := envSchema.Validate(); err != nil {
+		return nil, fmt.Errorf("\nInvalid MCP Image Env Schema\n\t%w\n", err)
+	}
+
+	if err := validateEnvSources(envSchema); err != nil {
 		return nil, fmt.Errorf("\nInvalid MCP Image Env Schema\n\t%w\n", err)
 	}
 
 	// Start a temporary instance to get tools
 
 	s := MCPServerImage{
-		ImageID:     slug + "-v" + strconv.Itoa(version),
-		Slug:        slug,
-		Version:     version,
-		Name:        name,
-		DockerImage: dockerImage,
-		ServerType:  serverType,
-		Provider:    provider,
-		EnvSchema:   envSchema,
+		ImageID:      slug + "-v" + strconv.Itoa(version),
+		Slug:         slug,
+		Version:      version,
+		Name:         name,
+		DockerImage:  dockerImage,
+		ServerType:   serverType,
+		Provider:     provider,
+		EnvSchema:    envSchema,
+		Launcher:     launcher,
+		ToolTimeouts: toolTimeouts,
+		Sampling:     sampling,
+		WarmPool:     warmPool,
+		OauthScopes:  oauthScopes,
 	}
-	tools, err := s.getTools()
-	if err != nil {
+	if err := s.discover(); err != nil {
 		return nil, err
 	}
-	s.Tools = tools
 
 	if err := saveMCPServerImageToDB(&s); err != nil {
 		return nil, fmt.Errorf("%w", err)
@@ -234,3 +345,106 @@ func NewMCPServerImage(name string,
 
 	return &s, nil
 }
+
+func getMCPServerImageTools(imageID string) ([]MCPTool, error) {
+	toolRows, err := Q.GetMCPServerToolsByImage(context.Background(), imageID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get tools for image %s - %w", imageID, err)
+	}
+
+	tools := []MCPTool{}
+
+	for _, t := range toolRows {
+		tools = append(tools, MCPTool{
+			Name:           t.Name,
+			Description:    t.Description.String,
+			InputSchema:    t.Schema,
+			TimeoutSeconds: int(t.TimeoutSeconds),
+		})
+	}
+
+	return tools, nil
+}
+
+func mcpServerImageFromRow(row db.McpServerImage) (*MCPServerImage, error) {
+	tools, err := getMCPServerImageTools(row.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	resources, templates, err := getMCPServerImageResources(row.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	prompts, err := getMCPServerImagePrompts(row.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	img := MCPServerImage{
+		ImageID:      row.ID,
+		Slug:         row.Slug,
+		Version:      int(row.Version),
+		Name:         row.Name,
+		DockerImage:  row.DockerImage,
+		ServerType:   models.MCPServerType(row.Type),
+		Provider:     row.OauthProvider.String,
+		EnvSchema:    row.EnvSchema,
+		Launcher:     row.Launcher,
+		ToolTimeouts: row.ToolTimeouts,
+		Sampling:     row.SamplingPolicy,
+		WarmPool:     row.WarmPool,
+		OauthScopes:  row.OauthScopes,
+		Tools:        tools,
+
+		Resources:         resources,
+		ResourceTemplates: templates,
+		Prompts:           prompts,
+	}
+
+	return &img, nil
+}
+
+func GetMCPServerImages() ([]*MCPServerImage, error) {
+	rows, err := Q.GetMCPServerImages(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get MCP server images - %w", err)
+	}
+
+	imgs := []*MCPServerImage{}
+
+	for _, row := range rows {
+		img, err := mcpServerImageFromRow(row)
+		if err != nil {
+			return nil, err
+		}
+
+		imgs = append(imgs, img)
+	}
+
+	return imgs, nil
+}
+
+func GetMCPServerImageByID(imageID string) (*MCPServerImage, error) {
+	row, err := Q.GetMCPServerImage(context.Background(), imageID)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcpServerImageFromRow(db.McpServerImage{
+		ID:             row.ID,
+		Slug:           row.Slug,
+		Version:        row.Version,
+		Name:           row.Name,
+		DockerImage:    row.DockerImage,
+		Type:           row.Type,
+		OauthProvider:  row.OauthProvider,
+		EnvSchema:      row.EnvSchema,
+		Launcher:       row.Launcher,
+		ToolTimeouts:   row.ToolTimeouts,
+		SamplingPolicy: row.SamplingPolicy,
+		WarmPool:       row.WarmPool,
+		OauthScopes:    row.OauthScopes,
+	})
+}
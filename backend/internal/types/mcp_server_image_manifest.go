@@ -0,0 +1,229 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/db/models"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrInvalidManifest = errors.New("Invalid MCP image manifest")
+
+// Declarative description of an MCP server image. Manifests can be written as YAML or JSON
+type MCPServerImageManifest struct {
+	Name        string               `json:"name" yaml:"name"`
+	Slug        string               `json:"slug" yaml:"slug"`
+	Version     int                  `json:"version" yaml:"version"`
+	DockerImage string               `json:"docker_image" yaml:"docker_image"`
+	ServerType  models.MCPServerType `json:"type" yaml:"type"`
+	Provider    string               `json:"provider,omitempty" yaml:"provider,omitempty"`
+	EnvSchema   models.EnvSchema     `json:"env_schema" yaml:"env_schema"`
+	Launcher    models.MCPLauncher   `json:"launcher,omitempty" yaml:"launcher,omitempty"` // Defaults to docker
+
+	ToolTimeouts models.ToolTimeouts    `json:"tool_timeouts,omitempty" yaml:"tool_timeouts,omitempty"`
+	Sampling     *models.SamplingPolicy `json:"sampling,omitempty" yaml:"sampling,omitempty"`   // Defaults to denied
+	WarmPool     *models.WarmPool       `json:"warm_pool,omitempty" yaml:"warm_pool,omitempty"` // Defaults to no pool
+	OauthScopes  models.OauthScopes     `json:"oauth_scopes,omitempty" yaml:"oauth_scopes,omitempty"`
+}
+
+func (m *MCPServerImageManifest) Validate() error {
+	missing := []string{}
+
+	if m.Name == "" {
+		missing = append(missing, "name")
+	}
+	if m.Slug == "" {
+		missing = append(missing, "slug")
+	}
+	if m.Version < 1 {
+		missing = append(missing, "version")
+	}
+	if m.Launcher.Type == "" {
+		m.Launcher = models.DockerLauncher
+	}
+	if m.Launcher.Type == models.MCPLauncherTypeDocker && m.DockerImage == "" {
+		missing = append(missing, "docker_image")
+	}
+	if m.ServerType == "" {
+		missing = append(missing, "type")
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("%w - missing fields: %s", ErrInvalidManifest, strings.Join(missing, ", "))
+	}
+
+	if !m.ServerType.IsValid() {
+		return fmt.Errorf("%w - %w: %s", ErrInvalidManifest, models.InvalidMCPTypeError, m.ServerType)
+	}
+
+	if err := m.Launcher.Validate(); err != nil {
+		return fmt.Errorf("%w - %w", ErrInvalidManifest, err)
+	}
+
+	if err := m.ToolTimeouts.Validate(); err != nil {
+		return fmt.Errorf("%w - %w", ErrInvalidManifest, err)
+	}
+
+	if m.Sampling != nil {
+		if err := m.Sampling.Validate(); err != nil {
+			return fmt.Errorf("%w - %w", ErrInvalidManifest, err)
+		}
+	}
+
+	if m.WarmPool != nil {
+		if err := m.WarmPool.Validate(); err != nil {
+			return fmt.Errorf("%w - %w", ErrInvalidManifest, err)
+		}
+
+		if m.WarmPool.Enabled() && (m.ServerType != models.MCPServerTypePublic || len(m.EnvSchema) > 0) {
+			return fmt.Errorf("%w - %w", ErrInvalidManifest, ErrWarmPoolNotPublic)
+		}
+	}
+
+	if m.ServerType == models.MCPServerTypeAuthenticatedOauth && m.Provider == "" {
+		return fmt.Errorf("%w - `provider` is required for %s images", ErrInvalidManifest, m.ServerType)
+	}
+
+	if len(m.OauthScopes) > 0 && m.Provider == "" {
+		return fmt.Errorf("%w - %w", ErrInvalidManifest, ErrOauthScopesWithoutProvider)
+	}
+
+	if m.EnvSchema == nil {
+		m.EnvSchema = models.EnvSchema{}
+	}
+
+	if err := m.EnvSchema.Validate(); err != nil {
+		return fmt.Errorf("%w - %w", ErrInvalidManifest, err)
+	}
+
+	if err := validateEnvSources(m.EnvSchema); err != nil {
+		return fmt.Errorf("%w - %w", ErrInvalidManifest, err)
+	}
+
+	return nil
+}
+
+// Decodes a YAML or JSON manifest into v. format is the file extension (yaml, yml or json)
+func decodeManifest(b []byte, format string, v any) error {
+	switch strings.TrimPrefix(strings.ToLower(format), ".") {
+	case "yaml", "yml":
+		return yaml.Unmarshal(b, v)
+	case "json":
+		return json.Unmarshal(b, v)
+	}
+
+	return fmt.Errorf("unsupported manifest format `%s`", format)
+}
+
+// Paths of every *.yaml, *.yml and *.json file in dir sorted by file name
+func manifestPaths(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read manifest directory %s - %w", dir, err)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	paths := []string{}
+
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".yaml", ".yml", ".json":
+			paths = append(paths, filepath.Join(dir, e.Name()))
+		}
+	}
+
+	return paths, nil
+}
+
+// Parses a manifest from raw bytes. format is the file extension (yaml, yml or json)
+func ParseMCPServerImageManifest(b []byte, format string) (*MCPServerImageManifest, error) {
+	m := MCPServerImageManifest{}
+
+	if err := decodeManifest(b, format, &m); err != nil {
+		return nil, fmt.Errorf("%w - %w", ErrInvalidManifest, err)
+	}
+
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+func LoadMCPServerImageManifest(path string) (*MCPServerImageManifest, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read manifest %s - %w", path, err)
+	}
+
+	m, err := ParseMCPServerImageManifest(b, filepath.Ext(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return m, nil
+}
+
+// Loads every *.yaml, *.yml and *.json manifest in dir sorted by file name
+func LoadMCPServerImageManifests(dir string) ([]*MCPServerImageManifest, error) {
+	paths, err := manifestPaths(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	manifests := []*MCPServerImageManifest{}
+
+	for _, path := range paths {
+		m, err := LoadMCPServerImageManifest(path)
+		if err != nil {
+			return nil, err
+		}
+
+		manifests = append(manifests, m)
+	}
+
+	return manifests, nil
+}
+
+// Runs tool discovery for the manifest and registers the image in the DB
+func NewMCPServerImageFromManifest(m *MCPServerImageManifest) (*MCPServerImage, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	return NewMCPServerImage(
+		m.Name,
+		m.Slug,
+		m.Version,
+		m.DockerImage,
+		m.ServerType,
+		m.Provider,
+		m.EnvSchema,
+		m.Launcher,
+		m.ToolTimeouts,
+		func() models.SamplingPolicy {
+			if m.Sampling == nil {
+				return models.SamplingPolicy{}
+			}
+			return *m.Sampling
+		}(),
+		func() models.WarmPool {
+			if m.WarmPool == nil {
+				return models.WarmPool{}
+			}
+			return *m.WarmPool
+		}(),
+		m.OauthScopes,
+	)
+}
@@ -0,0 +1,154 @@
+package types
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+)
+
+var ErrImageVersionExists = errors.New("Image version must be greater than the latest version")
+
+type MCPToolSchemaChange struct {
+	Name           string `json:"name"`
+	OldInputSchema string `json:"old_input_schema"`
+	NewInputSchema string `json:"new_input_schema"`
+}
+
+type MCPToolDiff struct {
+	Added   []MCPTool             `json:"added"`
+	Removed []MCPTool             `json:"removed"`
+	Changed []MCPToolSchemaChange `json:"changed"`
+}
+
+func (d *MCPToolDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Schemas are compared structurally so key order in the stored JSON does not matter
+func sameInputSchema(a string, b string) bool {
+	if a == b {
+		return true
+	}
+
+	var av, bv any
+	if err := json.Unmarshal([]byte(a), &av); err != nil {
+		return false
+	}
+	if err := json.Unmarshal([]byte(b), &bv); err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(av, bv)
+}
+
+func DiffMCPTools(prev []MCPTool, next []MCPTool) *MCPToolDiff {
+	diff := MCPToolDiff{
+		Added:   []MCPTool{},
+		Removed: []MCPTool{},
+		Changed: []MCPToolSchemaChange{},
+	}
+
+	prevByName := map[string]MCPTool{}
+	for _, t := range prev {
+		prevByName[t.Name] = t
+	}
+
+	nextByName := map[string]MCPTool{}
+	for _, t := range next {
+		nextByName[t.Name] = t
+
+		old, ok := prevByName[t.Name]
+		if !ok {
+			diff.Added = append(diff.Added, t)
+		} else if !sameInputSchema(old.InputSchema, t.InputSchema) {
+			diff.Changed = append(diff.Changed, MCPToolSchemaChange{
+				Name:           t.Name,
+				OldInputSchema: old.InputSchema,
+				NewInputSchema: t.InputSchema,
+			})
+		}
+	}
+
+	for _, t := range prev {
+		if _, ok := nextByName[t.Name]; !ok {
+			diff.Removed = append(diff.Removed, t)
+		}
+	}
+
+	sort.Slice(diff.Added, func(i, j int) bool { return diff.Added[i].Name < diff.Added[j].Name })
+	sort.Slice(diff.Removed, func(i, j int) bool { return diff.Removed[i].Name < diff.Removed[j].Name })
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].Name < diff.Changed[j].Name })
+
+	return &diff
+}
+
+/*
+Registers a new version of an existing image. Fields left empty in the manifest are
+inherited from the latest version and the version defaults to latest + 1.
+Returns the new image and the diff of its tools against the previous version.
+*/
+func NewMCPServerImageVersion(slug string, m *MCPServerImageManifest) (*MCPServerImage, *MCPToolDiff, error) {
+	latestRow, err := Q.GetLatestMCPServerImageBySlug(context.Background(), slug)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, fmt.Errorf("No image with slug `%s` - %w", slug, err)
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("Failed to get latest image version - %w", err)
+	}
+
+	latest, err := mcpServerImageFromRow(latestRow)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.Slug = slug
+
+	if m.Version == 0 {
+		m.Version = latest.Version + 1
+	} else if m.Version <= latest.Version {
+		return nil, nil, fmt.Errorf("%w (latest: %d, requested: %d)", ErrImageVersionExists, latest.Version, m.Version)
+	}
+
+	if m.Name == "" {
+		m.Name = latest.Name
+	}
+	if m.DockerImage == "" {
+		m.DockerImage = latest.DockerImage
+	}
+	if m.ServerType == "" {
+		m.ServerType = latest.ServerType
+	}
+	if m.Provider == "" {
+		m.Provider = latest.Provider
+	}
+	if m.EnvSchema == nil {
+		m.EnvSchema = latest.EnvSchema
+	}
+	if m.Launcher.Type == "" {
+		m.Launcher = latest.Launcher
+	}
+	if m.ToolTimeouts.IsEmpty() {
+		m.ToolTimeouts = latest.ToolTimeouts
+	}
+	if m.Sampling == nil {
+		m.Sampling = &latest.Sampling
+	}
+	if m.WarmPool == nil {
+		m.WarmPool = &latest.WarmPool
+	}
+	if m.OauthScopes == nil {
+		m.OauthScopes = latest.OauthScopes
+	}
+
+	img, err := NewMCPServerImageFromManifest(m)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return img, DiffMCPTools(latest.Tools, img.Tools), nil
+}
@@ -1,12 +1,16 @@
 package types
 
 import (
+	"bufio"
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"os"
 	"os/exec"
+	"time"
 
 	"github.com/AbhinavPalacharla/xtrn-personal/internal/db/models"
 	db "github.com/AbhinavPalacharla/xtrn-personal/internal/db/sqlc"
@@ -16,11 +20,22 @@ import (
 
 type MCPServerInstance struct {
 	MCPServerImage
-	InstanceID  string
-	InstanceEnv models.EnvSchema
-	Address     string
+	InstanceID  string             `json:"instance_id"`
+	InstanceEnv models.InstanceEnv `json:"-"`
+	Address     string             `json:"address"`
+	UserID      string             `json:"user_id,omitempty"` // "" for warm pool instances nobody has taken yet
+	// Set for instances shared through a warm pool
+	MaxConcurrentCalls int `json:"-"`
 }
 
+// Max time to wait for the instance process to report its address
+const MAX_INSTANCE_START_TIME = time.Second * 60
+
+var ErrInstanceExited = errors.New("MCP instance exited before it was ready")
+var ErrBinDirNotSet = errors.New("`BIN_DIR` environment variable not set, run: eval $(make setup-env)")
+var ErrInstanceAlreadyOnVersion = errors.New("Instance is already on this version")
+var ErrInstanceDowngrade = errors.New("Instances can't move to a lower version")
+
 const GOOGLE_REFRESH_TOKEN = ""
 
 func saveMCPServerInstanceToDB(inst *MCPServerInstance) error {
@@ -30,6 +45,7 @@ func saveMCPServerInstanceToDB(inst *MCPServerInstance) error {
 		Version: int64(inst.Version),
 		Address: inst.Address,
 		Env:     inst.InstanceEnv,
+		UserID:  nullString(inst.UserID),
 	})
 }
 
@@ -37,125 +53,198 @@ func addCommandFlag(cmd *string, flag string, value string) {
 	*cmd += " " + flag + "=" + value
 }
 
+// fd the instance process reads its env from (first of cmd.ExtraFiles)
+const INSTANCE_ENV_FD = 3
+
+// Writes the env as JSON and closes the pipe so the instance sees EOF
+func writeInstanceEnv(w *os.File, env models.InstanceEnv) error {
+	defer w.Close()
+
+	envJSON, _ := json.Marshal(env)
+	if _, err := w.Write(envJSON); err != nil {
+		return fmt.Errorf("Failed to send env to MCP instance - %w", err)
+	}
+
+	return nil
+}
+
+// Starts the instance's MCP server, or points it at a running one if its image has a SHARED warm pool
 func startMCPServerInstance(inst *MCPServerInstance) error {
+	if assignSharedWarmMCPServerInstance(inst) {
+		return nil
+	}
+
+	return launchMCPServerInstance(inst)
+}
+
+func launchMCPServerInstance(inst *MCPServerInstance) error {
+	if instanceHost != nil {
+		toolTimeouts, err := getMCPServerImageToolTimeouts(inst.ImageID)
+		if err != nil {
+			return err
+		}
+
+		return instanceHost.Start(inst, toolTimeouts)
+	}
+
+	binDir := os.Getenv("BIN_DIR")
+	if binDir == "" {
+		return ErrBinDirNotSet
+	}
+
 	socketPath := fmt.Sprintf("/tmp/xtrn/%s.sock", inst.InstanceID)
 	os.Remove(socketPath)
 
 	ln, err := net.Listen("unix", socketPath)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("Failed to listen on %s - %w", socketPath, err)
 	}
 	defer ln.Close()
 
-	//Start Server
-	binDir := os.Getenv("BIN_DIR")
-	if binDir == "" {
-		panic("`BIN_DIR` Environment vairable not set run: eval $(make setup-env)")
-	}
-
 	//Format command
 	// command := fmt.Sprintf("%s/start-mcp-instance", binDir)
 	// addCommandFlag(&command, "--instance-id", inst.InstanceID)
 	// addCommandFlag(&command, "--docker-image", inst.DockerImage)
 	// addCommandFlag(&command, "--callback-address", socketPath) //When instance HTTP server is ready it will write it to the socket
 
-	// envJSON, _ := json.Marshal(inst.InstanceEnv)
-	// addCommandFlag(&command, "--instance-env", string(envJSON)) //Pass env as json string
-
 	// commandArgs := strings.Split(command, " ")
 
 	commandArgs := []string{
 		fmt.Sprintf("%s/start-mcp-instance", binDir),
 		"--instance-id=" + inst.InstanceID,
+		"--image-id=" + inst.ImageID,
 		"--docker-image=" + inst.DockerImage,
 		"--callback-address=" + socketPath,
 	}
-	envJSON, _ := json.Marshal(inst.InstanceEnv)
-	commandArgs = append(commandArgs, "--instance-env="+string(envJSON))
+	//Env holds oauth secrets so it's written to an inherited pipe instead of argv where `ps` can see it
+	commandArgs = append(commandArgs, fmt.Sprintf("--instance-env-fd=%d", INSTANCE_ENV_FD))
+	launcherJSON, _ := json.Marshal(inst.Launcher)
+	commandArgs = append(commandArgs, "--launcher="+string(launcherJSON))
 
-	fmt.Print(commandArgs)
+	toolTimeouts, err := getMCPServerImageToolTimeouts(inst.ImageID)
+	if err != nil {
+		return err
+	}
+	toolTimeoutsJSON, _ := json.Marshal(toolTimeouts)
+	commandArgs = append(commandArgs, "--tool-timeouts="+string(toolTimeoutsJSON))
+	if inst.MaxConcurrentCalls > 0 {
+		commandArgs = append(commandArgs, fmt.Sprintf("--max-concurrent-calls=%d", inst.MaxConcurrentCalls))
+	}
+
+	envReader, envWriter, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("Failed to create instance env pipe - %w", err)
+	}
+	defer envWriter.Close()
 
 	//Run command
 	cmd := exec.Command(commandArgs[0], commandArgs[1:]...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	//Sidecar output can echo env values so it is scrubbed like the logs
+	cmd.Stdout = NewRedactingWriter(os.Stdout)
+	cmd.Stderr = NewRedactingWriter(os.Stderr)
+	cmd.ExtraFiles = []*os.File{envReader} //fd 3 in the child
 	if err := cmd.Start(); err != nil {
-		panic(err)
+		envReader.Close()
+		return fmt.Errorf("Failed to start MCP instance process - %w", err)
+	}
+	envReader.Close()
+
+	if err := writeInstanceEnv(envWriter, inst.InstanceEnv); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
 	}
 
-	//Read address from socket into inst.addr
+	//If the process dies before dialing back stop waiting on the socket
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+		ln.Close()
+	}()
+
+	deadline := time.Now().Add(MAX_INSTANCE_START_TIME)
+	ln.(*net.UnixListener).SetDeadline(deadline)
+
+	//The instance dials back as soon as it starts and reports on the control socket
 	conn, err := ln.Accept()
 	if err != nil {
-		panic(err)
+		select {
+		case waitErr := <-exited:
+			return fmt.Errorf("%w - %v", ErrInstanceExited, waitErr)
+		default:
+			cmd.Process.Kill()
+			return fmt.Errorf("Failed to receive MCP instance address - %w", err)
+		}
 	}
-	defer conn.Close()
 
-	buf := make([]byte, 1024)
-	n, _ := conn.Read(buf)
+	r := bufio.NewReader(conn)
+
+	address, err := waitForMCPServerInstance(conn, r, deadline)
+	if err != nil {
+		conn.Close()
+		cmd.Process.Kill()
+
+		//A crash shows up as a closed socket, report the exit instead
+		if !errors.Is(err, ErrInstanceInitFailed) {
+			select {
+			case waitErr := <-exited:
+				return fmt.Errorf("%w - %v", ErrInstanceExited, waitErr)
+			case <-time.After(time.Second):
+			}
+		}
+
+		return err
+	}
 
 	//Save address
-	inst.Address = "http://" + string(buf[:n])
+	inst.Address = "http://" + address
+
+	go watchMCPServerInstance(inst.InstanceID, inst.Address, conn, r, cmd)
 
 	return nil
 }
 
-func NewMCPServerInstace(imageID string, userEnv map[string]string) (*MCPServerInstance, error) {
+func NewMCPServerInstace(userID string, imageID string, userEnv map[string]string) (*MCPServerInstance, error) {
 
 	img, err := Q.GetMCPServerImage(context.Background(), imageID)
-	fmt.Printf("IMAGE: %v", img)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := checkOauthConsent(context.Background(), userID, img); err != nil {
+		return nil, err
+	}
+
 	// Instance ID
 	id, _ := gonanoid.New()
 	instID := img.ID + "-inst-" + id
 
-	instanceEnv := models.EnvSchema{}
-
-	for k, v := range img.EnvSchema {
-		//Handle template values
-		if v == "$provider.oauth_client_id" {
-			instanceEnv[k] = img.ClientID.String
-
-		} else if v == "$provider.oauth_client_secret" {
-			instanceEnv[k] = img.ClientSecret.String
-		} else if v == "$user.oauth_refresh_token" {
-			// instanceEnv[k] = "somerefreshtoken123"
-			// instanceEnv[k] = GOOGLE_REFRESH_TOKEN //TODO: Change to goth eventually
-
-			//Get refresh token
-			token, err := Q.GetOauthTokenByProvider(context.Background(), img.OauthProvider.String)
-			if err != nil {
-				return nil, err
-			}
-
-			instanceEnv[k] = token.RefreshToken
-		} else {
-			//If not template then see if it is in userEnv if not then invalid user schema
-
-			if val, ok := userEnv[k]; ok {
-				instanceEnv[k] = val
-			} else {
-				// return nil, errors.New("Invalid User Schema - Missing key %v\n\nExpected Schema: %v\n")
-				return nil, fmt.Errorf("Invalid User Schema - Missing key `%v`\n\nExpected Schema: %v\n", k, userEnv)
-			}
-		}
+	instanceEnv, err := resolveInstanceEnv(img, instID, userID, userEnv)
+	if err != nil {
+		return nil, err
 	}
 
 	inst := MCPServerInstance{
 		MCPServerImage: MCPServerImage{
+			ImageID:     img.ID,
 			Slug:        img.Slug,
 			Version:     int(img.Version),
+			Name:        img.Name,
 			DockerImage: img.DockerImage,
+			Launcher:    img.Launcher,
 		},
 		InstanceID:  instID,
 		InstanceEnv: instanceEnv,
+		UserID:      userID,
 	}
 
 	// fmt.Printf("%#v\n", inst)
 
-	if err := startMCPServerInstance(&inst); err != nil {
+	//Pool images have no user env so an instance can be taken from the image's warm pool already started
+	if warm := takeWarmMCPServerInstance(img.ID); warm != nil {
+		inst = *warm
+		inst.UserID = userID
+	} else if err := startMCPServerInstance(&inst); err != nil {
 		return nil, err
 	}
 
@@ -165,3 +254,207 @@ func NewMCPServerInstace(imageID string, userEnv map[string]string) (*MCPServerI
 
 	return &inst, nil
 }
+
+/*
+Checks userEnv against the image's env schema (reporting every problem at once) and fills in
+vars with a source through the env resolvers
+*/
+func resolveInstanceEnv(img db.GetMCPServerImageRow, instanceID string, userID string, userEnv map[string]string) (models.InstanceEnv, error) {
+	env, err := img.EnvSchema.ValidateUserEnv(userEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	instanceEnv := models.InstanceEnv(env)
+
+	RegisterSecrets(instanceEnvSecrets(img.EnvSchema, instanceEnv)...)
+
+	if err := resolveEnvSources(EnvResolveRequest{Image: img, InstanceID: instanceID, UserID: userID}, instanceEnv); err != nil {
+		return nil, err
+	}
+
+	return instanceEnv, nil
+}
+
+// The values of env a user gave, to carry over to an instance of another image version
+func userEnvForSchema(schema models.EnvSchema, env models.InstanceEnv) map[string]string {
+	userEnv := map[string]string{}
+
+	for k, v := range env {
+		if s, ok := schema[k]; ok && s.IsUserSet() {
+			userEnv[k] = v
+		}
+	}
+
+	return userEnv
+}
+
+/*
+Moves an instance to another version of its image. The user supplied env values are carried over
+and the instance keeps its row and desired state. A running instance is restarted on the new
+version and the row only changes once it is running, if it fails to start the previous version is
+started again with its tools and an error is returned. A stopped instance starts on the new version
+when it is next used. Only higher versions are allowed (ErrInstanceDowngrade).
+*/
+func UpgradeMCPServerInstance(instanceID string, version int) (*MCPServerInstance, *MCPToolDiff, error) {
+	ctx := context.Background()
+
+	unlock := lockMCPServerInstance(instanceID)
+	defer unlock()
+
+	row, err := Q.GetMCPServerInstance(ctx, instanceID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if version == 0 {
+		latest, err := Q.GetLatestMCPServerImageBySlug(ctx, row.Slug)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to get latest image version - %w", err)
+		}
+
+		version = int(latest.Version)
+	}
+
+	if int64(version) == row.Version {
+		return nil, nil, fmt.Errorf("%w - %s is on version %d", ErrInstanceAlreadyOnVersion, instanceID, version)
+	} else if int64(version) < row.Version {
+		return nil, nil, fmt.Errorf("%w - %s is on version %d", ErrInstanceDowngrade, instanceID, row.Version)
+	}
+
+	prevImg, err := Q.GetMCPServerImageBySlugVersion(ctx, db.GetMCPServerImageBySlugVersionParams{
+		Slug:    row.Slug,
+		Version: row.Version,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to get current image - %w", err)
+	}
+
+	nextImg, err := Q.GetMCPServerImageBySlugVersion(ctx, db.GetMCPServerImageBySlugVersionParams{
+		Slug:    row.Slug,
+		Version: int64(version),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to get image %s version %d - %w", row.Slug, version, err)
+	}
+
+	//Versions can need more scopes than the connection was granted
+	if err := checkOauthConsent(ctx, row.UserID.String, db.GetMCPServerImageRow(nextImg)); err != nil {
+		return nil, nil, err
+	}
+
+	//Vars the new version dropped are left behind, ones it added need a default
+	nextEnv, err := resolveInstanceEnv(db.GetMCPServerImageRow(nextImg), row.ID, row.UserID.String, userEnvForSchema(nextImg.EnvSchema, row.Env))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prevTools, err := getMCPServerImageTools(prevImg.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	nextTools, err := getMCPServerImageTools(nextImg.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prev := MCPServerInstance{
+		MCPServerImage: MCPServerImage{
+			ImageID:     prevImg.ID,
+			Slug:        prevImg.Slug,
+			Version:     int(prevImg.Version),
+			Name:        prevImg.Name,
+			DockerImage: prevImg.DockerImage,
+			Launcher:    prevImg.Launcher,
+		},
+		InstanceID:  row.ID,
+		InstanceEnv: row.Env,
+		Address:     row.Address,
+		UserID:      row.UserID.String,
+	}
+
+	next := MCPServerInstance{
+		MCPServerImage: MCPServerImage{
+			ImageID:     nextImg.ID,
+			Slug:        nextImg.Slug,
+			Version:     int(nextImg.Version),
+			Name:        nextImg.Name,
+			DockerImage: nextImg.DockerImage,
+			Launcher:    nextImg.Launcher,
+		},
+		InstanceID:  row.ID,
+		InstanceEnv: nextEnv,
+		UserID:      row.UserID.String,
+	}
+
+	//Kept so a rollback restores them if the previous version can't discover its tools again
+	prevInstanceTools, err := Q.GetMCPServerInstanceTools(ctx, row.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, fmt.Errorf("Failed to get tools of instance %s - %w", row.ID, err)
+	}
+	hadInstanceTools := err == nil
+
+	running := models.MCPInstanceState(row.RuntimeState) == models.MCPInstanceStateRunning && row.Address != ""
+
+	if running {
+		if err := stopMCPServerInstanceProcess(row); err != nil {
+			return nil, nil, err
+		}
+
+		//Tools the previous version discovered don't apply, the new version saves its own when it starts
+		if err := Q.DeleteMCPServerInstanceTools(ctx, row.ID); err != nil {
+			return nil, nil, fmt.Errorf("Failed to remove tools of instance %s - %w", row.ID, err)
+		}
+
+		if startErr := startMCPServerInstance(&next); startErr != nil {
+			// Rollback, the row still has the previous version
+			if hadInstanceTools {
+				if err := Q.UpsertMCPServerInstanceTools(ctx, db.UpsertMCPServerInstanceToolsParams{
+					InstanceID: row.ID,
+					Tools:      prevInstanceTools.Tools,
+				}); err != nil {
+					StdErrLogger.Printf("Failed to restore tools of instance %s - %v\n", row.ID, err)
+				}
+			} else if err := Q.DeleteMCPServerInstanceTools(ctx, row.ID); err != nil {
+				StdErrLogger.Printf("Failed to remove tools of instance %s - %v\n", row.ID, err)
+			}
+
+			if err := startMCPServerInstance(&prev); err != nil {
+				return nil, nil, fmt.Errorf("Failed to start version %d - %w. Rollback to version %d failed, the instance is stopped - %w", next.Version, startErr, prev.Version, err)
+			}
+
+			if err := Q.UpdateMCPServerInstanceRuntimeState(ctx, db.UpdateMCPServerInstanceRuntimeStateParams{
+				RuntimeState: string(models.MCPInstanceStateRunning),
+				Address:      prev.Address,
+				ID:           row.ID,
+			}); err != nil {
+				return nil, nil, fmt.Errorf("Failed to mark instance %s running - %w", row.ID, err)
+			}
+
+			return nil, nil, fmt.Errorf("Failed to start version %d, rolled back to version %d - %w", next.Version, prev.Version, startErr)
+		}
+	}
+
+	if err := Q.UpdateMCPServerInstanceVersion(ctx, db.UpdateMCPServerInstanceVersionParams{
+		Version: int64(next.Version),
+		Env:     next.InstanceEnv,
+		ID:      row.ID,
+	}); err != nil {
+		return nil, nil, fmt.Errorf("Failed to update instance %s - %w", row.ID, err)
+	}
+
+	if running {
+		if err := Q.UpdateMCPServerInstanceRuntimeState(ctx, db.UpdateMCPServerInstanceRuntimeStateParams{
+			RuntimeState: string(models.MCPInstanceStateRunning),
+			Address:      next.Address,
+			ID:           row.ID,
+		}); err != nil {
+			return nil, nil, fmt.Errorf("Failed to mark instance %s running - %w", row.ID, err)
+		}
+	} else if err := Q.DeleteMCPServerInstanceTools(ctx, row.ID); err != nil {
+		//The new version's image tools apply until it starts and discovers its own
+		return nil, nil, fmt.Errorf("Failed to remove tools of instance %s - %w", row.ID, err)
+	}
+
+	return &next, DiffMCPTools(prevTools, nextTools), nil
+}
@@ -0,0 +1,236 @@
+package types
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"sync"
+	"time"
+
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+)
+
+/*
+Control protocol between the API and an instance process over the instance's unix socket.
+The instance dials the socket on startup and writes one JSON message per line:
+
+READY       = HTTP server is listening on Address
+INIT_FAILED = instance couldn't start, Error has the details. The process exits after sending it
+HEARTBEAT   = sent every INSTANCE_HEARTBEAT_INTERVAL until the process exits
+SHUTDOWN    = instance was killed and is exiting, Reason says why
+*/
+const INSTANCE_CONTROL_VERSION = 1
+
+const INSTANCE_HEARTBEAT_INTERVAL = time.Second * 5
+
+// Instances that miss this many heartbeats in a row are considered dead
+const MAX_MISSED_HEARTBEATS = 3
+
+// Time to wait for a control message to be written
+const INSTANCE_CONTROL_WRITE_TIMEOUT = time.Second * 5
+
+var ErrInstanceInitFailed = errors.New("MCP instance failed to start")
+var ErrInstanceControlVersion = errors.New("Unsupported instance control protocol version")
+
+type InstanceControlMessageType string
+
+const (
+	InstanceControlMessageTypeReady      InstanceControlMessageType = "READY"
+	InstanceControlMessageTypeInitFailed InstanceControlMessageType = "INIT_FAILED"
+	InstanceControlMessageTypeHeartbeat  InstanceControlMessageType = "HEARTBEAT"
+	InstanceControlMessageTypeShutdown   InstanceControlMessageType = "SHUTDOWN"
+)
+
+type InstanceControlMessage struct {
+	Version int                        `json:"version"`
+	Type    InstanceControlMessageType `json:"type"`
+	Address string                     `json:"address,omitempty"`
+	Error   string                     `json:"error,omitempty"`
+	Reason  string                     `json:"reason,omitempty"`
+}
+
+func readInstanceControlMessage(r *bufio.Reader) (InstanceControlMessage, error) {
+	msg := InstanceControlMessage{}
+
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return msg, err
+	}
+
+	if err := json.Unmarshal(line, &msg); err != nil {
+		return msg, fmt.Errorf("Invalid instance control message - %w", err)
+	}
+
+	if msg.Version != INSTANCE_CONTROL_VERSION {
+		return msg, fmt.Errorf("%w %d (expected %d)", ErrInstanceControlVersion, msg.Version, INSTANCE_CONTROL_VERSION)
+	}
+
+	return msg, nil
+}
+
+// Instance side of the control socket. A nil *InstanceControl (no callback address) does nothing
+type InstanceControl struct {
+	mu   sync.Mutex
+	conn net.Conn
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func DialInstanceControl(address string) (*InstanceControl, error) {
+	conn, err := net.Dial("unix", address)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to dial control socket %s - %w", address, err)
+	}
+
+	return &InstanceControl{
+		conn: conn,
+		stop: make(chan struct{}),
+	}, nil
+}
+
+func (c *InstanceControl) send(msg InstanceControlMessage) error {
+	if c == nil {
+		return nil
+	}
+
+	msg.Version = INSTANCE_CONTROL_VERSION
+
+	b, _ := json.Marshal(msg)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(INSTANCE_CONTROL_WRITE_TIMEOUT))
+
+	if _, err := c.conn.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("Failed to send %s control message - %w", msg.Type, err)
+	}
+
+	return nil
+}
+
+func (c *InstanceControl) Ready(address string) error {
+	return c.send(InstanceControlMessage{
+		Type:    InstanceControlMessageTypeReady,
+		Address: address,
+	})
+}
+
+// Errors can include env values so they're redacted before leaving the process
+func (c *InstanceControl) InitFailed(err error) error {
+	return c.send(InstanceControlMessage{
+		Type:  InstanceControlMessageTypeInitFailed,
+		Error: Redact(err.Error()),
+	})
+}
+
+func (c *InstanceControl) Shutdown(reason string) error {
+	c.stopHeartbeats()
+
+	return c.send(InstanceControlMessage{
+		Type:   InstanceControlMessageTypeShutdown,
+		Reason: reason,
+	})
+}
+
+// Sends heartbeats until Shutdown is called or the API stops reading them
+func (c *InstanceControl) Heartbeat() error {
+	if c == nil {
+		return nil
+	}
+
+	ticker := time.NewTicker(INSTANCE_HEARTBEAT_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return nil
+		case <-ticker.C:
+			if err := c.send(InstanceControlMessage{Type: InstanceControlMessageTypeHeartbeat}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (c *InstanceControl) stopHeartbeats() {
+	if c != nil {
+		c.stopOnce.Do(func() { close(c.stop) })
+	}
+}
+
+func (c *InstanceControl) Close() error {
+	if c == nil {
+		return nil
+	}
+
+	c.stopHeartbeats()
+
+	return c.conn.Close()
+}
+
+/*
+Waits for the instance to report READY or INIT_FAILED. Heartbeats sent while the
+MCP server is starting are skipped. The deadline covers the whole startup.
+*/
+func waitForMCPServerInstance(conn net.Conn, r *bufio.Reader, deadline time.Time) (string, error) {
+	conn.SetReadDeadline(deadline)
+
+	for {
+		msg, err := readInstanceControlMessage(r)
+		if err != nil {
+			return "", fmt.Errorf("Failed to receive MCP instance address - %w", err)
+		}
+
+		switch msg.Type {
+		case InstanceControlMessageTypeReady:
+			return msg.Address, nil
+
+		case InstanceControlMessageTypeInitFailed:
+			return "", fmt.Errorf("%w - %s", ErrInstanceInitFailed, msg.Error)
+
+		case InstanceControlMessageTypeShutdown:
+			return "", fmt.Errorf("%w - %s", ErrInstanceExited, msg.Reason)
+		}
+	}
+}
+
+/*
+Reads heartbeats from a running instance. If the process exits or stops sending heartbeats
+without a SHUTDOWN it is killed and the instances using it are marked stopped (started again
+when they are next used). Rows since moved to another process (e.g. an upgrade) are left alone.
+*/
+func watchMCPServerInstance(instanceID string, address string, conn net.Conn, r *bufio.Reader, cmd *exec.Cmd) {
+	defer conn.Close()
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(INSTANCE_HEARTBEAT_INTERVAL * MAX_MISSED_HEARTBEATS))
+
+		msg, err := readInstanceControlMessage(r)
+		if err != nil {
+			StdErrLogger.Printf("Lost MCP instance %s - %v\n", instanceID, err)
+			cmd.Process.Kill()
+			markLostMCPServerInstanceStopped(instanceID, address)
+			return
+		}
+
+		if msg.Type == InstanceControlMessageTypeShutdown {
+			return
+		}
+	}
+}
+
+// Several instances can use one process through a SHARED warm pool so rows are matched by address
+func markLostMCPServerInstanceStopped(instanceID string, address string) {
+	forgetWarmPoolMember(address)
+
+	if err := Q.StopMCPServerInstancesByAddress(context.Background(), address); err != nil {
+		StdErrLogger.Printf("Failed to mark instances of %s stopped - %v\n", instanceID, err)
+	}
+}
@@ -0,0 +1,51 @@
+package types
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+)
+
+/*
+Runs instances inside the API process instead of a start-mcp-instance process per instance.
+Start sets inst.Address to where the instance's /callTool etc. are served.
+*/
+type MCPInstanceHost interface {
+	Start(inst *MCPServerInstance, toolTimeouts map[string]int) error
+	// Stops the instance's MCP server and keeps the instance
+	Stop(instanceID string) error
+	// Stops the instance's MCP server and removes the instance
+	Kill(instanceID string) error
+	// Whether address is an instance served by this host (instances left over from sidecar mode aren't)
+	Serves(address string) bool
+}
+
+// nil = every instance runs in its own process
+var instanceHost MCPInstanceHost
+
+func SetMCPInstanceHost(host MCPInstanceHost) {
+	instanceHost = host
+}
+
+// Hosted instances stopped with the last run, they are started again when they are next used
+func MarkHostedMCPServerInstancesStopped() error {
+	if instanceHost == nil {
+		return nil
+	}
+
+	rows, err := Q.GetAllMCPServerInstances(context.Background())
+	if err != nil {
+		return fmt.Errorf("Failed to get instances - %w", err)
+	}
+
+	for _, row := range rows {
+		if instanceHost.Serves(row.Address) {
+			if err := setMCPServerInstanceStopped(row.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,374 @@
+package types
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/db/models"
+	db "github.com/AbhinavPalacharla/xtrn-personal/internal/db/sqlc"
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+/*
+Instances have a desired state (set by the user with /start and /stop) and a runtime state
+(whether its MCP server is running). Instances that should be running are listed and started
+when they are first used, then stopped again once they've been idle for the idle timeout.
+*/
+
+// Set with INSTANCE_IDLE_TIMEOUT (e.g. "30m"), 0 keeps instances running until they are stopped
+const DEFAULT_INSTANCE_IDLE_TIMEOUT = time.Minute * 30
+
+var ErrInstanceStopped = errors.New("MCP instance is stopped")
+
+func InstanceIdleTimeout() (time.Duration, error) {
+	raw := os.Getenv("INSTANCE_IDLE_TIMEOUT")
+	if raw == "" {
+		return DEFAULT_INSTANCE_IDLE_TIMEOUT, nil
+	}
+
+	timeout, err := time.ParseDuration(raw)
+	if err != nil || timeout < 0 {
+		return 0, fmt.Errorf("Invalid INSTANCE_IDLE_TIMEOUT `%s` - must be a duration like 30m", raw)
+	}
+
+	return timeout, nil
+}
+
+// Start and stop of an instance can't overlap
+var instanceLocks sync.Map
+
+func lockMCPServerInstance(instanceID string) func() {
+	l, _ := instanceLocks.LoadOrStore(instanceID, &sync.Mutex{})
+	mu := l.(*sync.Mutex)
+
+	mu.Lock()
+	return mu.Unlock
+}
+
+type MCPInstanceColdStart struct {
+	ID         string     `json:"id"`
+	InstanceID string     `json:"instance_id"`
+	DurationMs int64      `json:"duration_ms"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+}
+
+func setMCPServerInstanceStopped(instanceID string) error {
+	if err := Q.UpdateMCPServerInstanceRuntimeState(context.Background(), db.UpdateMCPServerInstanceRuntimeStateParams{
+		RuntimeState: string(models.MCPInstanceStateStopped),
+		Address:      "",
+		ID:           instanceID,
+	}); err != nil {
+		return fmt.Errorf("Failed to mark instance %s stopped - %w", instanceID, err)
+	}
+
+	return nil
+}
+
+// Starts an instance from its row with the env it was saved with
+func startSavedMCPServerInstance(row db.McpServerInstance) (*MCPServerInstance, error) {
+	img, err := Q.GetMCPServerImageBySlugVersion(context.Background(), db.GetMCPServerImageBySlugVersionParams{
+		Slug:    row.Slug,
+		Version: row.Version,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get image for instance %s - %w", row.ID, err)
+	}
+
+	RegisterSecrets(instanceEnvSecrets(img.EnvSchema, row.Env)...)
+
+	inst := MCPServerInstance{
+		MCPServerImage: MCPServerImage{
+			ImageID:     img.ID,
+			Slug:        img.Slug,
+			Version:     int(img.Version),
+			Name:        img.Name,
+			DockerImage: img.DockerImage,
+			Launcher:    img.Launcher,
+		},
+		InstanceID:  row.ID,
+		InstanceEnv: row.Env,
+		UserID:      row.UserID.String,
+	}
+
+	if err := startMCPServerInstance(&inst); err != nil {
+		return nil, err
+	}
+
+	return &inst, nil
+}
+
+func recordMCPServerInstanceColdStart(instanceID string, duration time.Duration, startErr error) {
+	id, _ := gonanoid.New()
+
+	errMsg := ""
+	if startErr != nil {
+		errMsg = Redact(startErr.Error())
+	}
+
+	if err := Q.InsertMCPServerInstanceColdStart(context.Background(), db.InsertMCPServerInstanceColdStartParams{
+		ID:         id,
+		InstanceID: instanceID,
+		DurationMs: duration.Milliseconds(),
+		Error:      nullString(errMsg),
+	}); err != nil {
+		StdErrLogger.Printf("Failed to record cold start of %s - %v\n", instanceID, err)
+	}
+}
+
+/*
+Returns the address of a running instance, starting it first if it was stopped (cold start).
+Every call counts as a use for the idle timeout.
+*/
+func EnsureMCPServerInstanceRunning(instanceID string) (string, error) {
+	unlock := lockMCPServerInstance(instanceID)
+	defer unlock()
+
+	ctx := context.Background()
+
+	row, err := Q.GetMCPServerInstance(ctx, instanceID)
+	if err != nil {
+		return "", fmt.Errorf("Failed to get instance %s - %w", instanceID, err)
+	}
+
+	if models.MCPInstanceState(row.DesiredState) == models.MCPInstanceStateStopped {
+		return "", fmt.Errorf("%w - %s", ErrInstanceStopped, instanceID)
+	}
+
+	address := row.Address
+
+	if models.MCPInstanceState(row.RuntimeState) != models.MCPInstanceStateRunning || address == "" {
+		start := time.Now()
+		inst, err := startSavedMCPServerInstance(row)
+		recordMCPServerInstanceColdStart(instanceID, time.Since(start), err)
+
+		if err != nil {
+			return "", err
+		}
+
+		if err := Q.UpdateMCPServerInstanceRuntimeState(ctx, db.UpdateMCPServerInstanceRuntimeStateParams{
+			RuntimeState: string(models.MCPInstanceStateRunning),
+			Address:      inst.Address,
+			ID:           instanceID,
+		}); err != nil {
+			return "", fmt.Errorf("Failed to mark instance %s running - %w", instanceID, err)
+		}
+
+		address = inst.Address
+	}
+
+	TouchMCPServerInstance(instanceID)
+
+	return address, nil
+}
+
+// Counts as a use of the instance for the idle timeout
+func TouchMCPServerInstance(instanceID string) {
+	if err := Q.TouchMCPServerInstance(context.Background(), instanceID); err != nil {
+		StdErrLogger.Printf("Failed to update last use of %s - %v\n", instanceID, err)
+	}
+}
+
+// Stops the instance's MCP server and keeps the instance, it can be started again
+func scaleDownMCPServerInstance(instanceID string) error {
+	unlock := lockMCPServerInstance(instanceID)
+	defer unlock()
+
+	row, err := Q.GetMCPServerInstance(context.Background(), instanceID)
+	if err != nil {
+		return fmt.Errorf("Failed to get instance %s - %w", instanceID, err)
+	}
+
+	return stopMCPServerInstanceProcess(row)
+}
+
+// Asks a sidecar instance to stop, authenticated with its key
+func postInstanceStop(row db.McpServerInstance) error {
+	req, err := http.NewRequest(http.MethodPost, row.Address+"/stop", nil)
+	if err != nil {
+		return err
+	}
+	if err := SetInstanceKeyHeader(req, row.ID); err != nil {
+		return err
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	return nil
+}
+
+// Stops the MCP server of an instance row and marks it stopped. The caller holds the instance's lock
+func stopMCPServerInstanceProcess(row db.McpServerInstance) error {
+	instanceID := row.ID
+
+	if models.MCPInstanceState(row.RuntimeState) == models.MCPInstanceStateStopped || row.Address == "" {
+		return nil
+	}
+
+	//Instances sharing a warm pool member leave it running for the others
+	if isSharedWarmAddress(row.Address) {
+		return setMCPServerInstanceStopped(instanceID)
+	}
+
+	//If the instance is already gone there is nothing to stop, it is marked stopped either way
+	if instanceHost != nil && instanceHost.Serves(row.Address) {
+		if err := instanceHost.Stop(instanceID); err != nil {
+			StdErrLogger.Printf("Failed to stop MCP instance %s - %v\n", instanceID, err)
+		}
+	} else if err := postInstanceStop(row); err != nil {
+		StdErrLogger.Printf("Failed to make request to /stop for %s - %v\n", instanceID, err)
+	}
+
+	return setMCPServerInstanceStopped(instanceID)
+}
+
+// Sets the instance's desired state to running and starts it
+func StartMCPServerInstance(instanceID string) (string, error) {
+	if err := Q.UpdateMCPServerInstanceDesiredState(context.Background(), db.UpdateMCPServerInstanceDesiredStateParams{
+		DesiredState: string(models.MCPInstanceStateRunning),
+		ID:           instanceID,
+	}); err != nil {
+		return "", fmt.Errorf("Failed to update instance %s - %w", instanceID, err)
+	}
+
+	return EnsureMCPServerInstanceRunning(instanceID)
+}
+
+// Sets the instance's desired state to stopped so it isn't listed or started, and stops it
+func StopMCPServerInstance(instanceID string) error {
+	//Held across both so EnsureMCPServerInstanceRunning can't start it in between
+	unlock := lockMCPServerInstance(instanceID)
+	defer unlock()
+
+	row, err := Q.GetMCPServerInstance(context.Background(), instanceID)
+	if err != nil {
+		return fmt.Errorf("Failed to get instance %s - %w", instanceID, err)
+	}
+
+	if err := Q.UpdateMCPServerInstanceDesiredState(context.Background(), db.UpdateMCPServerInstanceDesiredStateParams{
+		DesiredState: string(models.MCPInstanceStateStopped),
+		ID:           instanceID,
+	}); err != nil {
+		return fmt.Errorf("Failed to update instance %s - %w", instanceID, err)
+	}
+
+	return stopMCPServerInstanceProcess(row)
+}
+
+// Stops running instances that haven't been used for idleTimeout
+func StopIdleMCPServerInstances(idleTimeout time.Duration) error {
+	rows, err := Q.GetIdleMCPServerInstances(context.Background(), fmt.Sprintf("-%d seconds", int(idleTimeout.Seconds())))
+	if err != nil {
+		return fmt.Errorf("Failed to get idle instances - %w", err)
+	}
+
+	for _, row := range rows {
+		if err := scaleDownMCPServerInstance(row.ID); err != nil {
+			StdErrLogger.Print(err)
+		}
+	}
+
+	return nil
+}
+
+// Stops idle instances until ctx is done. An idle timeout of 0 never stops them
+func WatchIdleMCPServerInstances(ctx context.Context, idleTimeout time.Duration) {
+	if idleTimeout == 0 {
+		return
+	}
+
+	//Instances run at most half a timeout past it
+	ticker := time.NewTicker(idleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := StopIdleMCPServerInstances(idleTimeout); err != nil {
+				StdErrLogger.Print(err)
+			}
+		}
+	}
+}
+
+func GetMCPServerInstanceColdStarts(instanceID string) ([]MCPInstanceColdStart, error) {
+	rows, err := Q.GetMCPServerInstanceColdStarts(context.Background(), instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get cold starts for instance %s - %w", instanceID, err)
+	}
+
+	starts := []MCPInstanceColdStart{}
+
+	for _, r := range rows {
+		s := MCPInstanceColdStart{
+			ID:         r.ID,
+			InstanceID: r.InstanceID,
+			DurationMs: r.DurationMs,
+			Error:      r.Error.String,
+		}
+
+		if r.CreatedAt.Valid {
+			s.CreatedAt = &r.CreatedAt.Time
+		}
+
+		starts = append(starts, s)
+	}
+
+	return starts, nil
+}
+
+/*
+EnsureMCPServerInstanceRunning through the API's /wake endpoint. For processes that aren't
+the API (e.g. the xtrn MCP server) so instances are only ever started by the API. The request
+is authenticated with the instance's key.
+*/
+func RequestMCPServerInstanceRunning(ctx context.Context, instanceID string) (string, error) {
+	instanceKey, err := InstanceKey(instanceID)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, APIAddress()+"/instances/"+instanceID+"/wake", bytes.NewBuffer(nil))
+	if err != nil {
+		return "", fmt.Errorf("Failed to create /wake request - %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+instanceKey)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("Failed to make request to /wake - %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read /wake response - %w", err)
+	}
+
+	var wakeRes struct {
+		Address string `json:"address"`
+		Error   string `json:"error"`
+	}
+	json.Unmarshal(body, &wakeRes)
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Failed to start instance %s - status %d: %s", instanceID, res.StatusCode, wakeRes.Error)
+	}
+
+	return wakeRes.Address, nil
+}
@@ -0,0 +1,143 @@
+package types
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	db "github.com/AbhinavPalacharla/xtrn-personal/internal/db/sqlc"
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Tools from different instances are namespaced as <instance id>___<tool name>
+const MCP_TOOL_NAME_SEPARATOR = "___"
+
+type MCPInstanceTool struct {
+	Name        string         `json:"name"` // Namespaced name
+	ToolName    string         `json:"tool_name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"input_schema"`
+	InstanceID  string         `json:"instance_id"`
+	Address     string         `json:"address"`
+}
+
+func NamespaceMCPToolName(instanceID string, toolName string) string {
+	return instanceID + MCP_TOOL_NAME_SEPARATOR + toolName
+}
+
+// Returns the instance ID and tool name from a namespaced tool name
+func SplitMCPToolName(name string) (string, string, bool) {
+	return strings.Cut(name, MCP_TOOL_NAME_SEPARATOR)
+}
+
+/*
+Saves the tools an instance discovered with its real env. They replace the image's tools
+(discovered with placeholder env) for the instance
+*/
+func SaveMCPInstanceTools(instanceID string, tools []mcp.Tool) error {
+	instanceTools := []MCPTool{}
+
+	for _, tool := range tools {
+		t, err := newMCPTool(tool)
+		if err != nil {
+			return err
+		}
+
+		instanceTools = append(instanceTools, t)
+	}
+
+	b, err := json.Marshal(instanceTools)
+	if err != nil {
+		return fmt.Errorf("Failed to marshal instance tools - %w", err)
+	}
+
+	if err := Q.UpsertMCPServerInstanceTools(context.Background(), db.UpsertMCPServerInstanceToolsParams{
+		InstanceID: instanceID,
+		Tools:      string(b),
+	}); err != nil {
+		return fmt.Errorf("Failed to save tools for instance %s - %w", instanceID, err)
+	}
+
+	return nil
+}
+
+// Instance ID -> tools for instances that discovered their own tools
+func getMCPInstanceDiscoveredTools() (map[string][]MCPTool, error) {
+	rows, err := Q.GetAllMCPServerInstanceTools(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get instance tools - %w", err)
+	}
+
+	discovered := map[string][]MCPTool{}
+
+	for _, r := range rows {
+		tools := []MCPTool{}
+		if err := json.Unmarshal([]byte(r.Tools), &tools); err != nil {
+			return nil, fmt.Errorf("Failed to parse tools for instance %s - %w", r.InstanceID, err)
+		}
+
+		discovered[r.InstanceID] = tools
+	}
+
+	return discovered, nil
+}
+
+func newMCPInstanceTool(instanceID string, address string, name string, description string, inputSchema string) MCPInstanceTool {
+	schema := map[string]any{}
+	json.Unmarshal([]byte(inputSchema), &schema)
+
+	return MCPInstanceTool{
+		Name:        NamespaceMCPToolName(instanceID, name),
+		ToolName:    name,
+		Description: description,
+		InputSchema: schema,
+		InstanceID:  instanceID,
+		Address:     address,
+	}
+}
+
+// Tools of every running instance of the user sorted by namespaced name
+func GetMCPInstanceTools(userID string) ([]MCPInstanceTool, error) {
+	rows, err := Q.GetMCPServerInstances(context.Background(), nullString(userID))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get MPC instances - %w", err)
+	}
+
+	discovered, err := getMCPInstanceDiscoveredTools()
+	if err != nil {
+		return nil, err
+	}
+
+	tools := []MCPInstanceTool{}
+	added := map[string]bool{}
+
+	for _, r := range rows {
+		//Instance discovered its own tools, rows are per image tool so only add them once
+		if instanceTools, ok := discovered[r.InstanceID]; ok {
+			if added[r.InstanceID] {
+				continue
+			}
+			added[r.InstanceID] = true
+
+			for _, t := range instanceTools {
+				tools = append(tools, newMCPInstanceTool(r.InstanceID, r.Address, t.Name, t.Description, t.InputSchema))
+			}
+
+			continue
+		}
+
+		//Instance image has no tools
+		if !r.ToolName.Valid {
+			continue
+		}
+
+		tools = append(tools, newMCPInstanceTool(r.InstanceID, r.Address, r.ToolName.String, r.ToolDesc.String, r.ToolSchema.String))
+	}
+
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+
+	return tools, nil
+}
@@ -0,0 +1,309 @@
+package types
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/db/models"
+	db "github.com/AbhinavPalacharla/xtrn-personal/internal/db/sqlc"
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+var ErrPromptNotFound = errors.New("Prompt not found")
+var ErrInvalidPromptCommand = errors.New("Invalid prompt command")
+
+type MCPPrompt struct {
+	Name        string                    `json:"name"`
+	Description string                    `json:"description"`
+	Arguments   models.MCPPromptArguments `json:"arguments"`
+}
+
+// Lists prompts from a running server. Servers without the prompts capability have none
+func listMCPPrompts(ctx context.Context, c *client.Client, capabilities mcp.ServerCapabilities) ([]MCPPrompt, error) {
+	prompts := []MCPPrompt{}
+
+	if capabilities.Prompts == nil {
+		return prompts, nil
+	}
+
+	promptsRes, err := c.ListPrompts(ctx, mcp.ListPromptsRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list prompts - %w", err)
+	}
+
+	for _, p := range promptsRes.Prompts {
+		args := models.MCPPromptArguments{}
+
+		for _, a := range p.Arguments {
+			args = append(args, models.MCPPromptArgument{
+				Name:        a.Name,
+				Description: a.Description,
+				Required:    a.Required,
+			})
+		}
+
+		prompts = append(prompts, MCPPrompt{
+			Name:        p.Name,
+			Description: p.Description,
+			Arguments:   args,
+		})
+	}
+
+	return prompts, nil
+}
+
+func saveMCPServerImagePrompts(qtx *db.Queries, img *MCPServerImage) error {
+	for _, p := range img.Prompts {
+		id, _ := gonanoid.New()
+
+		if err := qtx.InsertMCPServerPrompt(context.Background(), db.InsertMCPServerPromptParams{
+			ID:          id,
+			Name:        p.Name,
+			Description: nullString(p.Description),
+			Arguments:   p.Arguments,
+			ImageID:     img.ImageID,
+		}); err != nil {
+			return fmt.Errorf("Prompts: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func getMCPServerImagePrompts(imageID string) ([]MCPPrompt, error) {
+	rows, err := Q.GetMCPServerPromptsByImage(context.Background(), imageID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get prompts for image %s - %w", imageID, err)
+	}
+
+	prompts := []MCPPrompt{}
+
+	for _, r := range rows {
+		prompts = append(prompts, MCPPrompt{
+			Name:        r.Name,
+			Description: r.Description.String,
+			Arguments:   r.Arguments,
+		})
+	}
+
+	return prompts, nil
+}
+
+/*
+Prompt of a running instance. Command is what the user types after the slash: the prompt name
+if only one instance has a prompt with that name, otherwise <instance id>___<prompt name>
+*/
+type MCPInstancePrompt struct {
+	MCPPrompt
+	Command    string `json:"command"`
+	InstanceID string `json:"instance_id"`
+	Address    string `json:"-"`
+}
+
+// Prompts of the user's running instances
+func GetMCPInstancePrompts(userID string) ([]MCPInstancePrompt, error) {
+	rows, err := Q.GetMCPServerInstancePrompts(context.Background(), nullString(userID))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get instance prompts - %w", err)
+	}
+
+	nameCount := map[string]int{}
+	for _, r := range rows {
+		nameCount[r.PromptName]++
+	}
+
+	prompts := []MCPInstancePrompt{}
+
+	for _, r := range rows {
+		command := r.PromptName
+		if nameCount[r.PromptName] > 1 {
+			command = NamespaceMCPToolName(r.InstanceID, r.PromptName)
+		}
+
+		prompts = append(prompts, MCPInstancePrompt{
+			MCPPrompt: MCPPrompt{
+				Name:        r.PromptName,
+				Description: r.PromptDesc.String,
+				Arguments:   r.PromptArguments,
+			},
+			Command:    command,
+			InstanceID: r.InstanceID,
+			Address:    r.Address,
+		})
+	}
+
+	sort.Slice(prompts, func(i, j int) bool { return prompts[i].Command < prompts[j].Command })
+
+	return prompts, nil
+}
+
+// Finds the user's prompt for a slash command. The namespaced form always works
+func FindMCPInstancePrompt(userID string, command string) (*MCPInstancePrompt, error) {
+	prompts, err := GetMCPInstancePrompts(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range prompts {
+		if p.Command == command || NamespaceMCPToolName(p.InstanceID, p.Name) == command {
+			return &p, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: /%s", ErrPromptNotFound, command)
+}
+
+// Splits on spaces except inside double quotes
+func splitPromptCommand(s string) ([]string, error) {
+	fields := []string{}
+	var sb strings.Builder
+	inQuotes := false
+	hasField := false
+
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			hasField = true
+		case unicode.IsSpace(r) && !inQuotes:
+			if hasField {
+				fields = append(fields, sb.String())
+				sb.Reset()
+				hasField = false
+			}
+		default:
+			sb.WriteRune(r)
+			hasField = true
+		}
+	}
+
+	if inQuotes {
+		return nil, fmt.Errorf("%w - unterminated quote", ErrInvalidPromptCommand)
+	}
+
+	if hasField {
+		fields = append(fields, sb.String())
+	}
+
+	return fields, nil
+}
+
+/*
+Parses a `/promptName arg=value arg2="some value"` message. ok is false if the message is
+not a slash command.
+*/
+func ParsePromptCommand(content string) (command string, args map[string]string, ok bool, err error) {
+	content = strings.TrimSpace(content)
+
+	if !strings.HasPrefix(content, "/") {
+		return "", nil, false, nil
+	}
+
+	fields, err := splitPromptCommand(content[1:])
+	if err != nil {
+		return "", nil, true, err
+	}
+
+	if len(fields) == 0 || fields[0] == "" {
+		return "", nil, true, fmt.Errorf("%w - missing prompt name", ErrInvalidPromptCommand)
+	}
+
+	args = map[string]string{}
+
+	for _, f := range fields[1:] {
+		k, v, found := strings.Cut(f, "=")
+		if !found || k == "" {
+			return "", nil, true, fmt.Errorf("%w - expected arg=value, got `%s`", ErrInvalidPromptCommand, f)
+		}
+
+		args[k] = v
+	}
+
+	return fields[0], args, true, nil
+}
+
+func (p *MCPInstancePrompt) validateArguments(args map[string]string) error {
+	known := map[string]bool{}
+
+	for _, a := range p.Arguments {
+		known[a.Name] = true
+
+		if _, ok := args[a.Name]; a.Required && !ok {
+			return fmt.Errorf("%w - /%s requires argument `%s`", ErrInvalidPromptCommand, p.Command, a.Name)
+		}
+	}
+
+	for k := range args {
+		if !known[k] {
+			return fmt.Errorf("%w - /%s has no argument `%s`", ErrInvalidPromptCommand, p.Command, k)
+		}
+	}
+
+	return nil
+}
+
+type GetPromptRequest struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments"`
+}
+
+// Expands the prompt through the instance's /getPrompt endpoint
+func (p *MCPInstancePrompt) GetMessages(args map[string]string) ([]mcp.PromptMessage, error) {
+	if err := p.validateArguments(args); err != nil {
+		return nil, err
+	}
+
+	payload, _ := json.Marshal(GetPromptRequest{
+		Name:      p.Name,
+		Arguments: args,
+	})
+
+	address, err := EnsureMCPServerInstanceRunning(p.InstanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, address+"/getPrompt", bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create /getPrompt request - %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := SetInstanceKeyHeader(req, p.InstanceID); err != nil {
+		return nil, err
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to make request to /getPrompt - %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read /getPrompt response - %w", err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Failed to get prompt %s from %s - status %d: %s", p.Name, p.InstanceID, res.StatusCode, string(body))
+	}
+
+	raw := json.RawMessage(body)
+
+	promptRes, err := mcp.ParseGetPromptResult(&raw)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse prompt %s - %w", p.Name, err)
+	}
+
+	return promptRes.Messages, nil
+}
@@ -0,0 +1,198 @@
+package types
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	db "github.com/AbhinavPalacharla/xtrn-personal/internal/db/sqlc"
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+type MCPResource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	MIMEType    string `json:"mime_type"`
+}
+
+type MCPResourceTemplate struct {
+	URITemplate string `json:"uri_template"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	MIMEType    string `json:"mime_type"`
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{
+		String: s,
+		Valid:  s != "",
+	}
+}
+
+// Lists resources and resource templates from a running server. Servers without the resources capability have none
+func listMCPResources(ctx context.Context, c *client.Client, capabilities mcp.ServerCapabilities) ([]MCPResource, []MCPResourceTemplate, error) {
+	resources := []MCPResource{}
+	templates := []MCPResourceTemplate{}
+
+	if capabilities.Resources == nil {
+		return resources, templates, nil
+	}
+
+	resourcesRes, err := c.ListResources(ctx, mcp.ListResourcesRequest{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to list resources - %w", err)
+	}
+
+	for _, r := range resourcesRes.Resources {
+		resources = append(resources, MCPResource{
+			URI:         r.URI,
+			Name:        r.Name,
+			Description: r.Description,
+			MIMEType:    r.MIMEType,
+		})
+	}
+
+	templatesRes, err := c.ListResourceTemplates(ctx, mcp.ListResourceTemplatesRequest{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to list resource templates - %w", err)
+	}
+
+	for _, t := range templatesRes.ResourceTemplates {
+		uriTemplate := ""
+		if t.URITemplate != nil && t.URITemplate.Template != nil {
+			uriTemplate = t.URITemplate.Raw()
+		}
+
+		templates = append(templates, MCPResourceTemplate{
+			URITemplate: uriTemplate,
+			Name:        t.Name,
+			Description: t.Description,
+			MIMEType:    t.MIMEType,
+		})
+	}
+
+	return resources, templates, nil
+}
+
+func saveMCPServerImageResources(qtx *db.Queries, img *MCPServerImage) error {
+	for _, r := range img.Resources {
+		id, _ := gonanoid.New()
+
+		if err := qtx.InsertMCPServerResource(context.Background(), db.InsertMCPServerResourceParams{
+			ID:          id,
+			Uri:         r.URI,
+			Name:        r.Name,
+			Description: nullString(r.Description),
+			MimeType:    nullString(r.MIMEType),
+			ImageID:     img.ImageID,
+		}); err != nil {
+			return fmt.Errorf("Resources: %w", err)
+		}
+	}
+
+	for _, t := range img.ResourceTemplates {
+		id, _ := gonanoid.New()
+
+		if err := qtx.InsertMCPServerResourceTemplate(context.Background(), db.InsertMCPServerResourceTemplateParams{
+			ID:          id,
+			UriTemplate: t.URITemplate,
+			Name:        t.Name,
+			Description: nullString(t.Description),
+			MimeType:    nullString(t.MIMEType),
+			ImageID:     img.ImageID,
+		}); err != nil {
+			return fmt.Errorf("Resource templates: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func getMCPServerImageResources(imageID string) ([]MCPResource, []MCPResourceTemplate, error) {
+	resourceRows, err := Q.GetMCPServerResourcesByImage(context.Background(), imageID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to get resources for image %s - %w", imageID, err)
+	}
+
+	templateRows, err := Q.GetMCPServerResourceTemplatesByImage(context.Background(), imageID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to get resource templates for image %s - %w", imageID, err)
+	}
+
+	resources := []MCPResource{}
+	for _, r := range resourceRows {
+		resources = append(resources, MCPResource{
+			URI:         r.Uri,
+			Name:        r.Name,
+			Description: r.Description.String,
+			MIMEType:    r.MimeType.String,
+		})
+	}
+
+	templates := []MCPResourceTemplate{}
+	for _, t := range templateRows {
+		templates = append(templates, MCPResourceTemplate{
+			URITemplate: t.UriTemplate,
+			Name:        t.Name,
+			Description: t.Description.String,
+			MIMEType:    t.MimeType.String,
+		})
+	}
+
+	return resources, templates, nil
+}
+
+type ReadResourceRequest struct {
+	URI string `json:"uri"`
+}
+
+// Reads a resource through the instance's /readResource endpoint
+func ReadMCPInstanceResource(instanceID string, uri string) ([]mcp.ResourceContents, error) {
+	address, err := EnsureMCPServerInstanceRunning(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, _ := json.Marshal(ReadResourceRequest{URI: uri})
+
+	req, err := http.NewRequest(http.MethodPost, address+"/readResource", bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create /readResource request - %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := SetInstanceKeyHeader(req, instanceID); err != nil {
+		return nil, err
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to make request to /readResource - %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read /readResource response - %w", err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Failed to read resource %s from %s - status %d: %s", uri, instanceID, res.StatusCode, string(body))
+	}
+
+	raw := json.RawMessage(body)
+
+	readRes, err := mcp.ParseReadResourceResult(&raw)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse resource %s - %w", uri, err)
+	}
+
+	return readRes.Contents, nil
+}
@@ -0,0 +1,85 @@
+package types
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Tool annotation servers can set to ask for a longer (or shorter) timeout, in seconds
+const MCP_TOOL_TIMEOUT_ANNOTATION = "timeoutSeconds"
+
+/*
+mcp.ToolAnnotation drops fields it doesn't know so tools/list is sent on the transport directly
+to read the timeout annotation. Returns tool name -> seconds for tools that set it.
+*/
+func listMCPToolTimeoutAnnotations(ctx context.Context, t transport.Interface) (map[string]int, error) {
+	timeouts := map[string]int{}
+	cursor := mcp.Cursor("")
+
+	for page := 0; ; page++ {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+
+		res, err := t.SendRequest(ctx, transport.JSONRPCRequest{
+			JSONRPC: mcp.JSONRPC_VERSION,
+			ID:      mcp.NewRequestId(fmt.Sprintf("xtrn-tool-annotations-%d", page)),
+			Method:  string(mcp.MethodToolsList),
+			Params:  params,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list tool annotations - %w", err)
+		}
+		if res.Error != nil {
+			return nil, fmt.Errorf("Failed to list tool annotations - %s", res.Error.Message)
+		}
+
+		var result struct {
+			Tools []struct {
+				Name        string         `json:"name"`
+				Annotations map[string]any `json:"annotations"`
+			} `json:"tools"`
+			NextCursor mcp.Cursor `json:"nextCursor"`
+		}
+
+		if err := json.Unmarshal(res.Result, &result); err != nil {
+			return nil, fmt.Errorf("Failed to parse tool annotations - %w", err)
+		}
+
+		for _, tool := range result.Tools {
+			//JSON numbers decode as float64
+			if s, ok := tool.Annotations[MCP_TOOL_TIMEOUT_ANNOTATION].(float64); ok && s >= 1 {
+				timeouts[tool.Name] = int(s)
+			}
+		}
+
+		if result.NextCursor == "" {
+			return timeouts, nil
+		}
+
+		cursor = result.NextCursor
+	}
+}
+
+// Tool name -> timeout in seconds for the image's tools that don't use the instance default
+func getMCPServerImageToolTimeouts(imageID string) (map[string]int, error) {
+	tools, err := getMCPServerImageTools(imageID)
+	if err != nil {
+		return nil, err
+	}
+
+	timeouts := map[string]int{}
+
+	for _, t := range tools {
+		if t.TimeoutSeconds > 0 {
+			timeouts[t.Name] = t.TimeoutSeconds
+		}
+	}
+
+	return timeouts, nil
+}
@@ -0,0 +1,277 @@
+package types
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/db/models"
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+/*
+Warm pools keep instances of public images started ahead of time (see models.WarmPool). Pooled
+instances run like any other instance but have no row until an instance uses them:
+
+EXCLUSIVE = a pooled instance is handed out as a new instance and a replacement is started
+SHARED    = instances are pointed at one of the pool's running instances instead of starting their own
+
+Pools are started with the API and kept full, members that are lost are replaced.
+*/
+type warmPool struct {
+	imageID string
+	policy  models.WarmPool
+
+	mu       sync.Mutex
+	members  []*MCPServerInstance // EXCLUSIVE = waiting to be handed out, SHARED = shared by instances
+	next     int                  // SHARED member the next instance is pointed at
+	starting int                  // Members being started
+}
+
+// nil entries are images without a pool, images don't change so neither do their pools
+var warmPools = struct {
+	mu    sync.Mutex
+	pools map[string]*warmPool
+}{pools: map[string]*warmPool{}}
+
+func getWarmPool(imageID string) (*warmPool, error) {
+	warmPools.mu.Lock()
+	defer warmPools.mu.Unlock()
+
+	if pool, ok := warmPools.pools[imageID]; ok {
+		return pool, nil
+	}
+
+	img, err := Q.GetMCPServerImage(context.Background(), imageID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get image %s - %w", imageID, err)
+	}
+
+	var pool *warmPool
+	if img.WarmPool.Enabled() {
+		pool = &warmPool{imageID: imageID, policy: img.WarmPool}
+	}
+
+	warmPools.pools[imageID] = pool
+
+	return pool, nil
+}
+
+// Starts members until the pool is full
+func (p *warmPool) fill() {
+	p.mu.Lock()
+	missing := p.policy.Size - len(p.members) - p.starting
+	if missing > 0 {
+		p.starting += missing
+	}
+	p.mu.Unlock()
+
+	for i := 0; i < missing; i++ {
+		go p.startMember()
+	}
+}
+
+func (p *warmPool) startMember() {
+	inst, err := p.newMember()
+
+	p.mu.Lock()
+	p.starting--
+	if err == nil {
+		p.members = append(p.members, inst)
+	}
+	p.mu.Unlock()
+
+	if err != nil {
+		StdErrLogger.Printf("Failed to start warm instance of %s - %v\n", p.imageID, err)
+	}
+}
+
+func (p *warmPool) newMember() (*MCPServerInstance, error) {
+	img, err := Q.GetMCPServerImage(context.Background(), p.imageID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get image %s - %w", p.imageID, err)
+	}
+
+	id, _ := gonanoid.New()
+
+	//Pool images have no env schema, this only checks that nothing is missing
+	instanceEnv, err := resolveInstanceEnv(img, img.ID+"-inst-"+id, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	inst := MCPServerInstance{
+		MCPServerImage: MCPServerImage{
+			ImageID:     img.ID,
+			Slug:        img.Slug,
+			Version:     int(img.Version),
+			Name:        img.Name,
+			DockerImage: img.DockerImage,
+			Launcher:    img.Launcher,
+		},
+		InstanceID:  img.ID + "-inst-" + id,
+		InstanceEnv: instanceEnv,
+	}
+
+	if p.policy.PoolMode() == models.WarmPoolModeShared {
+		inst.InstanceID = img.ID + "-shared-" + id
+		inst.MaxConcurrentCalls = p.policy.MaxConcurrentCalls
+	}
+
+	if err := launchMCPServerInstance(&inst); err != nil {
+		return nil, err
+	}
+
+	return &inst, nil
+}
+
+// Removes the member at address (e.g. its process was lost) and starts a replacement
+func (p *warmPool) forget(address string) bool {
+	p.mu.Lock()
+	found := false
+	for i, m := range p.members {
+		if m.Address == address {
+			p.members = append(p.members[:i], p.members[i+1:]...)
+			found = true
+			break
+		}
+	}
+	p.mu.Unlock()
+
+	if found {
+		p.fill()
+	}
+
+	return found
+}
+
+func (p *warmPool) serves(address string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, m := range p.members {
+		if m.Address == address {
+			return true
+		}
+	}
+
+	return false
+}
+
+func eachWarmPool(fn func(p *warmPool) bool) {
+	warmPools.mu.Lock()
+	pools := []*warmPool{}
+	for _, p := range warmPools.pools {
+		if p != nil {
+			pools = append(pools, p)
+		}
+	}
+	warmPools.mu.Unlock()
+
+	for _, p := range pools {
+		if fn(p) {
+			return
+		}
+	}
+}
+
+// Starts the pools of every image that has one
+func StartWarmPools() error {
+	imgs, err := Q.GetMCPServerImages(context.Background())
+	if err != nil {
+		return fmt.Errorf("Failed to get MCP server images - %w", err)
+	}
+
+	for _, img := range imgs {
+		if !img.WarmPool.Enabled() {
+			continue
+		}
+
+		pool, err := getWarmPool(img.ID)
+		if err != nil {
+			return err
+		}
+
+		pool.fill()
+	}
+
+	return nil
+}
+
+// A started instance of the image to use as a new instance, nil if its EXCLUSIVE pool is empty or it has none
+func takeWarmMCPServerInstance(imageID string) *MCPServerInstance {
+	pool, err := getWarmPool(imageID)
+	if err != nil {
+		StdErrLogger.Print(err)
+		return nil
+	}
+
+	if pool == nil || pool.policy.PoolMode() != models.WarmPoolModeExclusive {
+		return nil
+	}
+
+	defer pool.fill()
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if len(pool.members) == 0 {
+		return nil
+	}
+
+	inst := pool.members[0]
+	pool.members = pool.members[1:]
+
+	return inst
+}
+
+// Points inst at a running member of its image's SHARED pool. False if there is none to share
+func assignSharedWarmMCPServerInstance(inst *MCPServerInstance) bool {
+	pool, err := getWarmPool(inst.ImageID)
+	if err != nil {
+		StdErrLogger.Print(err)
+		return false
+	}
+
+	if pool == nil || pool.policy.PoolMode() != models.WarmPoolModeShared {
+		return false
+	}
+
+	//Pools that couldn't start their members try again
+	defer pool.fill()
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if len(pool.members) == 0 {
+		return false
+	}
+
+	member := pool.members[pool.next%len(pool.members)]
+	pool.next++
+
+	inst.Address = member.Address
+	inst.MaxConcurrentCalls = member.MaxConcurrentCalls
+
+	return true
+}
+
+// Whether address is a SHARED pool member, stopping an instance there mustn't stop the member
+func isSharedWarmAddress(address string) bool {
+	found := false
+
+	eachWarmPool(func(p *warmPool) bool {
+		found = p.policy.PoolMode() == models.WarmPoolModeShared && p.serves(address)
+		return found
+	})
+
+	return found
+}
+
+// Drops a lost member from its pool. Instances that were handed out aren't members anymore
+func forgetWarmPoolMember(address string) {
+	eachWarmPool(func(p *warmPool) bool {
+		return p.forget(address)
+	})
+}
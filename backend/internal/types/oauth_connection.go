@@ -0,0 +1,280 @@
+package types
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	db "github.com/AbhinavPalacharla/xtrn-personal/internal/db/sqlc"
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+var ErrOauthConnectionNotFound = errors.New("Connection not found")
+
+// An account connected through an oauth provider (a row of oauth_tokens). The refresh token is never sent back
+type OauthConnection struct {
+	ID              string     `json:"id"`
+	Provider        string     `json:"provider"`
+	Account         string     `json:"account,omitempty"`
+	Scopes          []string   `json:"scopes"`
+	CreatedAt       *time.Time `json:"created_at,omitempty"`
+	LastRefreshedAt *time.Time `json:"last_refreshed_at,omitempty"`
+	Instances       []string   `json:"instances"` // IDs of the instances whose image uses the provider
+}
+
+type DeletedOauthConnection struct {
+	Revoked          bool     `json:"revoked"`                // Whether the provider revoked the token, false if it can't
+	RevokeError      string   `json:"revoke_error,omitempty"` // The token is deleted even if revoking failed
+	StoppedInstances []string `json:"stopped_instances"`
+}
+
+type OauthConnectionTest struct {
+	OK        bool       `json:"ok"`
+	Error     string     `json:"error,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // Of the access token the refresh returned
+}
+
+// The user's instances whose image uses the connection's provider
+func getOauthConnectionInstances(ctx context.Context, row db.OauthToken) ([]db.McpServerInstance, error) {
+	instances, err := Q.GetMCPServerInstancesByOauthProvider(ctx, db.GetMCPServerInstancesByOauthProviderParams{
+		OauthProvider: sql.NullString{String: row.OauthProvider, Valid: true},
+		UserID:        row.UserID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get instances using %s - %w", row.OauthProvider, err)
+	}
+
+	return instances, nil
+}
+
+func oauthConnectionFromRow(ctx context.Context, row db.OauthToken) (*OauthConnection, error) {
+	c := OauthConnection{
+		ID:        row.ID,
+		Provider:  row.OauthProvider,
+		Account:   row.Account.String,
+		Scopes:    []string{},
+		Instances: []string{},
+	}
+
+	if row.Scopes.Valid {
+		json.Unmarshal([]byte(row.Scopes.String), &c.Scopes)
+	}
+	if row.CreatedAt.Valid {
+		c.CreatedAt = &row.CreatedAt.Time
+	}
+	if row.LastRefreshedAt.Valid {
+		c.LastRefreshedAt = &row.LastRefreshedAt.Time
+	}
+
+	instances, err := getOauthConnectionInstances(ctx, row)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, inst := range instances {
+		c.Instances = append(c.Instances, inst.ID)
+	}
+
+	return &c, nil
+}
+
+func GetOauthConnections(ctx context.Context, userID string) ([]*OauthConnection, error) {
+	rows, err := Q.GetOauthTokens(ctx, nullString(userID))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get connections - %w", err)
+	}
+
+	connections := []*OauthConnection{}
+	for _, row := range rows {
+		c, err := oauthConnectionFromRow(ctx, row)
+		if err != nil {
+			return nil, err
+		}
+
+		connections = append(connections, c)
+	}
+
+	return connections, nil
+}
+
+// ErrForbidden if the connection isn't the user's
+func getOauthTokenRow(ctx context.Context, userID string, id string) (db.OauthToken, error) {
+	row, err := Q.GetOauthToken(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return row, fmt.Errorf("%w - %s", ErrOauthConnectionNotFound, id)
+	} else if err != nil {
+		return row, fmt.Errorf("Failed to get connection %s - %w", id, err)
+	}
+
+	if !isOwner(userID, row.UserID) {
+		return row, fmt.Errorf("%w - connection %s", ErrForbidden, id)
+	}
+
+	return row, nil
+}
+
+func GetOauthConnection(ctx context.Context, userID string, id string) (*OauthConnection, error) {
+	row, err := getOauthTokenRow(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return oauthConnectionFromRow(ctx, row)
+}
+
+// Stores the refresh token from a sign in, replacing the user's previous connection to the provider
+func SaveOauthConnection(ctx context.Context, userID string, providerName string, account string, scopes []string, refreshToken string) error {
+	scopesJSON, _ := json.Marshal(scopes)
+	scopesCol := sql.NullString{String: string(scopesJSON), Valid: len(scopes) > 0}
+	accountCol := sql.NullString{String: account, Valid: account != ""}
+
+	existing, err := Q.GetOauthTokenByProvider(ctx, db.GetOauthTokenByProviderParams{
+		UserID:        nullString(userID),
+		OauthProvider: providerName,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		id, _ := gonanoid.New()
+
+		if err := Q.InsertOauthToken(ctx, db.InsertOauthTokenParams{
+			ID:            id,
+			RefreshToken:  refreshToken,
+			OauthProvider: providerName,
+			Account:       accountCol,
+			Scopes:        scopesCol,
+			UserID:        nullString(userID),
+		}); err != nil {
+			return fmt.Errorf("Failed to save %s connection - %w", providerName, err)
+		}
+
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("Failed to get %s connection - %w", providerName, err)
+	}
+
+	//Providers don't always send a new refresh token when more scopes are granted
+	if refreshToken == "" {
+		refreshToken = existing.RefreshToken
+	}
+
+	if err := Q.ReconnectOauthTokenByProvider(ctx, db.ReconnectOauthTokenByProviderParams{
+		RefreshToken:  refreshToken,
+		Account:       accountCol,
+		Scopes:        scopesCol,
+		UserID:        nullString(userID),
+		OauthProvider: providerName,
+	}); err != nil {
+		return fmt.Errorf("Failed to save %s connection - %w", providerName, err)
+	}
+
+	return nil
+}
+
+// RFC 7009 revocation of the refresh token, which also revokes the access tokens it issued
+func revokeOauthToken(ctx context.Context, provider *OauthProvider, refreshToken string) error {
+	form := url.Values{
+		"token":           {refreshToken},
+		"token_type_hint": {"refresh_token"},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, provider.RevokeURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(provider.ClientID), url.QueryEscape(provider.ClientSecret))
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with %d", provider.RevokeURL, res.StatusCode)
+	}
+
+	return nil
+}
+
+/*
+Revokes the connection's token at its provider when the provider supports it, deletes the token
+and stops the instances using the provider so none keep using tokens they already have.
+*/
+func DeleteOauthConnection(ctx context.Context, userID string, id string) (*DeletedOauthConnection, error) {
+	row, err := getOauthTokenRow(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	providerRow, err := Q.GetOauthProvider(ctx, row.OauthProvider)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get oauth provider %s - %w", row.OauthProvider, err)
+	}
+	provider := oauthProviderFromRow(providerRow)
+
+	deleted := DeletedOauthConnection{StoppedInstances: []string{}}
+
+	if provider.RevokeURL != "" {
+		if err := revokeOauthToken(ctx, provider, row.RefreshToken); err != nil {
+			deleted.RevokeError = err.Error()
+		} else {
+			deleted.Revoked = true
+		}
+	}
+
+	//Waits for a refresh in flight so it can't cache the deleted connection's token again
+	key := oauthTokenKey(userID, row.OauthProvider)
+	unlock := lockOauthToken(key)
+	_, err = Q.DeleteOauthToken(ctx, id)
+	setCachedOauthToken(key, nil)
+	unlock()
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to delete connection %s - %w", id, err)
+	}
+
+	instances, err := getOauthConnectionInstances(ctx, row)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, inst := range instances {
+		if err := StopMCPServerInstance(inst.ID); err != nil {
+			StdErrLogger.Print(err)
+			continue
+		}
+
+		deleted.StoppedInstances = append(deleted.StoppedInstances, inst.ID)
+	}
+
+	return &deleted, nil
+}
+
+// Refreshes the connection's access token to check the provider still accepts its refresh token
+func TestOauthConnection(ctx context.Context, userID string, id string) (*OauthConnectionTest, error) {
+	row, err := getOauthTokenRow(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := getOauthAccessToken(ctx, userID, row.OauthProvider, true)
+	if errors.Is(err, ErrOauthTokenRevoked) {
+		return &OauthConnectionTest{Error: err.Error()}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	test := OauthConnectionTest{OK: true}
+	if !token.ExpiresAt.IsZero() {
+		test.ExpiresAt = &token.ExpiresAt
+	}
+
+	return &test, nil
+}
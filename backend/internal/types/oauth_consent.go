@@ -0,0 +1,173 @@
+package types
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	db "github.com/AbhinavPalacharla/xtrn-personal/internal/db/sqlc"
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	"github.com/markbates/goth"
+)
+
+var ErrOauthConsentRequired = errors.New("Needs additional consent")
+
+/*
+An image needs scopes its provider's connection wasn't granted (or there's no connection). AuthURL
+signs in again asking for them on top of the ones already granted.
+*/
+type OauthConsentRequiredError struct {
+	Provider      string   `json:"provider"`
+	MissingScopes []string `json:"missing_scopes"`
+	AuthURL       string   `json:"auth_url"`
+}
+
+func (e *OauthConsentRequiredError) Error() string {
+	if len(e.MissingScopes) == 0 {
+		return fmt.Sprintf("%s - %s is not connected", ErrOauthConsentRequired, e.Provider)
+	}
+
+	return fmt.Sprintf("%s - %s needs %s", ErrOauthConsentRequired, e.Provider, strings.Join(e.MissingScopes, ", "))
+}
+
+func (e *OauthConsentRequiredError) Unwrap() error {
+	return ErrOauthConsentRequired
+}
+
+// Where the user signs in to grant the scopes imageID needs
+func oauthConsentURL(providerName string, imageID string) string {
+	return APIAddress() + "/auth/" + providerName + "?image_id=" + url.QueryEscape(imageID)
+}
+
+// Scopes the user's connection to the provider was granted, false if it isn't connected
+func grantedOauthScopes(ctx context.Context, userID string, providerName string) ([]string, bool, error) {
+	row, err := Q.GetOauthTokenByProvider(ctx, db.GetOauthTokenByProviderParams{
+		UserID:        nullString(userID),
+		OauthProvider: providerName,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("Failed to get %s connection - %w", providerName, err)
+	}
+
+	granted := []string{}
+	if row.Scopes.Valid {
+		json.Unmarshal([]byte(row.Scopes.String), &granted)
+	}
+
+	return granted, true, nil
+}
+
+// Checks the user connected the image's provider with every scope the image needs
+func checkOauthConsent(ctx context.Context, userID string, img db.GetMCPServerImageRow) error {
+	if !img.OauthProvider.Valid {
+		return nil
+	}
+
+	granted, connected, err := grantedOauthScopes(ctx, userID, img.OauthProvider.String)
+	if err != nil {
+		return err
+	}
+
+	missing := img.OauthScopes.Missing(granted)
+	if connected && len(missing) == 0 {
+		return nil
+	}
+
+	return &OauthConsentRequiredError{
+		Provider:      img.OauthProvider.String,
+		MissingScopes: missing,
+		AuthURL:       oauthConsentURL(img.OauthProvider.String, img.ID),
+	}
+}
+
+func appendScopes(scopes []string, add ...string) []string {
+	for _, s := range add {
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+
+	return scopes
+}
+
+/*
+Scopes to ask the provider for when the user signs in: its own, the ones already granted, the ones
+the images of the user's instances need and the ones imageIDs (images about to get an instance) need.
+*/
+func OauthScopesToRequest(ctx context.Context, userID string, providerName string, imageIDs []string) ([]string, error) {
+	row, err := Q.GetOauthProvider(ctx, providerName)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get oauth provider %s - %w", providerName, err)
+	}
+
+	scopes := oauthProviderFromRow(row).Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	granted, _, err := grantedOauthScopes(ctx, userID, providerName)
+	if err != nil {
+		return nil, err
+	}
+	scopes = appendScopes(scopes, granted...)
+
+	inUse, err := Q.GetOauthScopesInUseByProvider(ctx, db.GetOauthScopesInUseByProviderParams{
+		OauthProvider: sql.NullString{String: providerName, Valid: true},
+		UserID:        nullString(userID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get scopes used by %s instances - %w", providerName, err)
+	}
+	for _, s := range inUse {
+		scopes = appendScopes(scopes, s...)
+	}
+
+	for _, id := range imageIDs {
+		img, err := Q.GetMCPServerImage(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get image %s - %w", id, err)
+		}
+
+		if img.OauthProvider.String != providerName {
+			return nil, fmt.Errorf("Image %s doesn't use %s", id, providerName)
+		}
+
+		scopes = appendScopes(scopes, img.OauthScopes...)
+	}
+
+	return scopes, nil
+}
+
+// Starts signing in to the provider asking for scopes, added to the ones already granted
+func BeginOauthAuth(ctx context.Context, providerName string, scopes []string, state string) (goth.Session, error) {
+	row, err := Q.GetOauthProvider(ctx, providerName)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get oauth provider %s - %w", providerName, err)
+	}
+
+	p := oauthProviderFromRow(row)
+	p.Scopes = scopes
+
+	gp, err := p.GothProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := gp.BeginAuth(state)
+	if err != nil {
+		return nil, err
+	}
+
+	if def, _ := getOauthProviderKind(p.Kind); def.IncludeGrantedScopes != nil {
+		def.IncludeGrantedScopes(s)
+	}
+
+	return s, nil
+}
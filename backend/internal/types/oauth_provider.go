@@ -4,55 +4,309 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
 
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/db/models"
 	db "github.com/AbhinavPalacharla/xtrn-personal/internal/db/sqlc"
 	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/github"
+	"github.com/markbates/goth/providers/google"
+	"github.com/markbates/goth/providers/slack"
+	"golang.org/x/oauth2/endpoints"
 )
 
+var ErrInvalidOauthProvider = errors.New("Invalid oauth provider")
+
+/*
+A provider users connect accounts with. Providers are data (manifests/oauth-providers) stored in
+oauth_providers, Kind picks the goth provider they're built with.
+*/
 type OauthProvider struct {
 	Name         string
+	Kind         models.OauthProviderKind
 	ClientID     string
 	ClientSecret string
 	CallbackURL  string
 	Scopes       []string
-	GothProvider goth.Provider
+	AuthURL      string // OAUTH2 only, the other kinds have their own
+	TokenURL     string // Where the API exchanges refresh tokens for access tokens, defaults to the kind's
+	UserInfoURL  string // OAUTH2 only, where the signed in user's profile is read from
+	PKCE         bool   // OAUTH2 only
+	AuthParams   models.OauthAuthParams
+	RevokeURL    string // RFC 7009 endpoint tokens are revoked at, defaults to the kind's. "" = can't be revoked
+}
+
+/*
+How providers of a kind are built. AuthParams are the params the kind can send to its auth URL,
+nil allows any.
+*/
+type OauthProviderKindDef struct {
+	TokenURL   string // Default token URL, "" = providers must set one
+	RevokeURL  string
+	AuthParams []string
+	PKCE       bool
+	New        func(p *OauthProvider) goth.Provider
+
+	// Makes the session's auth URL add to the scopes already granted instead of replacing them. nil = the provider always adds
+	IncludeGrantedScopes func(s goth.Session)
+
+	// Scopes the user granted, users can uncheck some. nil = the kind can't tell and the requested scopes are saved
+	GrantedScopes func(ctx context.Context, user goth.User) ([]string, error)
+}
+
+var (
+	oauthProviderKinds   = map[models.OauthProviderKind]OauthProviderKindDef{}
+	oauthProviderKindsMu sync.RWMutex
+)
+
+func RegisterOauthProviderKind(kind models.OauthProviderKind, def OauthProviderKindDef) {
+	oauthProviderKindsMu.Lock()
+	defer oauthProviderKindsMu.Unlock()
+
+	oauthProviderKinds[kind] = def
+}
+
+func getOauthProviderKind(kind models.OauthProviderKind) (OauthProviderKindDef, bool) {
+	oauthProviderKindsMu.RLock()
+	defer oauthProviderKindsMu.RUnlock()
+
+	def, ok := oauthProviderKinds[kind]
+	return def, ok
+}
+
+func init() {
+	RegisterOauthProviderKind(models.OauthProviderKindGoogle, OauthProviderKindDef{
+		TokenURL:   endpoints.Google.TokenURL,
+		RevokeURL:  "https://oauth2.googleapis.com/revoke",
+		AuthParams: []string{"access_type", "prompt", "hd", "login_hint"},
+		New: func(p *OauthProvider) goth.Provider {
+			g := google.New(p.ClientID, p.ClientSecret, p.CallbackURL, p.Scopes...)
+
+			if v, ok := p.AuthParams["access_type"]; ok {
+				g.SetAccessType(v)
+			}
+			if v, ok := p.AuthParams["prompt"]; ok {
+				g.SetPrompt(strings.Fields(v)...)
+			}
+			if v, ok := p.AuthParams["hd"]; ok {
+				g.SetHostedDomain(v)
+			}
+			if v, ok := p.AuthParams["login_hint"]; ok {
+				g.SetLoginHint(v)
+			}
+
+			return g
+		},
+		IncludeGrantedScopes: func(s goth.Session) {
+			if gs, ok := s.(*google.Session); ok {
+				gs.AuthURL += "&include_granted_scopes=true"
+			}
+		},
+		GrantedScopes: googleGrantedScopes,
+	})
+
+	RegisterOauthProviderKind(models.OauthProviderKindGithub, OauthProviderKindDef{
+		TokenURL:   endpoints.GitHub.TokenURL,
+		AuthParams: []string{},
+		New: func(p *OauthProvider) goth.Provider {
+			return github.New(p.ClientID, p.ClientSecret, p.CallbackURL, p.Scopes...)
+		},
+		GrantedScopes: githubGrantedScopes,
+	})
+
+	RegisterOauthProviderKind(models.OauthProviderKindSlack, OauthProviderKindDef{
+		TokenURL:   endpoints.Slack.TokenURL,
+		RevokeURL:  "https://slack.com/api/auth.revoke",
+		AuthParams: []string{},
+		New: func(p *OauthProvider) goth.Provider {
+			return slack.New(p.ClientID, p.ClientSecret, p.CallbackURL, p.Scopes...)
+		},
+	})
+
+	//Microsoft, Notion etc. only need their endpoints
+	RegisterOauthProviderKind(models.OauthProviderKindOauth2, OauthProviderKindDef{
+		PKCE:          true,
+		New:           newOauth2GothProvider,
+		GrantedScopes: oauth2GrantedScopes,
+	})
+}
+
+// Reports every problem with the provider at once
+func (p *OauthProvider) Validate() error {
+	errs := []error{}
+
+	if p.Name == "" {
+		errs = append(errs, errors.New("`name` is required"))
+	}
+	if p.ClientID == "" {
+		errs = append(errs, errors.New("`client_id` is required"))
+	}
+	if p.ClientSecret == "" {
+		errs = append(errs, errors.New("`client_secret` is required"))
+	}
+	if p.CallbackURL == "" {
+		errs = append(errs, errors.New("`callback_url` is required"))
+	}
+
+	def, ok := getOauthProviderKind(p.Kind)
+	if !ok {
+		errs = append(errs, fmt.Errorf("%w: %s", models.InvalidOauthProviderKindError, p.Kind))
+		return fmt.Errorf("%w - %w", ErrInvalidOauthProvider, errors.Join(errs...))
+	}
+
+	if p.Kind == models.OauthProviderKindOauth2 && p.AuthURL == "" {
+		errs = append(errs, fmt.Errorf("`auth_url` is required for %s providers", p.Kind))
+	}
+	if p.Kind != models.OauthProviderKindOauth2 && (p.AuthURL != "" || p.UserInfoURL != "") {
+		errs = append(errs, fmt.Errorf("`auth_url` and `user_info_url` can only be set for %s providers", models.OauthProviderKindOauth2))
+	}
+	if p.TokenURL == "" && def.TokenURL == "" {
+		errs = append(errs, fmt.Errorf("`token_url` is required for %s providers", p.Kind))
+	}
+	if p.PKCE && !def.PKCE {
+		errs = append(errs, fmt.Errorf("%s providers don't support pkce", p.Kind))
+	}
+
+	if def.AuthParams != nil {
+		for _, k := range sortedAuthParamKeys(p.AuthParams) {
+			if !slices.Contains(def.AuthParams, k) {
+				errs = append(errs, fmt.Errorf("%s providers don't support auth param `%s`", p.Kind, k))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w - %w", ErrInvalidOauthProvider, errors.Join(errs...))
+	}
+
+	return nil
+}
+
+func sortedAuthParamKeys(params models.OauthAuthParams) []string {
+	keys := []string{}
+	for k := range params {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
+// The provider's goth provider, used for the sign in flow
+func (p *OauthProvider) GothProvider() (goth.Provider, error) {
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", p.Name, err)
+	}
+
+	def, _ := getOauthProviderKind(p.Kind)
+
+	gp := def.New(p)
+	gp.SetName(p.Name)
+
+	return gp, nil
 }
 
-func (p *OauthProvider) StoreOauthProvider() {
+// Validates the provider and creates or updates it
+func (p *OauthProvider) StoreOauthProvider() error {
+	if err := p.Validate(); err != nil {
+		return fmt.Errorf("%s: %w", p.Name, err)
+	}
+
+	def, _ := getOauthProviderKind(p.Kind)
+	if p.TokenURL == "" {
+		p.TokenURL = def.TokenURL
+	}
+	if p.RevokeURL == "" {
+		p.RevokeURL = def.RevokeURL
+	}
+
+	scopes, _ := json.Marshal(p.Scopes)
 
-	Q.InsertOauthProvider(context.Background(), db.InsertOauthProviderParams{
+	err := Q.UpsertOauthProvider(context.Background(), db.UpsertOauthProviderParams{
 		Name:         p.Name,
+		Kind:         p.Kind,
 		ClientID:     p.ClientID,
 		ClientSecret: p.ClientSecret,
 		CallbackUrl:  p.CallbackURL,
 		Scopes: sql.NullString{
-			String: func() string {
-				jsonBytes, _ := json.Marshal(p.Scopes)
-
-				return string(jsonBytes)
-			}(),
-			Valid: len(p.Scopes) > 0,
+			String: string(scopes),
+			Valid:  len(p.Scopes) > 0,
 		},
+		AuthUrl:     sql.NullString{String: p.AuthURL, Valid: p.AuthURL != ""},
+		TokenUrl:    sql.NullString{String: p.TokenURL, Valid: p.TokenURL != ""},
+		UserInfoUrl: sql.NullString{String: p.UserInfoURL, Valid: p.UserInfoURL != ""},
+		Pkce:        p.PKCE,
+		AuthParams:  p.AuthParams,
+		RevokeUrl:   sql.NullString{String: p.RevokeURL, Valid: p.RevokeURL != ""},
 	})
+	if err != nil {
+		return fmt.Errorf("Failed to store oauth provider %s - %w", p.Name, err)
+	}
+
+	return nil
 }
 
-func NewOauthProvider(
-	name string,
-	clientID string,
-	clientSecret string,
-	callbackURL string,
-	scopes []string,
-	newGothProvider func() goth.Provider,
-) *OauthProvider {
+func oauthProviderFromRow(row db.OauthProvider) *OauthProvider {
 	p := OauthProvider{
-		Name:         name,
-		ClientID:     clientID,
-		ClientSecret: clientSecret,
-		CallbackURL:  callbackURL,
-		Scopes:       scopes,
-		GothProvider: newGothProvider(),
+		Name:         row.Name,
+		Kind:         row.Kind,
+		ClientID:     row.ClientID,
+		ClientSecret: row.ClientSecret,
+		CallbackURL:  row.CallbackUrl,
+		AuthURL:      row.AuthUrl.String,
+		TokenURL:     row.TokenUrl.String,
+		UserInfoURL:  row.UserInfoUrl.String,
+		PKCE:         row.Pkce,
+		AuthParams:   row.AuthParams,
+		RevokeURL:    row.RevokeUrl.String,
+	}
+
+	if row.Scopes.Valid {
+		json.Unmarshal([]byte(row.Scopes.String), &p.Scopes)
 	}
 
 	return &p
 }
+
+func GetOauthProviders(ctx context.Context) ([]*OauthProvider, error) {
+	rows, err := Q.GetOauthProviders(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get oauth providers - %w", err)
+	}
+
+	providers := []*OauthProvider{}
+	for _, row := range rows {
+		providers = append(providers, oauthProviderFromRow(row))
+	}
+
+	return providers, nil
+}
+
+/*
+Registers every stored provider with goth. Providers that are invalid are skipped and reported
+together so one bad row doesn't take down sign in for the rest.
+*/
+func UseOauthProviders(ctx context.Context) error {
+	providers, err := GetOauthProviders(ctx)
+	if err != nil {
+		return err
+	}
+
+	errs := []error{}
+	for _, p := range providers {
+		gp, err := p.GothProvider()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		goth.UseProviders(gp)
+	}
+
+	return errors.Join(errs...)
+}
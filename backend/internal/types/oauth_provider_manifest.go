@@ -0,0 +1,152 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/db/models"
+)
+
+var ErrInvalidOauthProviderManifest = errors.New("Invalid oauth provider manifest")
+
+/*
+Declarative description of an oauth provider, written as YAML or JSON. client_id and client_secret
+are values or $env / $secret sources (e.g. $env.GOOGLE_CLIENT_ID) so manifests hold no credentials.
+*/
+type OauthProviderManifest struct {
+	Name         string                   `json:"name" yaml:"name"`
+	Kind         models.OauthProviderKind `json:"kind" yaml:"kind"`
+	ClientID     string                   `json:"client_id" yaml:"client_id"`
+	ClientSecret string                   `json:"client_secret" yaml:"client_secret"`
+	CallbackURL  string                   `json:"callback_url,omitempty" yaml:"callback_url,omitempty"` // Defaults to <API_ADDRESS>/auth/<name>/callback
+	Scopes       []string                 `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+	AuthURL      string                   `json:"auth_url,omitempty" yaml:"auth_url,omitempty"`
+	TokenURL     string                   `json:"token_url,omitempty" yaml:"token_url,omitempty"`
+	UserInfoURL  string                   `json:"user_info_url,omitempty" yaml:"user_info_url,omitempty"`
+	PKCE         bool                     `json:"pkce,omitempty" yaml:"pkce,omitempty"`
+	AuthParams   models.OauthAuthParams   `json:"auth_params,omitempty" yaml:"auth_params,omitempty"`
+	RevokeURL    string                   `json:"revoke_url,omitempty" yaml:"revoke_url,omitempty"`
+}
+
+// Namespaces provider credentials can come from
+var oauthCredentialNamespaces = []string{"env", "secret"}
+
+func resolveOauthCredential(value string) (string, error) {
+	if !strings.HasPrefix(value, "$") {
+		return value, nil
+	}
+
+	namespace, name, err := models.ParseEnvSource(value)
+	if err != nil {
+		return "", err
+	}
+
+	resolver, ok := getEnvResolver(namespace)
+	if !ok || !slices.Contains(oauthCredentialNamespaces, namespace) {
+		return "", fmt.Errorf("%s: credentials can only come from $%s", value, strings.Join(oauthCredentialNamespaces, " or $"))
+	}
+
+	v, err := resolver.Resolve(EnvResolveRequest{}, name)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", value, err)
+	}
+
+	return v, nil
+}
+
+// Resolves the manifest's credentials into a provider. Every problem is reported at once
+func (m *OauthProviderManifest) OauthProvider() (*OauthProvider, error) {
+	errs := []error{}
+
+	clientID, err := resolveOauthCredential(m.ClientID)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("`client_id`: %w", err))
+	}
+
+	clientSecret, err := resolveOauthCredential(m.ClientSecret)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("`client_secret`: %w", err))
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%w - %w", ErrInvalidOauthProviderManifest, errors.Join(errs...))
+	}
+
+	p := OauthProvider{
+		Name:         m.Name,
+		Kind:         m.Kind,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		CallbackURL:  m.CallbackURL,
+		Scopes:       m.Scopes,
+		AuthURL:      m.AuthURL,
+		TokenURL:     m.TokenURL,
+		UserInfoURL:  m.UserInfoURL,
+		PKCE:         m.PKCE,
+		AuthParams:   m.AuthParams,
+		RevokeURL:    m.RevokeURL,
+	}
+
+	if p.CallbackURL == "" {
+		p.CallbackURL = APIAddress() + "/auth/" + p.Name + "/callback"
+	}
+
+	if p.AuthParams == nil {
+		p.AuthParams = models.OauthAuthParams{}
+	}
+
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("%w - %w", ErrInvalidOauthProviderManifest, err)
+	}
+
+	return &p, nil
+}
+
+func ParseOauthProviderManifest(b []byte, format string) (*OauthProviderManifest, error) {
+	m := OauthProviderManifest{}
+
+	if err := decodeManifest(b, format, &m); err != nil {
+		return nil, fmt.Errorf("%w - %w", ErrInvalidOauthProviderManifest, err)
+	}
+
+	return &m, nil
+}
+
+func LoadOauthProviderManifest(path string) (*OauthProviderManifest, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read manifest %s - %w", path, err)
+	}
+
+	m, err := ParseOauthProviderManifest(b, filepath.Ext(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return m, nil
+}
+
+// Loads every *.yaml, *.yml and *.json provider manifest in dir sorted by file name
+func LoadOauthProviderManifests(dir string) ([]*OauthProviderManifest, error) {
+	paths, err := manifestPaths(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	manifests := []*OauthProviderManifest{}
+
+	for _, path := range paths {
+		m, err := LoadOauthProviderManifest(path)
+		if err != nil {
+			return nil, err
+		}
+
+		manifests = append(manifests, m)
+	}
+
+	return manifests, nil
+}
@@ -0,0 +1,233 @@
+package types
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/markbates/goth"
+	"golang.org/x/oauth2"
+)
+
+/*
+Goth provider for OAUTH2 providers, anything with standard authorization code endpoints. The user
+is read from UserInfoURL when it's set (OIDC userinfo style fields), otherwise it only has tokens.
+*/
+type oauth2GothProvider struct {
+	name        string
+	config      *oauth2.Config
+	userInfoURL string
+	pkce        bool
+	authParams  map[string]string
+}
+
+type oauth2GothSession struct {
+	AuthURL      string    `json:"auth_url"`
+	CodeVerifier string    `json:"code_verifier,omitempty"`
+	AccessToken  string    `json:"access_token,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at,omitempty"`
+	IDToken      string    `json:"id_token,omitempty"`
+	Scope        string    `json:"scope,omitempty"` // Granted scopes from the token response, "" if it had none
+}
+
+func newOauth2GothProvider(p *OauthProvider) goth.Provider {
+	return &oauth2GothProvider{
+		name: p.Name,
+		config: &oauth2.Config{
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  p.CallbackURL,
+			Scopes:       p.Scopes,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  p.AuthURL,
+				TokenURL: p.TokenURL,
+			},
+		},
+		userInfoURL: p.UserInfoURL,
+		pkce:        p.PKCE,
+		authParams:  p.AuthParams,
+	}
+}
+
+func (p *oauth2GothProvider) Name() string {
+	return p.name
+}
+
+func (p *oauth2GothProvider) SetName(name string) {
+	p.name = name
+}
+
+func (p *oauth2GothProvider) Debug(bool) {}
+
+func (p *oauth2GothProvider) BeginAuth(state string) (goth.Session, error) {
+	s := oauth2GothSession{}
+	opts := []oauth2.AuthCodeOption{}
+
+	for k, v := range p.authParams {
+		opts = append(opts, oauth2.SetAuthURLParam(k, v))
+	}
+
+	if p.pkce {
+		s.CodeVerifier = oauth2.GenerateVerifier()
+		opts = append(opts, oauth2.S256ChallengeOption(s.CodeVerifier))
+	}
+
+	s.AuthURL = p.config.AuthCodeURL(state, opts...)
+
+	return &s, nil
+}
+
+func (p *oauth2GothProvider) UnmarshalSession(data string) (goth.Session, error) {
+	s := oauth2GothSession{}
+	if err := json.Unmarshal([]byte(data), &s); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func (p *oauth2GothProvider) FetchUser(session goth.Session) (goth.User, error) {
+	s := session.(*oauth2GothSession)
+
+	user := goth.User{
+		Provider:     p.name,
+		AccessToken:  s.AccessToken,
+		RefreshToken: s.RefreshToken,
+		ExpiresAt:    s.ExpiresAt,
+		IDToken:      s.IDToken,
+		RawData:      map[string]any{},
+	}
+
+	if s.Scope != "" {
+		user.RawData["scope"] = s.Scope
+	}
+
+	if user.AccessToken == "" {
+		return user, fmt.Errorf("%s cannot get user information without accessToken", p.name)
+	}
+
+	if p.userInfoURL == "" {
+		return user, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, p.userInfoURL, nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.AccessToken)
+	req.Header.Set("Accept", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return user, fmt.Errorf("Failed to get user from %s - %w", p.name, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return user, fmt.Errorf("%s responded with %d when getting the user", p.name, res.StatusCode)
+	}
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return user, err
+	}
+
+	profile := map[string]any{}
+	if err := json.Unmarshal(b, &profile); err != nil {
+		return user, fmt.Errorf("Failed to parse user from %s - %w", p.name, err)
+	}
+
+	//The token response's scope wins over anything the profile calls scope
+	for k, v := range profile {
+		if _, ok := user.RawData[k]; !ok {
+			user.RawData[k] = v
+		}
+	}
+
+	str := func(keys ...string) string {
+		for _, k := range keys {
+			switch v := user.RawData[k].(type) {
+			case string:
+				return v
+			case float64:
+				return fmt.Sprint(int64(v))
+			}
+		}
+		return ""
+	}
+
+	user.UserID = str("sub", "id")
+	user.Email = str("email", "mail", "userPrincipalName")
+	user.Name = str("name", "displayName")
+	user.NickName = str("preferred_username", "login", "username")
+	user.AvatarURL = str("picture", "avatar_url")
+
+	return user, nil
+}
+
+func (p *oauth2GothProvider) RefreshTokenAvailable() bool {
+	return true
+}
+
+func (p *oauth2GothProvider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return p.config.TokenSource(context.Background(), &oauth2.Token{RefreshToken: refreshToken}).Token()
+}
+
+func (s *oauth2GothSession) GetAuthURL() (string, error) {
+	if s.AuthURL == "" {
+		return "", errors.New(goth.NoAuthUrlErrorMessage)
+	}
+
+	return s.AuthURL, nil
+}
+
+func (s *oauth2GothSession) Marshal() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (s *oauth2GothSession) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	p := provider.(*oauth2GothProvider)
+
+	opts := []oauth2.AuthCodeOption{}
+	if s.CodeVerifier != "" {
+		opts = append(opts, oauth2.VerifierOption(s.CodeVerifier))
+	}
+
+	token, err := p.config.Exchange(context.Background(), params.Get("code"), opts...)
+	if err != nil {
+		return "", err
+	}
+
+	if !token.Valid() {
+		return "", errors.New("Invalid token received from provider")
+	}
+
+	s.AccessToken = token.AccessToken
+	s.RefreshToken = token.RefreshToken
+	s.ExpiresAt = token.Expiry
+
+	if idToken, ok := token.Extra("id_token").(string); ok {
+		s.IDToken = idToken
+	}
+	if scope, ok := token.Extra("scope").(string); ok {
+		s.Scope = scope
+	}
+
+	return token.AccessToken, nil
+}
+
+// Providers that leave scope out of the token response granted what was requested (RFC 6749 5.1)
+func oauth2GrantedScopes(_ context.Context, user goth.User) ([]string, error) {
+	scope, _ := user.RawData["scope"].(string)
+	if scope == "" {
+		return nil, nil
+	}
+
+	return splitScopes(scope), nil
+}
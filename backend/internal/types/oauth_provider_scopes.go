@@ -0,0 +1,106 @@
+package types
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	"github.com/markbates/goth"
+)
+
+/*
+Scopes the user granted in the sign in that returned user, read through the provider's kind.
+nil without an error means the provider doesn't report them and the requested scopes should be used.
+*/
+func GrantedOauthScopes(ctx context.Context, providerName string, user goth.User) ([]string, error) {
+	row, err := Q.GetOauthProvider(ctx, providerName)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get oauth provider %s - %w", providerName, err)
+	}
+
+	def, ok := getOauthProviderKind(row.Kind)
+	if !ok || def.GrantedScopes == nil {
+		return nil, nil
+	}
+
+	scopes, err := def.GrantedScopes(ctx, user)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get scopes granted for %s - %w", providerName, err)
+	}
+
+	return scopes, nil
+}
+
+// Scope lists are space separated (RFC 6749 3.3), some providers use commas
+func splitScopes(scope string) []string {
+	return strings.FieldsFunc(scope, func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+}
+
+const GOOGLE_TOKEN_INFO_URL = "https://oauth2.googleapis.com/tokeninfo"
+
+// Google leaves unchecked scopes out of the token, tokeninfo lists what it carries
+func googleGrantedScopes(ctx context.Context, user goth.User) ([]string, error) {
+	form := url.Values{"access_token": {user.AccessToken}}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, GOOGLE_TOKEN_INFO_URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to request tokeninfo - %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read tokeninfo response - %w", err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tokeninfo responded with %d: %s", res.StatusCode, body)
+	}
+
+	var info struct {
+		Scope string `json:"scope"`
+	}
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("Failed to parse tokeninfo response - %w", err)
+	}
+
+	return splitScopes(info.Scope), nil
+}
+
+const GITHUB_USER_URL = "https://api.github.com/user"
+
+// GitHub reports a token's scopes in the X-OAuth-Scopes header of API responses
+func githubGrantedScopes(ctx context.Context, user goth.User) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, GITHUB_USER_URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+user.AccessToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to request %s - %w", GITHUB_USER_URL, err)
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded with %d", GITHUB_USER_URL, res.StatusCode)
+	}
+
+	return splitScopes(res.Header.Get("X-OAuth-Scopes")), nil
+}
@@ -0,0 +1,266 @@
+package types
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/db/models"
+	db "github.com/AbhinavPalacharla/xtrn-personal/internal/db/sqlc"
+	mcp_launcher "github.com/AbhinavPalacharla/xtrn-personal/internal/mcp-launcher"
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	"golang.org/x/oauth2"
+)
+
+/*
+The API owns oauth tokens. Refresh tokens stay in oauth_tokens and instances only get short-lived
+access tokens, injected when they start ($user.oauth_access_token) or fetched from their token
+endpoint when they need one ($broker.token_url authenticated with $broker.token_key).
+*/
+
+var ErrOauthTokenRevoked = errors.New("Oauth refresh token is invalid or revoked, sign in again")
+var ErrInvalidInstanceKey = errors.New("Invalid instance key")
+
+type OauthAccessToken struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	ExpiresAt   time.Time `json:"expires_at,omitempty"`
+	ExpiresIn   int       `json:"expires_in,omitempty"` // Seconds
+}
+
+// Set with TOKEN_BROKER_ADDRESS when instances can't reach the API on API_ADDRESS (e.g. http://host.docker.internal:8080)
+func TokenBrokerAddress() string {
+	if addr := os.Getenv("TOKEN_BROKER_ADDRESS"); addr != "" {
+		return addr
+	}
+
+	return APIAddress()
+}
+
+func instanceTokenURL(instanceID string) string {
+	return TokenBrokerAddress() + "/instances/" + instanceID + "/oauth/token"
+}
+
+// Access tokens by user and provider (oauthTokenKey). mu only guards the map, refreshes hold the key's lock
+var oauthTokens = struct {
+	mu     sync.Mutex
+	tokens map[string]*oauth2.Token
+}{tokens: map[string]*oauth2.Token{}}
+
+func oauthTokenKey(userID string, providerName string) string {
+	return userID + "/" + providerName
+}
+
+// Refreshes of a connection are serialized so a rotated refresh token is never used twice
+var oauthTokenLocks sync.Map
+
+func lockOauthToken(key string) func() {
+	l, _ := oauthTokenLocks.LoadOrStore(key, &sync.Mutex{})
+	mu := l.(*sync.Mutex)
+
+	mu.Lock()
+	return mu.Unlock
+}
+
+func cachedOauthToken(key string) *oauth2.Token {
+	oauthTokens.mu.Lock()
+	defer oauthTokens.mu.Unlock()
+
+	return oauthTokens.tokens[key]
+}
+
+// nil removes the key's token
+func setCachedOauthToken(key string, token *oauth2.Token) {
+	oauthTokens.mu.Lock()
+	defer oauthTokens.mu.Unlock()
+
+	if token == nil {
+		delete(oauthTokens.tokens, key)
+	} else {
+		oauthTokens.tokens[key] = token
+	}
+}
+
+// A valid access token for the user's provider connection, refreshed (and the refresh token rotated) when it has expired
+func GetOauthAccessToken(ctx context.Context, userID string, providerName string) (*OauthAccessToken, error) {
+	return getOauthAccessToken(ctx, userID, providerName, false)
+}
+
+// force refreshes even if the cached access token is still valid
+func getOauthAccessToken(ctx context.Context, userID string, providerName string, force bool) (*OauthAccessToken, error) {
+	key := oauthTokenKey(userID, providerName)
+	defer lockOauthToken(key)()
+
+	provider, err := Q.GetOauthProvider(ctx, providerName)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get oauth provider %s - %w", providerName, err)
+	}
+
+	if !provider.TokenUrl.Valid {
+		return nil, fmt.Errorf("Oauth provider %s has no token_url", providerName)
+	}
+
+	row, err := Q.GetOauthTokenByProvider(ctx, db.GetOauthTokenByProviderParams{
+		UserID:        nullString(userID),
+		OauthProvider: providerName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get oauth token for %s - %w", providerName, err)
+	}
+
+	//A new refresh token (e.g. the user signed in again) makes the cached access token stale
+	cached := cachedOauthToken(key)
+	if cached == nil || cached.RefreshToken != row.RefreshToken || force {
+		cached = &oauth2.Token{RefreshToken: row.RefreshToken}
+	}
+
+	cfg := oauth2.Config{
+		ClientID:     provider.ClientID,
+		ClientSecret: provider.ClientSecret,
+		Endpoint:     oauth2.Endpoint{TokenURL: provider.TokenUrl.String},
+	}
+
+	token, err := cfg.TokenSource(ctx, cached).Token()
+	if err != nil {
+		retrieveErr := &oauth2.RetrieveError{}
+		if errors.As(err, &retrieveErr) && retrieveErr.ErrorCode == "invalid_grant" {
+			setCachedOauthToken(key, nil)
+			return nil, fmt.Errorf("%w - %s", ErrOauthTokenRevoked, providerName)
+		}
+
+		return nil, fmt.Errorf("Failed to refresh access token for %s - %w", providerName, err)
+	}
+
+	RegisterSecrets(token.AccessToken, token.RefreshToken)
+
+	if token.AccessToken != cached.AccessToken {
+		if err := Q.MarkOauthTokenRefreshed(ctx, db.MarkOauthTokenRefreshedParams{
+			UserID:        nullString(userID),
+			OauthProvider: providerName,
+		}); err != nil {
+			StdErrLogger.Printf("Failed to mark %s token refreshed - %v\n", providerName, err)
+		}
+	}
+
+	//Providers that rotate refresh tokens invalidate the old one
+	if token.RefreshToken != row.RefreshToken {
+		if err := Q.UpdateOauthTokenByProivder(ctx, db.UpdateOauthTokenByProivderParams{
+			RefreshToken:  token.RefreshToken,
+			UserID:        nullString(userID),
+			OauthProvider: providerName,
+		}); err != nil {
+			return nil, fmt.Errorf("Failed to save rotated refresh token for %s - %w", providerName, err)
+		}
+	}
+
+	setCachedOauthToken(key, token)
+
+	accessToken := OauthAccessToken{
+		AccessToken: token.AccessToken,
+		TokenType:   token.Type(),
+	}
+
+	if !token.Expiry.IsZero() {
+		accessToken.ExpiresAt = token.Expiry
+		accessToken.ExpiresIn = int(time.Until(token.Expiry).Seconds())
+	}
+
+	return &accessToken, nil
+}
+
+// An access token for the instance's image's oauth provider, from its user's connection. instanceKey is the instance's $broker.token_key
+func GetMCPServerInstanceAccessToken(ctx context.Context, instanceID string, instanceKey string) (*OauthAccessToken, error) {
+	if !VerifyInstanceKey(instanceID, instanceKey) {
+		return nil, ErrInvalidInstanceKey
+	}
+
+	row, err := Q.GetMCPServerInstance(ctx, instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get instance %s - %w", instanceID, err)
+	}
+
+	img, err := Q.GetMCPServerImageBySlugVersion(ctx, db.GetMCPServerImageBySlugVersionParams{
+		Slug:    row.Slug,
+		Version: row.Version,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get image for instance %s - %w", instanceID, err)
+	}
+
+	if !img.OauthProvider.Valid {
+		return nil, fmt.Errorf("Image %s has no oauth provider", img.ID)
+	}
+
+	return GetOauthAccessToken(ctx, row.UserID.String, img.OauthProvider.String)
+}
+
+// Asks the instance's token endpoint for an access token, the way instances that fetch their own tokens do
+func fetchMCPServerInstanceAccessToken(ctx context.Context, instanceID string, instanceKey string) (*OauthAccessToken, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, instanceTokenURL(instanceID), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+instanceKey)
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to make request to token endpoint - %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("Token endpoint returned %d - %s", res.StatusCode, body)
+	}
+
+	token := OauthAccessToken{}
+	if err := json.NewDecoder(res.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("Failed to decode access token - %w", err)
+	}
+
+	RegisterSecrets(token.AccessToken)
+
+	return &token, nil
+}
+
+/*
+Keeps the bearer token of a remote launcher fresh when it is the user's access token
+($user.oauth_access_token), which expires long before the instance does. Tokens come from the
+instance's token endpoint so the API stays the only one refreshing them. nil if the launcher sends
+anything else, the value in the instance's env doesn't change.
+*/
+func MCPServerInstanceBearerToken(imageID string, instanceID string, l models.MCPLauncher) (mcp_launcher.BearerTokenFunc, error) {
+	if !l.IsRemote() || l.BearerTokenEnv == "" {
+		return nil, nil
+	}
+
+	img, err := Q.GetMCPServerImage(context.Background(), imageID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get image %s - %w", imageID, err)
+	}
+
+	if img.EnvSchema[l.BearerTokenEnv].Source != models.EnvSourceUserAccessToken {
+		return nil, nil
+	}
+
+	instanceKey, err := InstanceKey(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context) (string, error) {
+		token, err := fetchMCPServerInstanceAccessToken(ctx, instanceID, instanceKey)
+		if err != nil {
+			StdErrLogger.Printf("Failed to refresh bearer token of %s - %v\n", instanceID, err)
+			return "", err
+		}
+
+		return token.AccessToken, nil
+	}, nil
+}
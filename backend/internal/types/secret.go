@@ -0,0 +1,102 @@
+package types
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	db "github.com/AbhinavPalacharla/xtrn-personal/internal/db/sqlc"
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+)
+
+var ErrSecretNotFound = errors.New("Secret not found")
+var ErrInvalidSecretName = errors.New("Secret names may only contain letters, numbers, `_`, `.` and `-`")
+
+var secretNameRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Values are never sent back, only that a secret exists
+type Secret struct {
+	Name      string     `json:"name"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// Creates or replaces the secret, env schemas use it as $secret.<name>
+func SetSecret(name string, value string) error {
+	if !secretNameRegex.MatchString(name) {
+		return ErrInvalidSecretName
+	}
+
+	encrypted, err := EncryptSecret(value)
+	if err != nil {
+		return fmt.Errorf("Failed to encrypt secret %s - %w", name, err)
+	}
+
+	if err := Q.UpsertSecret(context.Background(), db.UpsertSecretParams{
+		Name:  name,
+		Value: encrypted,
+	}); err != nil {
+		return fmt.Errorf("Failed to save secret %s - %w", name, err)
+	}
+
+	return nil
+}
+
+func getSecretValue(name string) (string, error) {
+	row, err := Q.GetSecret(context.Background(), name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("%w - %s", ErrSecretNotFound, name)
+	} else if err != nil {
+		return "", fmt.Errorf("Failed to get secret %s - %w", name, err)
+	}
+
+	value, err := DecryptSecret(row.Value)
+	if err != nil {
+		return "", err
+	}
+
+	RegisterSecrets(value)
+
+	return value, nil
+}
+
+func GetSecrets() ([]Secret, error) {
+	rows, err := Q.GetSecrets(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get secrets - %w", err)
+	}
+
+	secrets := []Secret{}
+
+	for _, r := range rows {
+		s := Secret{Name: r.Name}
+
+		if r.CreatedAt.Valid {
+			s.CreatedAt = &r.CreatedAt.Time
+		}
+		if r.UpdatedAt.Valid {
+			s.UpdatedAt = &r.UpdatedAt.Time
+		}
+
+		secrets = append(secrets, s)
+	}
+
+	return secrets, nil
+}
+
+// Instances already started with the secret keep its value
+func DeleteSecret(name string) error {
+	n, err := Q.DeleteSecret(context.Background(), name)
+	if err != nil {
+		return fmt.Errorf("Failed to delete secret %s - %w", name, err)
+	}
+
+	if n == 0 {
+		return fmt.Errorf("%w - %s", ErrSecretNotFound, name)
+	}
+
+	return nil
+}
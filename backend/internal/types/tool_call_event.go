@@ -0,0 +1,83 @@
+package types
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Sent as the Accept header to /callTool to get progress and log events before the result
+const TOOL_CALL_STREAM_CONTENT_TYPE = "application/x-ndjson"
+
+type ToolCallEventType string
+
+const (
+	ToolCallEventTypeProgress ToolCallEventType = "PROGRESS"
+	ToolCallEventTypeLog      ToolCallEventType = "LOG"
+	ToolCallEventTypeResult   ToolCallEventType = "RESULT"
+)
+
+/*
+One line of a streamed /callTool response.
+
+PROGRESS = notifications/progress for the call
+LOG      = notifications/message from the server while the call was running
+RESULT   = last event. Status and Body are what /callTool returns when not streaming
+*/
+type ToolCallEvent struct {
+	Type      ToolCallEventType `json:"type"`
+	ToolUseID string            `json:"tool_use_id,omitempty"`
+
+	Progress float64 `json:"progress,omitempty"`
+	Total    float64 `json:"total,omitempty"`
+	Message  string  `json:"message,omitempty"`
+
+	Level  string `json:"level,omitempty"`
+	Logger string `json:"logger,omitempty"`
+	Data   any    `json:"data,omitempty"`
+
+	Status int             `json:"status,omitempty"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+/*
+Reads a /callTool response. Streamed responses call onEvent for every progress and log event
+and return the status and body of the RESULT event. onEvent can be nil.
+*/
+func ReadToolCallResponse(res *http.Response, onEvent func(ToolCallEvent)) (int, []byte, error) {
+	if res.Header.Get("Content-Type") != TOOL_CALL_STREAM_CONTENT_TYPE {
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			return 0, nil, fmt.Errorf("Failed to read /callTool response - %w", err)
+		}
+
+		return res.StatusCode, body, nil
+	}
+
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		var e ToolCallEvent
+
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return 0, nil, fmt.Errorf("Failed to unmarshal tool call event - %w", err)
+		}
+
+		if e.Type == ToolCallEventTypeResult {
+			return e.Status, e.Body, nil
+		}
+
+		if onEvent != nil {
+			onEvent(e)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, nil, fmt.Errorf("Failed to read tool call events - %w", err)
+	}
+
+	return 0, nil, fmt.Errorf("Tool call stream ended without a result")
+}
@@ -0,0 +1,190 @@
+package types
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	db "github.com/AbhinavPalacharla/xtrn-personal/internal/db/sqlc"
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+// Provider users sign in to xtrn with. Other providers connect accounts for instances
+const SIGN_IN_PROVIDER = "google-signin"
+
+var ErrUserNotFound = errors.New("User not found")
+var ErrForbidden = errors.New("Belongs to another user")
+
+type User struct {
+	ID          string     `json:"id"`
+	Email       string     `json:"email"`
+	Name        string     `json:"name,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+	IsAdmin     bool       `json:"is_admin"` // Can change images and secrets, which every user shares
+}
+
+func userFromRow(row db.User) *User {
+	u := User{
+		ID:      row.ID,
+		Email:   row.Email,
+		Name:    row.Name.String,
+		IsAdmin: row.IsAdmin,
+	}
+
+	if row.CreatedAt.Valid {
+		u.CreatedAt = &row.CreatedAt.Time
+	}
+	if row.LastLoginAt.Valid {
+		u.LastLoginAt = &row.LastLoginAt.Time
+	}
+
+	return &u
+}
+
+func GetUser(ctx context.Context, id string) (*User, error) {
+	row, err := Q.GetUser(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w - %s", ErrUserNotFound, id)
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to get user %s - %w", id, err)
+	}
+
+	return userFromRow(row), nil
+}
+
+func GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	row, err := Q.GetUserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w - %s", ErrUserNotFound, email)
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to get user %s - %w", email, err)
+	}
+
+	return userFromRow(row), nil
+}
+
+// Emails in ADMIN_EMAILS (comma separated) are made admins when they sign in
+func isAdminEmail(email string) bool {
+	for _, e := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		if strings.EqualFold(strings.TrimSpace(e), email) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+Gets or creates the user for an account that signed in with SIGN_IN_PROVIDER. The first user
+created takes over the chats, instances and connections from before there were users and is
+an admin, other users only are if their email is in ADMIN_EMAILS.
+*/
+func SignInUser(ctx context.Context, email string, name string) (*User, error) {
+	if email == "" {
+		return nil, fmt.Errorf("%s didn't return an email", SIGN_IN_PROVIDER)
+	}
+
+	row, err := Q.GetUserByEmail(ctx, email)
+	if err == nil {
+		if err := Q.UpdateUserLogin(ctx, db.UpdateUserLoginParams{
+			Name: nullString(name),
+			ID:   row.ID,
+		}); err != nil {
+			return nil, fmt.Errorf("Failed to update user %s - %w", email, err)
+		}
+
+		if !row.IsAdmin && isAdminEmail(email) {
+			if err := Q.SetUserAdmin(ctx, db.SetUserAdminParams{IsAdmin: true, ID: row.ID}); err != nil {
+				return nil, fmt.Errorf("Failed to make user %s an admin - %w", email, err)
+			}
+		}
+
+		return GetUser(ctx, row.ID)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("Failed to get user %s - %w", email, err)
+	}
+
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to begin transaction - %w", err)
+	}
+	defer tx.Rollback()
+	qtx := Q.WithTx(tx)
+
+	id, _ := gonanoid.New()
+
+	if err := qtx.InsertUser(ctx, db.InsertUserParams{
+		ID:    id,
+		Email: email,
+		Name:  nullString(name),
+	}); err != nil {
+		return nil, fmt.Errorf("Failed to create user %s - %w", email, err)
+	}
+
+	count, err := qtx.CountUsers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to count users - %w", err)
+	}
+
+	if count == 1 || isAdminEmail(email) {
+		if err := qtx.SetUserAdmin(ctx, db.SetUserAdminParams{IsAdmin: true, ID: id}); err != nil {
+			return nil, fmt.Errorf("Failed to make user %s an admin - %w", email, err)
+		}
+	}
+
+	if count == 1 {
+		if err := qtx.ClaimUnownedChats(ctx, nullString(id)); err != nil {
+			return nil, fmt.Errorf("Failed to claim chats - %w", err)
+		}
+		if err := qtx.ClaimUnownedMCPServerInstances(ctx, nullString(id)); err != nil {
+			return nil, fmt.Errorf("Failed to claim instances - %w", err)
+		}
+		if err := qtx.ClaimUnownedOauthTokens(ctx, nullString(id)); err != nil {
+			return nil, fmt.Errorf("Failed to claim connections - %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("Failed to create user %s - %w", email, err)
+	}
+
+	return GetUser(ctx, id)
+}
+
+func isOwner(userID string, owner sql.NullString) bool {
+	return owner.Valid && owner.String == userID
+}
+
+// sql.ErrNoRows if the instance doesn't exist, ErrForbidden if it isn't the user's
+func CheckMCPServerInstanceOwner(ctx context.Context, userID string, instanceID string) error {
+	row, err := Q.GetMCPServerInstance(ctx, instanceID)
+	if err != nil {
+		return fmt.Errorf("Failed to get instance %s - %w", instanceID, err)
+	}
+
+	if !isOwner(userID, row.UserID) {
+		return fmt.Errorf("%w - instance %s", ErrForbidden, instanceID)
+	}
+
+	return nil
+}
+
+// sql.ErrNoRows if the chat doesn't exist, ErrForbidden if it isn't the user's
+func CheckChatOwner(ctx context.Context, userID string, chatID string) error {
+	row, err := Q.GetChat(ctx, chatID)
+	if err != nil {
+		return fmt.Errorf("Failed to get chat %s - %w", chatID, err)
+	}
+
+	if !isOwner(userID, row.UserID) {
+		return fmt.Errorf("%w - chat %s", ErrForbidden, chatID)
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package xtrn_mcp_server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	"github.com/mark3labs/mcp-go/mcp"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+type toolCallRequest struct {
+	ToolUseID string         `json:"tool_use_id"`
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments"`
+}
+
+// Body of a /callTool response. Error is only set when the instance returned an HTTP error
+type toolCallResponse struct {
+	ToolUseID string           `json:"tool_use_id"`
+	Content   []map[string]any `json:"content"`
+	IsError   bool             `json:"is_error"`
+	Error     string           `json:"error"`
+}
+
+/*
+Forwards a tool call to an instance. The instance has already handled the xtrn header so
+the status code tells us how to report the result:
+
+200 = result, 400 = error result for the LLM, 401 = user must re-authenticate, 408 = timeout
+*/
+func callInstanceTool(ctx context.Context, instanceID string, address string, name string, args map[string]any) (*mcp.CallToolResult, error) {
+	id, _ := gonanoid.New()
+
+	payload, err := json.Marshal(toolCallRequest{
+		ToolUseID: id,
+		Name:      name,
+		Arguments: args,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to marshal tool call - %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, address+"/callTool", bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := SetInstanceKeyHeader(req, instanceID); err != nil {
+		return nil, err
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to make request to /callTool - %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read /callTool response - %w", err)
+	}
+
+	var tcRes toolCallResponse
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &tcRes); err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal /callTool response - %w", err)
+		}
+	}
+
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusBadRequest:
+		if tcRes.Error != "" {
+			return mcp.NewToolResultError(tcRes.Error), nil
+		}
+
+		result := mcp.CallToolResult{
+			Content: []mcp.Content{},
+			IsError: tcRes.IsError || res.StatusCode == http.StatusBadRequest,
+		}
+
+		for _, c := range tcRes.Content {
+			content, err := mcp.ParseContent(c)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to parse tool content - %w", err)
+			}
+
+			result.Content = append(result.Content, content)
+		}
+
+		if len(result.Content) == 0 && result.IsError {
+			return mcp.NewToolResultError("Tool call failed"), nil
+		}
+
+		return &result, nil
+
+	case http.StatusUnauthorized:
+		return mcp.NewToolResultError("Function could not be executed because user is unauthorized. User must re-authenticate to continue."), nil
+
+	case http.StatusRequestTimeout:
+		if tcRes.Error != "" {
+			return mcp.NewToolResultError(tcRes.Error), nil
+		}
+
+		return mcp.NewToolResultError("Tool call request timeout"), nil
+	}
+
+	return mcp.NewToolResultError(fmt.Sprintf("Tool call failed (status %d) - %s", res.StatusCode, tcRes.Error)), nil
+}
@@ -0,0 +1,99 @@
+package xtrn_mcp_server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/types"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const SERVER_NAME = "xtrn"
+const SERVER_VERSION = "1.0.0"
+
+/*
+MCP server whose tools are the tools of every running xtrn instance of a user. Tool names are
+namespaced the same way as in chats (<instance id>___<tool name>) and calls are forwarded
+to the instance's /callTool endpoint.
+*/
+type Server struct {
+	MCPServer *server.MCPServer
+	Logger    *log.Logger
+	UserID    string
+}
+
+func NewServer(logger *log.Logger, userID string) (*Server, error) {
+	s := Server{
+		MCPServer: server.NewMCPServer(SERVER_NAME, SERVER_VERSION, server.WithToolCapabilities(true)),
+		Logger:    logger,
+		UserID:    userID,
+	}
+
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// Replaces the tool list with the current instance tools. Connected clients get tools/list_changed
+func (s *Server) Reload() error {
+	instanceTools, err := types.GetMCPInstanceTools(s.UserID)
+	if err != nil {
+		return err
+	}
+
+	tools := []server.ServerTool{}
+
+	for _, t := range instanceTools {
+		schema, err := json.Marshal(t.InputSchema)
+		if err != nil {
+			return fmt.Errorf("Failed to marshal input schema for %s - %w", t.Name, err)
+		}
+
+		tools = append(tools, server.ServerTool{
+			Tool:    mcp.NewToolWithRawSchema(t.Name, t.Description, schema),
+			Handler: s.toolHandler(t),
+		})
+	}
+
+	s.MCPServer.SetTools(tools...)
+	s.Logger.Printf("Loaded %d tools\n", len(tools))
+
+	return nil
+}
+
+// Reloads tools every interval until ctx is done so new and deleted instances are picked up
+func (s *Server) WatchInstances(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Reload(); err != nil {
+				s.Logger.Printf("Failed to reload tools - %v\n", err)
+			}
+		}
+	}
+}
+
+func (s *Server) toolHandler(t types.MCPInstanceTool) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		//Stopped instances are started by the API, which owns them
+		address, err := types.RequestMCPServerInstanceRunning(ctx, t.InstanceID)
+		if err != nil {
+			return nil, err
+		}
+
+		s.Logger.Printf("Calling %s on %s\n", t.ToolName, address)
+
+		return callInstanceTool(ctx, t.InstanceID, address, t.ToolName, req.GetArguments())
+	}
+}
@@ -3,27 +3,26 @@ package main
 import (
 	"fmt"
 
-	mcp_server_images "github.com/AbhinavPalacharla/xtrn-personal/internal/mcp-server-images"
 	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/types"
 )
 
 func main() {
-	googleCalendarImage, err := mcp_server_images.NewGoogleCalendarImage()
-
+	manifestDir, err := GetEnv("MANIFEST_DIR")
 	if err != nil {
-		StdErrLogger.Fatal(err)
-	} else {
-		fmt.Print("✅ Created Google Calendar Image\n")
+		StdErrLogger.Fatal(fmt.Errorf("%w - run: eval $(make setup-env)", err))
 	}
 
-	airBNBImage, err := mcp_server_images.NewAirBNBImage()
-
+	manifests, err := types.LoadMCPServerImageManifests(manifestDir)
 	if err != nil {
 		StdErrLogger.Fatal(err)
-	} else {
-		fmt.Print("✅ Created AirBNB Image\n")
 	}
 
-	_ = googleCalendarImage
-	_ = airBNBImage
+	for _, m := range manifests {
+		if _, err := types.NewMCPServerImageFromManifest(m); err != nil {
+			StdErrLogger.Fatal(fmt.Errorf("Failed to create %s image - %w", m.Name, err))
+		} else {
+			fmt.Printf("✅ Created %s Image\n", m.Name)
+		}
+	}
 }
@@ -1,16 +1,29 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 
-	mcp_server_images "github.com/AbhinavPalacharla/xtrn-personal/internal/mcp-server-images"
 	mcp_server_instances "github.com/AbhinavPalacharla/xtrn-personal/internal/mcp-server-instances"
 	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/types"
 )
 
 func main() {
-	googleCalendarEnv := mcp_server_images.NewGoogleCalendarEnv("Qualcomm Calendar")
-	googleCalendarInstance, err := mcp_server_instances.NewGoogleCalendarInstance(googleCalendarEnv)
+	email := flag.String("user", "", "Email of the user the instances are created for")
+	flag.Parse()
+
+	user, err := types.GetUserByEmail(context.Background(), *email)
+	if err != nil {
+		StdErrLogger.Fatal(fmt.Errorf("%w", err))
+	}
+
+	//User supplied env, see the image's manifest for the rest
+	googleCalendarEnv := map[string]string{
+		"WORK_CALENDAR": "Qualcomm Calendar",
+	}
+	googleCalendarInstance, err := mcp_server_instances.NewGoogleCalendarInstance(user.ID, googleCalendarEnv)
 
 	if err != nil {
 		StdErrLogger.Fatal(fmt.Errorf("%w", err))
@@ -18,8 +31,8 @@ func main() {
 		fmt.Printf("✅ Created Google Calendar Instance %v: 🚀%s\n", googleCalendarInstance.InstanceID, googleCalendarInstance.Address)
 	}
 
-	// airbnbEnv := mcp_server_images.NewAirBNBEnv()
-	// airbnbInstance, err := mcp_server_instances.NewAirBNBInstance(airbnbEnv)
+	// airbnbEnv := map[string]string{}
+	// airbnbInstance, err := mcp_server_instances.NewAirBNBInstance(user.ID, airbnbEnv)
 
 	// if err != nil {
 	// 	StdErrLogger.Fatal(fmt.Errorf("%w", err))
@@ -3,13 +3,31 @@ package main
 import (
 	"fmt"
 
-	oauth_provider "github.com/AbhinavPalacharla/xtrn-personal/internal/oauth-provider"
+	. "github.com/AbhinavPalacharla/xtrn-personal/internal/shared"
+	"github.com/AbhinavPalacharla/xtrn-personal/internal/types"
 )
 
 func main() {
-	oauth_provider.GoogleCalendarOauthProvider.StoreOauthProvider()
+	providerDir, err := GetEnv("OAUTH_PROVIDER_DIR")
+	if err != nil {
+		StdErrLogger.Fatal(fmt.Errorf("%w - run: eval $(make setup-env)", err))
+	}
 
-	oauth_provider.GoogleSigninOauthProvider.StoreOauthProvider()
+	manifests, err := types.LoadOauthProviderManifests(providerDir)
+	if err != nil {
+		StdErrLogger.Fatal(err)
+	}
 
-	fmt.Print("✅ Created Google OAuth Provider\n")
+	for _, m := range manifests {
+		p, err := m.OauthProvider()
+		if err != nil {
+			StdErrLogger.Fatal(fmt.Errorf("Failed to create %s OAuth Provider - %w", m.Name, err))
+		}
+
+		if err := p.StoreOauthProvider(); err != nil {
+			StdErrLogger.Fatal(err)
+		}
+
+		fmt.Printf("✅ Created %s OAuth Provider\n", p.Name)
+	}
 }
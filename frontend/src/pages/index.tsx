@@ -1,17 +1,15 @@
 import { useChat } from "@ai-sdk/react";
 import { useState } from "react";
 
-const API_BASE_URL = "http://127.0.0.1:8080";
-const USER_ID = "4ZV1dBca-cm1rKWg4RyVo";
+// Same host the API's sign in callbacks use so the session cookie is sent
+const API_BASE_URL = "http://localhost:8080";
 
 const Index = () => {
   const [chatID, setChatID] = useState<string | null>(null);
 
   const { messages, input, handleInputChange, handleSubmit, isLoading } = useChat({
     api: chatID ? `${API_BASE_URL}/chat/${chatID}` : `${API_BASE_URL}/chat`,
-    headers: {
-      "x-xtrn-user-id": USER_ID,
-    },
+    credentials: "include",
     experimental_prepareRequestBody: ({ messages }) => {
       return messages[messages.length - 1].content;
     },